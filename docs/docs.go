@@ -0,0 +1,100 @@
+package docs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ContentSecurityPolicy lets the Swagger UI page load its assets from unpkg
+const ContentSecurityPolicy = "default-src 'none'; script-src 'unsafe-inline' https://unpkg.com; " +
+	"style-src https://unpkg.com; img-src data: https://unpkg.com; connect-src 'self'; frame-ancestors 'none'"
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed swagger.html
+var swaggerUI []byte
+
+// SpecHandler serves the OpenAPI document at /openapi.json
+func SpecHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})
+}
+
+// UIHandler serves the Swagger UI page at /docs
+func UIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(swaggerUI)
+	})
+}
+
+// Paths returns the path templates documented in the spec, e.g. /hotels/{id}
+func Paths() ([]string, error) {
+	var doc struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("openapi.json: %w", err)
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// VerifyRoutes checks that every documented path is served by mux and every
+// registered API pattern is documented, so the spec and the router can't drift apart
+func VerifyRoutes(mux *http.ServeMux, patterns []string) error {
+	paths, err := Paths()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, path := range paths {
+		concrete := strings.NewReplacer("{id}", "1", "{entity}", "hotels").Replace(path)
+		req, err := http.NewRequest(http.MethodGet, concrete, nil)
+		if err != nil {
+			return err
+		}
+		if _, pattern := mux.Handler(req); pattern == "" {
+			problems = append(problems, "documented but not routed: "+path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		documented := false
+		for _, path := range paths {
+			if path == strings.TrimSuffix(pattern, "/") || strings.HasPrefix(path, pattern) {
+				documented = true
+				break
+			}
+		}
+		if !documented {
+			problems = append(problems, "routed but not documented: "+pattern)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("OpenAPI spec is out of sync with the router:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
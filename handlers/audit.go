@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"go.mod/repositories"
+	"go.mod/services"
+)
+
+type AuditHandler struct {
+	Service services.AuditService
+}
+
+func NewAuditHandler(service services.AuditService) *AuditHandler {
+	return &AuditHandler{Service: service}
+}
+
+// ServeHTTP handles GET /audit?entity=booking&id=42&limit=100, newest entries first
+func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repositories.AuditFilter{EntityType: query.Get("entity")}
+	if id := query.Get("id"); id != "" {
+		entityID, err := parseID(id)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		filter.EntityID = entityID
+	}
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = value
+	}
+
+	entries, err := h.Service.Find(r.Context(), filter)
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error reading audit log: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(entries)
+}
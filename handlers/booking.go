@@ -2,16 +2,29 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"go.mod/models"
 	"go.mod/services"
+	"gorm.io/gorm"
 )
 
 type BookingHandler struct {
-	Service services.BookingService
+	Service        services.BookingService
+	PaymentService services.PaymentService
+	InvoiceService services.InvoiceService
+}
+
+func NewBookingHandler(service services.BookingService, payments services.PaymentService,
+	invoices services.InvoiceService) *BookingHandler {
+	return &BookingHandler{Service: service, PaymentService: payments, InvoiceService: invoices}
 }
 
 func (h *BookingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -23,6 +36,62 @@ func (h *BookingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	if strings.HasSuffix(id, restoreSuffix) {
+		restoreEntity(w, r, id, "Booking", h.Service.Restore, h.Service.GetByID)
+		return
+	}
+
+	if len(pathSegments) == 3 && pathSegments[0] == "bookings" && pathSegments[2] == "check-in" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.checkIn(w, r, pathSegments[1])
+		return
+	}
+
+	if len(pathSegments) == 3 && pathSegments[0] == "bookings" && pathSegments[2] == "check-out" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.checkOut(w, r, pathSegments[1])
+		return
+	}
+
+	if len(pathSegments) == 3 && pathSegments[0] == "bookings" && pathSegments[2] == "payments" {
+		switch r.Method {
+		case http.MethodGet:
+			h.getPayments(w, r, pathSegments[1])
+		case http.MethodPost:
+			h.recordPayment(w, r, pathSegments[1])
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if len(pathSegments) == 3 && pathSegments[0] == "bookings" && pathSegments[2] == "invoice" {
+		switch r.Method {
+		case http.MethodGet:
+			h.getInvoice(w, r, pathSegments[1])
+		case http.MethodPost:
+			h.issueInvoice(w, r, pathSegments[1])
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if len(pathSegments) == 3 && pathSegments[0] == "bookings" && pathSegments[2] == "cancel" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.cancelBooking(w, r, pathSegments[1])
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		if id != "" {
@@ -38,6 +107,12 @@ func (h *BookingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			http.Error(w, "ID required for update", http.StatusBadRequest)
 		}
+	case http.MethodPatch:
+		if id != "" {
+			h.patchBooking(w, r, id)
+		} else {
+			http.Error(w, "ID required for patch", http.StatusBadRequest)
+		}
 	case http.MethodDelete:
 		if id != "" {
 			h.deleteBooking(w, r, id)
@@ -49,50 +124,123 @@ func (h *BookingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *BookingHandler) getAllBookings(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	guestID := query.Get("guest_id")
+var bookingCSVColumns = []csvColumn[models.Booking]{
+	{"id", func(b *models.Booking) string { return csvUint(b.ID) }},
+	{"guest_id", func(b *models.Booking) string { return csvUint(b.GuestID) }},
+	{"guest_name", func(b *models.Booking) string { return csvText(b.Guest.Name) }},
+	{"guest_mobile_number", func(b *models.Booking) string { return csvText(b.Guest.MobileNumber) }},
+	{"hotel_id", func(b *models.Booking) string { return csvUint(b.HotelID) }},
+	{"hotel_name", func(b *models.Booking) string { return csvText(b.Hotel.Name) }},
+	{"check_in", func(b *models.Booking) string { return b.CheckIn.String() }},
+	{"check_out", func(b *models.Booking) string { return b.CheckOut.String() }},
+	{"status", func(b *models.Booking) string { return b.Status }},
+	{"adults", func(b *models.Booking) string { return strconv.Itoa(b.Adults) }},
+	{"children", func(b *models.Booking) string { return strconv.Itoa(b.Children) }},
+	{"room_types", func(b *models.Booking) string { return csvList(bookingRoomTypes(b)) }},
+	{"total_price", func(b *models.Booking) string { return csvFloat(services.StayPrice(b)) }},
+	{"created_at", func(b *models.Booking) string { return csvTime(b.CreatedAt) }},
+}
+
+// bookingRoomTypes names the room type of every room the booking holds: the reserved types,
+// plus rooms booked directly without a reservation
+func bookingRoomTypes(booking *models.Booking) []string {
+	var types []string
+	assigned := map[uint]bool{}
+	for _, reservation := range booking.Reservations {
+		if reservation.RoomType != nil {
+			types = append(types, reservation.RoomType.Name)
+		}
+		if reservation.RoomID != nil {
+			assigned[*reservation.RoomID] = true
+		}
+	}
+	for _, room := range booking.BookedRooms {
+		if !assigned[room.ID] {
+			types = append(types, room.RoomType)
+		}
+	}
+	return types
+}
+
+// bookingFilter builds a predicate from the guest_id and room_type query parameters
+func bookingFilter(query url.Values) (func(booking *models.Booking) bool, error) {
+	guestIDStr := query.Get("guest_id")
 	roomType := query.Get("room_type")
 
-	bookings, err := h.Service.GetAll()
+	var guestID uint64
+	if guestIDStr != "" {
+		var err error
+		if guestID, err = strconv.ParseUint(guestIDStr, 10, 0); err != nil {
+			return nil, errors.New("Invalid guest_id format")
+		}
+	}
+
+	return func(booking *models.Booking) bool {
+		// Фільтрація за guest_id
+		if guestIDStr != "" && uint64(booking.GuestID) != guestID {
+			return false
+		}
+		// Фільтрація за room_type
+		if roomType != "" {
+			for _, name := range bookingRoomTypes(booking) {
+				if strings.EqualFold(name, roomType) {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	}, nil
+}
+
+func (h *BookingHandler) getAllBookings(w http.ResponseWriter, r *http.Request) {
+	match, err := bookingFilter(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Фільтрація за guest_id
-	if guestID != "" {
-		var filtered []models.Booking
-		for _, booking := range bookings {
-			if booking.Guest.ID == guestID {
-				filtered = append(filtered, booking)
-			}
+	if wantsCSV(r) {
+		writeCSV(w, r, "bookings.csv", bookingCSVColumns, func(fn func(item *models.Booking) error) error {
+			return h.Service.Stream(r.Context(), includeDeleted(r.URL.Query()), fn)
+		}, match)
+		return
+	}
+
+	bookings, err := h.Service.GetAll(r.Context(), includeDeleted(r.URL.Query()))
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
 		}
-		bookings = filtered
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
 	}
 
-	// Фільтрація за room_type
-	if roomType != "" {
-		var filtered []models.Booking
-		for _, booking := range bookings {
-			for _, room := range booking.BookedRooms {
-				if strings.EqualFold(room.RoomType, roomType) {
-					filtered = append(filtered, booking)
-					break // додаємо бронювання лише один раз
-				}
-			}
+	filtered := make([]models.Booking, 0, len(bookings))
+	for i := range bookings {
+		if match(&bookings[i]) {
+			filtered = append(filtered, bookings[i])
 		}
-		bookings = filtered
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(bookings)
+	json.NewEncoder(w).Encode(filtered)
 }
 
 func (h *BookingHandler) getBookingByID(w http.ResponseWriter, r *http.Request, id string) {
-	booking, found := h.Service.GetByID(id)
-	if !found {
-		http.Error(w, "Booking not found", http.StatusNotFound)
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	booking, err := h.Service.GetByID(r.Context(), bookingID)
+	if err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Booking") {
+			return
+		}
+		log.Printf("Error reading booking: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(booking)
@@ -100,54 +248,349 @@ func (h *BookingHandler) getBookingByID(w http.ResponseWriter, r *http.Request,
 
 func (h *BookingHandler) createBooking(w http.ResponseWriter, r *http.Request) {
 	var newBooking models.Booking
-	if err := json.NewDecoder(r.Body).Decode(&newBooking); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &newBooking); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	createdBooking, err := h.Service.Create(newBooking)
-	if err != nil {
+	if err := h.Service.Create(r.Context(), &newBooking); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Booking") || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error creating booking: %v", err)
 		http.Error(w, "Server error during creation", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdBooking)
+	json.NewEncoder(w).Encode(newBooking)
 }
 
 func (h *BookingHandler) updateBooking(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
 	var updatedBooking models.Booking
-	if err := json.NewDecoder(r.Body).Decode(&updatedBooking); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &updatedBooking); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
+	updatedBooking.ID = bookingID
 
-	resultBooking, found, err := h.Service.Update(id, updatedBooking)
-	if err != nil {
+	if err := h.Service.Update(r.Context(), &updatedBooking); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Booking") || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error updating booking: %v", err)
 		http.Error(w, "Server error during update", http.StatusInternalServerError)
 		return
 	}
-	if !found {
-		http.Error(w, "Booking not found", http.StatusNotFound)
+
+	json.NewEncoder(w).Encode(updatedBooking)
+}
+
+// patchBooking applies a merge patch or JSON patch to the stored booking and saves only the changed columns
+func (h *BookingHandler) patchBooking(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.Service.GetByID(r.Context(), bookingID)
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error reading booking: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+
+	patched, fields, err := patchEntity(r, &current, "ID", "CreatedAt", "UpdatedAt", "DeletedAt", "Status", "CancelledAt", "CancellationReason", "CancellationPenalty", "Guest", "Hotel", "BookedRooms", "Reservations")
+	if err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	if err := h.Service.Patch(r.Context(), &patched, fields); err != nil {
+		if writeForbidden(w, err) || writeConflict(w, err) {
+			return
+		}
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("Error patching booking: %v", err)
+		http.Error(w, "Server error during update", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(resultBooking)
+	json.NewEncoder(w).Encode(patched)
 }
 
 func (h *BookingHandler) deleteBooking(w http.ResponseWriter, r *http.Request, id string) {
-	found, err := h.Service.Delete(id)
+	bookingID, err := parseID(id)
 	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Delete(r.Context(), bookingID); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Booking") || writeDeleteBlocked(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error deleting booking: %v", err)
-			http.Error(w, "Server error during deletion", http.StatusInternalServerError)
+		http.Error(w, "Server error during deletion", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkInRequest picks rooms for some of the reservations; an empty body assigns every room automatically
+type checkInRequest struct {
+	Assignments map[uint]uint `json:"assignments"`
+}
+
+func (h *BookingHandler) checkIn(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
 		return
 	}
-	if !found {
+
+	var req checkInRequest
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeDecodeError(w, err)
+		return
+	}
+
+	booking, err := h.Service.CheckIn(r.Context(), bookingID, req.Assignments)
+	if err != nil {
+		if writeForbidden(w, err) || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error checking in booking: %v", err)
+		http.Error(w, "Server error during check-in", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(booking)
+}
+
+type cancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+// cancelBooking cancels the booking with the penalty of its policy; the booking itself is kept
+func (h *BookingHandler) cancelBooking(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	var req cancelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	booking, err := h.Service.Cancel(r.Context(), bookingID, req.Reason)
+	if err != nil {
+		if writeForbidden(w, err) || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error cancelling booking: %v", err)
+		http.Error(w, "Server error during cancellation", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(booking)
+}
+
+// checkOutRequest lets a manager check the guest out although money is still owed
+type checkOutRequest struct {
+	Override bool `json:"override"`
+}
+
+func (h *BookingHandler) checkOut(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	var req checkOutRequest
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeDecodeError(w, err)
+		return
+	}
+
+	booking, err := h.Service.CheckOut(r.Context(), bookingID, req.Override)
+	if err != nil {
+		if writeForbidden(w, err) || writeConflict(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error checking out booking: %v", err)
+		http.Error(w, "Server error during check-out", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(booking)
+}
+
+// writePaymentError maps service errors of the payment endpoints to HTTP statuses
+func writePaymentError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case writeForbidden(w, err), writeValidation(w, err):
+	case errors.Is(err, gorm.ErrRecordNotFound):
 		http.Error(w, "Booking not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrPaymentDeclined):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	default:
+		log.Printf("Error %s payment: %v", action, err)
+		http.Error(w, "Server error during "+action, http.StatusInternalServerError)
+	}
+}
+
+// getPayments returns the booking's ledger and balance
+func (h *BookingHandler) getPayments(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	ledger, err := h.PaymentService.Ledger(r.Context(), bookingID)
+	if err != nil {
+		writePaymentError(w, err, "reading")
+		return
+	}
+	json.NewEncoder(w).Encode(ledger)
+}
+
+func (h *BookingHandler) recordPayment(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	var input services.PaymentInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	payment, err := h.PaymentService.Record(r.Context(), bookingID, input)
+	if err != nil {
+		writePaymentError(w, err, "recording")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// writeInvoiceError maps service errors of the invoice endpoints to HTTP statuses
+func writeInvoiceError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case writeForbidden(w, err), writeConflict(w, err):
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Booking not found", http.StatusNotFound)
+	default:
+		log.Printf("Error %s invoice: %v", action, err)
+		http.Error(w, "Server error during "+action, http.StatusInternalServerError)
+	}
+}
+
+// wantsPDF picks the PDF rendering with ?format=pdf or an Accept header that asks for it
+func wantsPDF(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "pdf"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/pdf")
+}
+
+func writeFolio(w http.ResponseWriter, r *http.Request, folio services.Folio, status int) {
+	if !wantsPDF(r) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(folio)
+		return
+	}
+
+	name := fmt.Sprintf("folio-%d.pdf", folio.BookingID)
+	if !folio.Draft {
+		name = fmt.Sprintf("invoice-%d-%d.pdf", folio.Hotel.ID, folio.Number)
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", name))
+	w.WriteHeader(status)
+	w.Write(services.RenderInvoicePDF(&folio))
+}
+
+// getInvoice returns the issued invoice, or a draft folio while the booking has none
+func (h *BookingHandler) getInvoice(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" && format != "pdf" {
+		http.Error(w, "format must be json or pdf", http.StatusBadRequest)
+		return
+	}
+
+	folio, err := h.InvoiceService.Get(r.Context(), bookingID)
+	if err != nil {
+		writeInvoiceError(w, err, "reading")
+		return
+	}
+	writeFolio(w, r, folio, http.StatusOK)
+}
+
+// issueInvoice issues the invoice of a cancelled or checked-out booking; check-out issues it by itself
+func (h *BookingHandler) issueInvoice(w http.ResponseWriter, r *http.Request, id string) {
+	bookingID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" && format != "pdf" {
+		http.Error(w, "format must be json or pdf", http.StatusBadRequest)
+		return
+	}
+
+	folio, created, err := h.InvoiceService.Issue(r.Context(), bookingID)
+	if err != nil {
+		writeInvoiceError(w, err, "issuing")
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeFolio(w, r, folio, status)
 }
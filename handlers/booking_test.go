@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+
+	"go.mod/models"
+)
+
+// reservedBooking holds room 101 for two nights and has a Suite reserved without a room yet
+func reservedBooking() models.Booking {
+	roomID := uint(7)
+	room := models.Room{Number: "101", RoomType: "Double", Price: 120}
+	room.ID = roomID
+	return models.Booking{
+		CheckIn:     models.NewDate(2026, 5, 4),
+		CheckOut:    models.NewDate(2026, 5, 6),
+		BookedRooms: []models.Room{room},
+		Reservations: []models.Reservation{
+			{RoomID: &roomID, RoomType: &models.RoomType{Name: "Double", BasePrice: 120}},
+			{RoomType: &models.RoomType{Name: "Suite", BasePrice: 250}},
+		},
+	}
+}
+
+func TestBookingFilterMatchesReservedRoomTypes(t *testing.T) {
+	booking := reservedBooking()
+	for roomType, want := range map[string]bool{"double": true, "Suite": true, "Single": false} {
+		match, err := bookingFilter(url.Values{"room_type": {roomType}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := match(&booking); got != want {
+			t.Errorf("room_type=%s matched %v, want %v", roomType, got, want)
+		}
+	}
+}
+
+func TestBookingCSVTotalPriceCountsEveryNight(t *testing.T) {
+	booking := reservedBooking()
+	for _, column := range bookingCSVColumns {
+		if column.Name != "total_price" {
+			continue
+		}
+		// (120 + 250) × 2 ночі
+		if got := column.Value(&booking); got != "740.00" {
+			t.Errorf("total_price = %s, want 740.00", got)
+		}
+		return
+	}
+	t.Fatal("no total_price column")
+}
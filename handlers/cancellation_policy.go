@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+type CancellationPolicyHandler struct {
+	Service services.CancellationPolicyService
+}
+
+func NewCancellationPolicyHandler(service services.CancellationPolicyService) *CancellationPolicyHandler {
+	return &CancellationPolicyHandler{Service: service}
+}
+
+func (h *CancellationPolicyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var id string
+	if len(pathSegments) == 2 && pathSegments[0] == "cancellation-policies" {
+		id = pathSegments[1]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if id != "" {
+			h.getPolicyByID(w, r, id)
+		} else {
+			h.getAllPolicies(w, r)
+		}
+	case http.MethodPost:
+		h.createPolicy(w, r)
+	case http.MethodPut:
+		if id != "" {
+			h.updatePolicy(w, r, id)
+		} else {
+			http.Error(w, "ID required for update", http.StatusBadRequest)
+		}
+	case http.MethodDelete:
+		if id != "" {
+			h.deletePolicy(w, r, id)
+		} else {
+			http.Error(w, "ID required for delete", http.StatusBadRequest)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writePolicyError maps service errors of the cancellation policy endpoints to HTTP statuses
+func writePolicyError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case writeForbidden(w, err), writeValidation(w, err):
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Cancellation policy not found", http.StatusNotFound)
+	default:
+		log.Printf("Error %s cancellation policy: %v", action, err)
+		http.Error(w, "Server error during "+action, http.StatusInternalServerError)
+	}
+}
+
+// getAllPolicies lists the cancellation policies, optionally of one hotel with ?hotel_id=
+func (h *CancellationPolicyHandler) getAllPolicies(w http.ResponseWriter, r *http.Request) {
+	var hotelID uint64
+	if hotelIDStr := r.URL.Query().Get("hotel_id"); hotelIDStr != "" {
+		var err error
+		if hotelID, err = strconv.ParseUint(hotelIDStr, 10, 0); err != nil {
+			http.Error(w, "Invalid hotel_id format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	policies, err := h.Service.GetAll(r.Context(), includeDeleted(r.URL.Query()))
+	if err != nil {
+		writePolicyError(w, err, "reading")
+		return
+	}
+
+	filtered := make([]models.CancellationPolicy, 0, len(policies))
+	for _, policy := range policies {
+		if hotelID == 0 || uint64(policy.HotelID) == hotelID {
+			filtered = append(filtered, policy)
+		}
+	}
+	json.NewEncoder(w).Encode(filtered)
+}
+
+func (h *CancellationPolicyHandler) getPolicyByID(w http.ResponseWriter, r *http.Request, id string) {
+	policyID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid cancellation policy ID", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := h.Service.GetByID(r.Context(), policyID)
+	if err != nil {
+		writePolicyError(w, err, "reading")
+		return
+	}
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *CancellationPolicyHandler) createPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy models.CancellationPolicy
+	if err := decodeJSON(r, &policy); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if err := h.Service.Create(r.Context(), &policy); err != nil {
+		writePolicyError(w, err, "creating")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *CancellationPolicyHandler) updatePolicy(w http.ResponseWriter, r *http.Request, id string) {
+	policyID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid cancellation policy ID", http.StatusBadRequest)
+		return
+	}
+
+	var policy models.CancellationPolicy
+	if err := decodeJSON(r, &policy); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	policy.ID = policyID
+
+	if err := h.Service.Update(r.Context(), &policy); err != nil {
+		writePolicyError(w, err, "updating")
+		return
+	}
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (h *CancellationPolicyHandler) deletePolicy(w http.ResponseWriter, r *http.Request, id string) {
+	policyID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid cancellation policy ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Delete(r.Context(), policyID); err != nil {
+		writePolicyError(w, err, "deleting")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	csvListSeparator = "; "
+	utf8BOM          = "\xEF\xBB\xBF"
+)
+
+// csvColumn describes one column of a CSV export
+type csvColumn[T any] struct {
+	Name  string
+	Value func(item *T) string
+}
+
+// wantsCSV checks ?format=csv|excel first, then the Accept header
+func wantsCSV(r *http.Request) bool {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "csv", "excel":
+		return true
+	case "json":
+		return false
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == "text/csv" {
+			return true
+		}
+	}
+	return false
+}
+
+// selectColumns picks the columns listed in ?columns=a,b,c (all by default)
+func selectColumns[T any](r *http.Request, all []csvColumn[T]) ([]csvColumn[T], error) {
+	param := r.URL.Query().Get("columns")
+	if param == "" {
+		return all, nil
+	}
+
+	var selected []csvColumn[T]
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, col := range all {
+			if strings.EqualFold(col.Name, name) {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+	}
+	return selected, nil
+}
+
+// csvStream writes rows one by one and flushes periodically, so the whole export never sits in memory
+type csvStream[T any] struct {
+	w       http.ResponseWriter
+	cw      *csv.Writer
+	columns []csvColumn[T]
+	rows    int
+}
+
+func newCSVStream[T any](w http.ResponseWriter, r *http.Request, filename string, columns []csvColumn[T]) (*csvStream[T], error) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Excel не розпізнає UTF-8 без BOM, а імена гостей здебільшого кирилицею
+	if strings.EqualFold(r.URL.Query().Get("format"), "excel") {
+		if _, err := w.Write([]byte(utf8BOM)); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &csvStream[T]{w: w, cw: csv.NewWriter(w), columns: columns}
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+	}
+	if err := s.cw.Write(header); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *csvStream[T]) Write(item *T) error {
+	record := make([]string, len(s.columns))
+	for i, col := range s.columns {
+		record[i] = col.Value(item)
+	}
+	if err := s.cw.Write(record); err != nil {
+		return err
+	}
+
+	s.rows++
+	if s.rows%100 == 0 {
+		s.cw.Flush()
+		if f, ok := s.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	return s.cw.Error()
+}
+
+func (s *csvStream[T]) Close() error {
+	s.cw.Flush()
+	return s.cw.Error()
+}
+
+// csvText escapes cells that spreadsheets would otherwise treat as formulas
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func csvList(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = csvText(v)
+	}
+	return strings.Join(escaped, csvListSeparator)
+}
+
+func csvUint(value uint) string {
+	return strconv.FormatUint(uint64(value), 10)
+}
+
+func csvFloat(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', 2, 32)
+}
+
+func csvTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.Format(time.RFC3339)
+}
+
+// writeCSV streams the items matching the filter straight from the repository
+func writeCSV[T any](w http.ResponseWriter, r *http.Request, filename string, all []csvColumn[T],
+	stream func(fn func(item *T) error) error, match func(item *T) bool) {
+	columns, err := selectColumns(r, all)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := newCSVStream(w, r, filename, columns)
+	if err != nil {
+		log.Printf("Error writing CSV header: %v", err)
+		return
+	}
+
+	err = stream(func(item *T) error {
+		if !match(item) {
+			return nil
+		}
+		return out.Write(item)
+	})
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		// Заголовки вже надіслані, тому лише логуємо
+		log.Printf("Error streaming %s: %v", filename, err)
+	}
+}
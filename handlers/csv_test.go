@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.mod/models"
+)
+
+func TestCSVTextEscapesFormulas(t *testing.T) {
+	tests := map[string]string{
+		"=HYPERLINK(\"http://evil\")": "'=HYPERLINK(\"http://evil\")",
+		"+380501234567":               "'+380501234567",
+		"-2+3":                        "'-2+3",
+		"@SUM(A1:A2)":                 "'@SUM(A1:A2)",
+		"\t=1+1":                      "'\t=1+1",
+		"\r=1+1":                      "'\r=1+1",
+		"Olena Koval":                 "Olena Koval",
+		"a=b":                         "a=b",
+		"Київ":                        "Київ",
+		"":                            "",
+	}
+	for value, want := range tests {
+		if got := csvText(value); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", value, got, want)
+		}
+	}
+	if got, want := csvList([]string{"spa", "=1+1", "@pool"}), "spa; '=1+1; '@pool"; got != want {
+		t.Errorf("csvList = %q, want %q", got, want)
+	}
+}
+
+// exportGuests runs the guest export over the guests for a request to target
+func exportGuests(target string, guests ...models.Guest) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	writeCSV(w, r, "guests.csv", guestCSVColumns, func(fn func(item *models.Guest) error) error {
+		for i := range guests {
+			if err := fn(&guests[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(*models.Guest) bool { return true })
+	return w
+}
+
+func TestGuestCSVExport(t *testing.T) {
+	guest := models.Guest{
+		Name:         "Koval, \"Olena\"",
+		MobileNumber: "+380501234567",
+		Email:        "=cmd|' /C calc'!A0",
+		Address:      "Khreshchatyk 1\nKyiv",
+		Preferences:  models.StringSlice{"quiet room", "-late check-out"},
+	}
+	guest.ID = 7
+	w := exportGuests("/guests?format=csv", guest)
+
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	body := w.Body.String()
+	for _, raw := range []string{`"Koval, ""Olena"""`, "\"Khreshchatyk 1\nKyiv\"", `'+380501234567`, `,'=cmd|' /C calc'!A0,`} {
+		if !strings.Contains(body, raw) {
+			t.Errorf("export doesn't contain %q:\n%s", raw, body)
+		}
+	}
+
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHeader := []string{"id", "name", "mobile_number", "preferences", "email", "date_of_birth", "nationality", "address",
+		"document_type", "document_number", "document_expiry", "marketing_email_consent", "marketing_sms_consent", "created_at"}
+	if len(records) != 2 || !slices.Equal(records[0], wantHeader) {
+		t.Fatalf("records = %q, want the header %q and one row", records, wantHeader)
+	}
+	row := records[1]
+	want := map[int]string{
+		0: "7",
+		1: "Koval, \"Olena\"",
+		2: "'+380501234567",
+		3: "quiet room; '-late check-out",
+		4: "'=cmd|' /C calc'!A0",
+		7: "Khreshchatyk 1\nKyiv",
+	}
+	for i, value := range want {
+		if row[i] != value {
+			t.Errorf("%s = %q, want %q", wantHeader[i], row[i], value)
+		}
+	}
+}
+
+func TestGuestCSVColumnsAndExcel(t *testing.T) {
+	guest := models.Guest{Name: "Olena Koval", Email: "olena@example.com"}
+	guest.ID = 7
+
+	w := exportGuests("/guests?format=excel&columns=email,ID,name", guest)
+	body := w.Body.String()
+	if !strings.HasPrefix(body, utf8BOM) {
+		t.Errorf("an Excel export doesn't start with the UTF-8 BOM: %q", body)
+	}
+	if want := "email,id,name\nolena@example.com,7,Olena Koval\n"; strings.TrimPrefix(body, utf8BOM) != want {
+		t.Errorf("export = %q, want %q", body, want)
+	}
+
+	w = exportGuests("/guests?format=csv", guest)
+	if strings.HasPrefix(w.Body.String(), utf8BOM) {
+		t.Error("a plain CSV export starts with a BOM")
+	}
+
+	w = exportGuests("/guests?format=csv&columns=name,password", guest)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown column: status %d, want 400", w.Code)
+	}
+}
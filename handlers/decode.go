@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var errTrailingData = errors.New("request body must contain a single JSON value")
+
+// decodeJSON reads exactly one JSON value into v, rejecting unknown fields and anything after the value.
+// The body is capped by the route's BodyLimitMiddleware.
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errTrailingData
+	}
+	return nil
+}
+
+// decodeStrict is decodeJSON for an already read value, e.g. one element of an import array
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// writeDecodeError maps a decodeJSON failure to a response
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		http.Error(w, fmt.Sprintf("Invalid request body: malformed JSON at offset %d", syntaxErr.Offset), http.StatusBadRequest)
+	case errors.As(err, &typeErr):
+		http.Error(w, fmt.Sprintf("Invalid request body: field %q has the wrong type", typeErr.Field), http.StatusBadRequest)
+	case errors.Is(err, io.EOF):
+		http.Error(w, "Invalid request body: body is empty", http.StatusBadRequest)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		http.Error(w, "Invalid request body: JSON is truncated", http.StatusBadRequest)
+	default:
+		// Сюди потрапляють і невідомі поля: "json: unknown field ..."
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+// writeForbidden answers 403 when the service refused the caller's role; it reports whether it did
+func writeForbidden(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, services.ErrForbidden) {
+		return false
+	}
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return true
+}
+
+// writeNotFound answers 404 when the record doesn't exist or is in the trash; it reports whether it did
+func writeNotFound(w http.ResponseWriter, err error, entity string) bool {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	http.Error(w, entity+" not found", http.StatusNotFound)
+	return true
+}
+
+// writeConflict answers 409 when the operation doesn't fit the current state, e.g. a room type is sold out;
+// it reports whether it did
+func writeConflict(w http.ResponseWriter, err error) bool {
+	var conflict *repositories.ConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	http.Error(w, conflict.Error(), http.StatusConflict)
+	return true
+}
+
+// writeDuplicate answers 409 with message when a unique index refused the row; it reports whether it did
+func writeDuplicate(w http.ResponseWriter, err error, message string) bool {
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return false
+	}
+	http.Error(w, message, http.StatusConflict)
+	return true
+}
+
+// writeValidation answers 422 with the validation problems; it reports whether it did
+func writeValidation(w http.ResponseWriter, err error) bool {
+	var validationErr *services.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	http.Error(w, validationErr.Error(), http.StatusUnprocessableEntity)
+	return true
+}
+
+type deleteBlocker struct {
+	BookingID uint        `json:"booking_id"`
+	GuestID   uint        `json:"guest_id"`
+	HotelID   uint        `json:"hotel_id"`
+	CheckIn   models.Date `json:"check_in"`
+	CheckOut  models.Date `json:"check_out"`
+}
+
+type deleteBlockedResponse struct {
+	Error    string          `json:"error"`
+	Blockers []deleteBlocker `json:"blockers"`
+}
+
+// writeDeleteBlocked answers 409 with the future bookings that prevent a delete; it reports whether it did
+func writeDeleteBlocked(w http.ResponseWriter, err error) bool {
+	var blocked *repositories.DeleteBlockedError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+
+	resp := deleteBlockedResponse{
+		Error:    "The " + blocked.EntityType + " has future bookings; cancel them first or repeat the request with ?cascade=true",
+		Blockers: make([]deleteBlocker, 0, len(blocked.Bookings)),
+	}
+	for _, booking := range blocked.Bookings {
+		resp.Blockers = append(resp.Blockers, deleteBlocker{
+			BookingID: booking.ID,
+			GuestID:   booking.GuestID,
+			HotelID:   booking.HotelID,
+			CheckIn:   booking.CheckIn,
+			CheckOut:  booking.CheckOut,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(resp)
+	return true
+}
@@ -2,18 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"go.mod/models"
 	"go.mod/services"
+	"gorm.io/gorm"
 )
 
 type GuestHandler struct {
 	Service services.GuestService
 }
 
+func NewGuestHandler(service services.GuestService) *GuestHandler {
+	return &GuestHandler{Service: service}
+}
+
 func (h *GuestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
 	var id string
@@ -23,6 +31,11 @@ func (h *GuestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	if strings.HasSuffix(id, restoreSuffix) {
+		restoreEntity(w, r, id, "Guest", h.Service.Restore, h.Service.GetByID)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		if id != "" {
@@ -38,6 +51,12 @@ func (h *GuestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			http.Error(w, "ID required for update", http.StatusBadRequest)
 		}
+	case http.MethodPatch:
+		if id != "" {
+			h.patchGuest(w, r, id)
+		} else {
+			http.Error(w, "ID required for patch", http.StatusBadRequest)
+		}
 	case http.MethodDelete:
 		if id != "" {
 			h.deleteGuest(w, r, id)
@@ -49,44 +68,86 @@ func (h *GuestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *GuestHandler) getAllGuests(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	name := query.Get("name")
+var guestCSVColumns = []csvColumn[models.Guest]{
+	{"id", func(g *models.Guest) string { return csvUint(g.ID) }},
+	{"name", func(g *models.Guest) string { return csvText(g.Name) }},
+	{"mobile_number", func(g *models.Guest) string { return csvText(g.MobileNumber) }},
+	{"preferences", func(g *models.Guest) string { return csvList(g.Preferences) }},
+	{"email", func(g *models.Guest) string { return csvText(g.Email) }},
+	{"date_of_birth", func(g *models.Guest) string { return g.DateOfBirth.String() }},
+	{"nationality", func(g *models.Guest) string { return g.Nationality }},
+	{"address", func(g *models.Guest) string { return csvText(g.Address) }},
+	{"document_type", func(g *models.Guest) string { return g.DocumentType }},
+	{"document_number", func(g *models.Guest) string { return csvText(g.DocumentNumber) }},
+	{"document_expiry", func(g *models.Guest) string { return g.DocumentExpiry.String() }},
+	{"marketing_email_consent", func(g *models.Guest) string { return strconv.FormatBool(g.MarketingEmailConsent) }},
+	{"marketing_sms_consent", func(g *models.Guest) string { return strconv.FormatBool(g.MarketingSMSConsent) }},
+	{"created_at", func(g *models.Guest) string { return csvTime(g.CreatedAt) }},
+}
+
+// guestFilter builds a predicate from the name, mobile_number and email query parameters
+func guestFilter(query url.Values) func(guest *models.Guest) bool {
+	name := strings.ToLower(query.Get("name"))
 	mobileNumber := query.Get("mobile_number")
+	email := query.Get("email")
 
-	guests, err := h.Service.GetAll()
-	if err != nil {
-		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+	return func(guest *models.Guest) bool {
+		if name != "" && !strings.Contains(strings.ToLower(guest.Name), name) {
+			return false
+		}
+		if mobileNumber != "" && guest.MobileNumber != mobileNumber {
+			return false
+		}
+		if email != "" && !strings.EqualFold(guest.Email, email) {
+			return false
+		}
+		return true
+	}
+}
+
+func (h *GuestHandler) getAllGuests(w http.ResponseWriter, r *http.Request) {
+	match := guestFilter(r.URL.Query())
+
+	if wantsCSV(r) {
+		writeCSV(w, r, "guests.csv", guestCSVColumns, func(fn func(item *models.Guest) error) error {
+			return h.Service.Stream(r.Context(), includeDeleted(r.URL.Query()), fn)
+		}, match)
 		return
 	}
 
-	if name != "" {
-		var filtered []models.Guest
-		for _, guest := range guests {
-			if strings.Contains(strings.ToLower(guest.Name), strings.ToLower(name)) {
-				filtered = append(filtered, guest)
-			}
+	guests, err := h.Service.GetAll(r.Context(), includeDeleted(r.URL.Query()))
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
 		}
-		guests = filtered
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
 	}
 
-	if mobileNumber != "" {
-		var filtered []models.Guest
-		for _, guest := range guests {
-			if guest.MobileNumber == mobileNumber {
-				filtered = append(filtered, guest)
-			}
+	filtered := make([]models.Guest, 0, len(guests))
+	for i := range guests {
+		if match(&guests[i]) {
+			filtered = append(filtered, guests[i])
 		}
-		guests = filtered
 	}
 
-	json.NewEncoder(w).Encode(guests)
+	json.NewEncoder(w).Encode(filtered)
 }
 
 func (h *GuestHandler) getGuestByID(w http.ResponseWriter, r *http.Request, id string) {
-	guest, found := h.Service.GetByID(id)
-	if !found {
-		http.Error(w, "Guest not found", http.StatusNotFound)
+	guestID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid guest ID", http.StatusBadRequest)
+		return
+	}
+
+	guest, err := h.Service.GetByID(r.Context(), guestID)
+	if err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Guest") {
+			return
+		}
+		log.Printf("Error reading guest: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(guest)
@@ -94,52 +155,109 @@ func (h *GuestHandler) getGuestByID(w http.ResponseWriter, r *http.Request, id s
 
 func (h *GuestHandler) createGuest(w http.ResponseWriter, r *http.Request) {
 	var newGuest models.Guest
-	if err := json.NewDecoder(r.Body).Decode(&newGuest); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &newGuest); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	createdGuest, err := h.Service.Create(newGuest)
-	if err != nil {
+	if err := h.Service.Create(r.Context(), &newGuest); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Guest") || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error creating guest: %v", err)
 		http.Error(w, "Server error during creation", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdGuest)
+	json.NewEncoder(w).Encode(newGuest)
 }
 
 func (h *GuestHandler) updateGuest(w http.ResponseWriter, r *http.Request, id string) {
+	guestID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid guest ID", http.StatusBadRequest)
+		return
+	}
+
 	var updatedGuest models.Guest
-	if err := json.NewDecoder(r.Body).Decode(&updatedGuest); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &updatedGuest); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
+	updatedGuest.ID = guestID
 
-	resultGuest, found, err := h.Service.Update(id, updatedGuest)
-	if err != nil {
+	if err := h.Service.Update(r.Context(), &updatedGuest); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Guest") || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error updating guest: %v", err)
 		http.Error(w, "Server error during update", http.StatusInternalServerError)
 		return
 	}
-	if !found {
-		http.Error(w, "Guest not found", http.StatusNotFound)
+
+	json.NewEncoder(w).Encode(updatedGuest)
+}
+
+// patchGuest applies a merge patch or JSON patch to the stored guest and saves only the changed columns
+func (h *GuestHandler) patchGuest(w http.ResponseWriter, r *http.Request, id string) {
+	guestID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid guest ID", http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.Service.GetByID(r.Context(), guestID)
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Guest not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error reading guest: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(resultGuest)
+	patched, fields, err := patchEntity(r, &current, "ID", "CreatedAt", "UpdatedAt", "DeletedAt", "ConsentUpdatedAt")
+	if err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	if err := h.Service.Patch(r.Context(), &patched, fields); err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("Error patching guest: %v", err)
+		http.Error(w, "Server error during update", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(patched)
 }
 
 func (h *GuestHandler) deleteGuest(w http.ResponseWriter, r *http.Request, id string) {
-	found, err := h.Service.Delete(id)
+	guestID, err := parseID(id)
 	if err != nil {
-		log.Printf("Error deleting guest: %v", err)
-		http.Error(w, "Server error during deletion", http.StatusInternalServerError)
+		http.Error(w, "Invalid guest ID", http.StatusBadRequest)
 		return
 	}
-	if !found {
-		http.Error(w, "Guest not found", http.StatusNotFound)
+	cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+
+	if err := h.Service.Delete(r.Context(), guestID, cascade); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Guest") || writeDeleteBlocked(w, err) || writeConflict(w, err) {
+			return
+		}
+		log.Printf("Error deleting guest: %v", err)
+		http.Error(w, "Server error during deletion", http.StatusInternalServerError)
 		return
 	}
 
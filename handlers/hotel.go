@@ -2,21 +2,30 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"go.mod/models"
 	"go.mod/services"
+	"gorm.io/gorm"
 )
 
-
 type HotelHandler struct {
-	Service services.HotelService
+	Service         services.HotelService
+	RoomService     services.RoomService
+	RoomTypeService services.RoomTypeService
+}
+
+func NewHotelHandler(service services.HotelService, rooms services.RoomService, roomTypes services.RoomTypeService) *HotelHandler {
+	return &HotelHandler{Service: service, RoomService: rooms, RoomTypeService: roomTypes}
 }
 
 func (h *HotelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	
+
 	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
 	var id string
 	if len(pathSegments) == 2 && pathSegments[0] == "hotels" {
@@ -25,6 +34,29 @@ func (h *HotelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	if strings.HasSuffix(id, restoreSuffix) {
+		restoreEntity(w, r, id, "Hotel", h.Service.Restore, h.Service.GetByID)
+		return
+	}
+
+	if len(pathSegments) == 3 && pathSegments[0] == "hotels" && pathSegments[2] == "rooms:import" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.importRooms(w, r, pathSegments[1])
+		return
+	}
+
+	if len(pathSegments) == 3 && pathSegments[0] == "hotels" && pathSegments[2] == "availability" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.getAvailability(w, r, pathSegments[1])
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		if id != "" {
@@ -40,6 +72,12 @@ func (h *HotelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			http.Error(w, "ID required for update", http.StatusBadRequest)
 		}
+	case http.MethodPatch:
+		if id != "" {
+			h.patchHotel(w, r, id)
+		} else {
+			http.Error(w, "ID required for patch", http.StatusBadRequest)
+		}
 	case http.MethodDelete:
 		if id != "" {
 			h.deleteHotel(w, r, id)
@@ -51,47 +89,150 @@ func (h *HotelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *HotelHandler) getAllHotels(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	name := query.Get("name")
+var hotelCSVColumns = []csvColumn[models.Hotel]{
+	{"id", func(h *models.Hotel) string { return csvUint(h.ID) }},
+	{"name", func(h *models.Hotel) string { return csvText(h.Name) }},
+	{"star_rating", func(h *models.Hotel) string { return strconv.Itoa(h.StarRating) }},
+	{"amenities", func(h *models.Hotel) string { return csvList(h.Amenities) }},
+	{"rooms_count", func(h *models.Hotel) string { return strconv.Itoa(len(h.Rooms)) }},
+	{"room_types", func(h *models.Hotel) string {
+		types := make([]string, len(h.Rooms))
+		for i, room := range h.Rooms {
+			types[i] = room.RoomType
+		}
+		return csvList(types)
+	}},
+	{"created_at", func(h *models.Hotel) string { return csvTime(h.CreatedAt) }},
+}
+
+// hotelFilter builds a predicate from the name and room_type query parameters
+func hotelFilter(query url.Values) func(hotel *models.Hotel) bool {
+	name := strings.ToLower(query.Get("name"))
 	roomType := query.Get("room_type")
 
-	hotels, err := h.Service.GetAll()
+	return func(hotel *models.Hotel) bool {
+		if name != "" && !strings.Contains(strings.ToLower(hotel.Name), name) {
+			return false
+		}
+		if roomType != "" {
+			for _, room := range hotel.Rooms {
+				if strings.EqualFold(room.RoomType, roomType) {
+					return true
+				}
+			}
+			for _, t := range hotel.RoomTypes {
+				if strings.EqualFold(t.Name, roomType) {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	}
+}
+
+func (h *HotelHandler) getAllHotels(w http.ResponseWriter, r *http.Request) {
+	match := hotelFilter(r.URL.Query())
+	if r.URL.Query().Has("near") {
+		h.getNearHotels(w, r, match)
+		return
+	}
+
+	if wantsCSV(r) {
+		writeCSV(w, r, "hotels.csv", hotelCSVColumns, func(fn func(item *models.Hotel) error) error {
+			return h.Service.Stream(r.Context(), includeDeleted(r.URL.Query()), fn)
+		}, match)
+		return
+	}
+
+	hotels, err := h.Service.GetAll(r.Context(), includeDeleted(r.URL.Query()))
 	if err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
 		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
 		return
 	}
 
-	if name != "" {
-		var filtered []models.Hotel
-		for _, hotel := range hotels {
-			if strings.Contains(strings.ToLower(hotel.Name), strings.ToLower(name)) {
-				filtered = append(filtered, hotel)
-			}
+	filtered := make([]models.Hotel, 0, len(hotels))
+	for i := range hotels {
+		if match(&hotels[i]) {
+			filtered = append(filtered, hotels[i])
 		}
-		hotels = filtered
 	}
 
-	if roomType != "" {
-		var filtered []models.Hotel
-		for _, hotel := range hotels {
-			for _, room := range hotel.Rooms {
-				if strings.EqualFold(room.RoomType, roomType) {
-					filtered = append(filtered, hotel)
-					break 
+	json.NewEncoder(w).Encode(filtered)
+}
+
+// getNearHotels answers ?near=lat,lng&radius_km= with the matching hotels nearest first, each with its distance_km
+func (h *HotelHandler) getNearHotels(w http.ResponseWriter, r *http.Request, match func(hotel *models.Hotel) bool) {
+	var query services.GeoQuery
+	coordinates := strings.Split(r.URL.Query().Get("near"), ",")
+	if len(coordinates) != 2 {
+		http.Error(w, "Invalid near format, expected lat,lng", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if query.Latitude, err = strconv.ParseFloat(strings.TrimSpace(coordinates[0]), 64); err != nil {
+		http.Error(w, "Invalid near format, expected lat,lng", http.StatusBadRequest)
+		return
+	}
+	if query.Longitude, err = strconv.ParseFloat(strings.TrimSpace(coordinates[1]), 64); err != nil {
+		http.Error(w, "Invalid near format, expected lat,lng", http.StatusBadRequest)
+		return
+	}
+	if value := r.URL.Query().Get("radius_km"); value != "" {
+		if query.RadiusKm, err = strconv.ParseFloat(value, 64); err != nil {
+			http.Error(w, "Invalid radius_km format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	found, err := h.Service.Near(r.Context(), query, includeDeleted(r.URL.Query()))
+	if err != nil {
+		if writeForbidden(w, err) || writeValidation(w, err) {
+			return
+		}
+		log.Printf("Error searching hotels: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+
+	if wantsCSV(r) {
+		writeCSV(w, r, "hotels.csv", hotelCSVColumns, func(fn func(item *models.Hotel) error) error {
+			for i := range found {
+				if err := fn(&found[i].Hotel); err != nil {
+					return err
 				}
 			}
-		}
-		hotels = filtered
+			return nil
+		}, match)
+		return
 	}
 
-	json.NewEncoder(w).Encode(hotels)
+	filtered := make([]services.HotelDistance, 0, len(found))
+	for i := range found {
+		if match(&found[i].Hotel) {
+			filtered = append(filtered, found[i])
+		}
+	}
+	json.NewEncoder(w).Encode(filtered)
 }
 
 func (h *HotelHandler) getHotelByID(w http.ResponseWriter, r *http.Request, id string) {
-	hotel, found := h.Service.GetByID(id)
-	if !found {
-		http.Error(w, "Hotel not found", http.StatusNotFound)
+	hotelID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid hotel ID", http.StatusBadRequest)
+		return
+	}
+
+	hotel, err := h.Service.GetByID(r.Context(), hotelID)
+	if err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Hotel") {
+			return
+		}
+		log.Printf("Error reading hotel: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(hotel)
@@ -99,54 +240,154 @@ func (h *HotelHandler) getHotelByID(w http.ResponseWriter, r *http.Request, id s
 
 func (h *HotelHandler) createHotel(w http.ResponseWriter, r *http.Request) {
 	var newHotel models.Hotel
-	if err := json.NewDecoder(r.Body).Decode(&newHotel); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &newHotel); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	createdHotel, err := h.Service.Create(newHotel)
-	if err != nil {
+	if err := h.Service.Create(r.Context(), &newHotel); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Hotel") || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error creating hotel: %v", err)
 		http.Error(w, "Server error during creation", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdHotel)
+	json.NewEncoder(w).Encode(newHotel)
 }
 
 func (h *HotelHandler) updateHotel(w http.ResponseWriter, r *http.Request, id string) {
+	hotelID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid hotel ID", http.StatusBadRequest)
+		return
+	}
+
 	var updatedHotel models.Hotel
-	if err := json.NewDecoder(r.Body).Decode(&updatedHotel); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &updatedHotel); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
+	updatedHotel.ID = hotelID
 
-	resultHotel, found, err := h.Service.Update(id, updatedHotel)
-	if err != nil {
+	if err := h.Service.Update(r.Context(), &updatedHotel); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Hotel") || writeValidation(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error updating hotel: %v", err)
 		http.Error(w, "Server error during update", http.StatusInternalServerError)
 		return
 	}
-	if !found {
-		http.Error(w, "Hotel not found", http.StatusNotFound)
+
+	json.NewEncoder(w).Encode(updatedHotel)
+}
+
+// patchHotel applies a merge patch or JSON patch to the stored hotel and saves only the changed columns
+func (h *HotelHandler) patchHotel(w http.ResponseWriter, r *http.Request, id string) {
+	hotelID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid hotel ID", http.StatusBadRequest)
 		return
 	}
 
-	json.NewEncoder(w).Encode(resultHotel)
+	current, err := h.Service.GetByID(r.Context(), hotelID)
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Hotel not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error reading hotel: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+
+	patched, fields, err := patchEntity(r, &current, "ID", "CreatedAt", "UpdatedAt", "DeletedAt", "Rooms", "RoomTypes", "TaxRules")
+	if err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	if err := h.Service.Patch(r.Context(), &patched, fields); err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("Error patching hotel: %v", err)
+		http.Error(w, "Server error during update", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(patched)
 }
 
 func (h *HotelHandler) deleteHotel(w http.ResponseWriter, r *http.Request, id string) {
-	found, err := h.Service.Delete(id)
+	hotelID, err := parseID(id)
 	if err != nil {
+		http.Error(w, "Invalid hotel ID", http.StatusBadRequest)
+		return
+	}
+	cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+
+	if err := h.Service.Delete(r.Context(), hotelID, cascade); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Hotel") || writeDeleteBlocked(w, err) || writeConflict(w, err) {
+			return
+		}
 		log.Printf("Error deleting hotel: %v", err)
 		http.Error(w, "Server error during deletion", http.StatusInternalServerError)
 		return
 	}
-	if !found {
-		http.Error(w, "Hotel not found", http.StatusNotFound)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getAvailability answers GET /hotels/{id}/availability?check_in=&check_out=&adults=&children=
+// with the free rooms per room type and, for a party, the room combinations that fit it
+func (h *HotelHandler) getAvailability(w http.ResponseWriter, r *http.Request, id string) {
+	hotelID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid hotel ID", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	var query services.AvailabilityQuery
+	for param, date := range map[string]*models.Date{"check_in": &query.CheckIn, "check_out": &query.CheckOut} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		if *date, err = models.ParseDate(value); err != nil {
+			http.Error(w, "Invalid "+param+" format, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	for param, count := range map[string]*int{"adults": &query.Adults, "children": &query.Children} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		if *count, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid "+param+" format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	availability, err := h.RoomTypeService.Availability(r.Context(), hotelID, query)
+	if err != nil {
+		if writeForbidden(w, err) || writeValidation(w, err) {
+			return
+		}
+		log.Printf("Error reading availability: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(availability)
 }
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// parseID converts a path segment into a primary key
+func parseID(id string) (uint, error) {
+	value, err := strconv.ParseUint(id, 10, 0)
+	return uint(value), err
+}
+
+// patchError carries the HTTP status that should be returned for a failed patch
+type patchError struct {
+	status  int
+	message string
+}
+
+func (e *patchError) Error() string { return e.message }
+
+func newPatchError(status int, format string, args ...interface{}) *patchError {
+	return &patchError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// writePatchError maps a patch failure to an HTTP response
+func writePatchError(w http.ResponseWriter, err error) {
+	var pe *patchError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &pe):
+		http.Error(w, pe.message, pe.status)
+	case errors.As(err, &maxBytesErr):
+		writeDecodeError(w, err)
+	default:
+		http.Error(w, "Invalid patch: "+err.Error(), http.StatusBadRequest)
+	}
+}
+
+// patchEntity applies a Merge Patch (RFC 7396) or JSON Patch (RFC 6902) from the request body to current.
+// It returns the patched copy and the names of the top-level fields that actually changed.
+// Fields listed in readOnly (e.g. ID, associations) may not be modified.
+func patchEntity[T any](r *http.Request, current *T, readOnly ...string) (T, []string, error) {
+	var patched T
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchContentType && mediaType != jsonPatchContentType {
+		return patched, nil, newPatchError(http.StatusUnsupportedMediaType,
+			"Content-Type must be %s or %s", mergePatchContentType, jsonPatchContentType)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return patched, nil, err
+	}
+
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return patched, nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(currentJSON, &doc); err != nil {
+		return patched, nil, err
+	}
+	original, _ := doc.(map[string]interface{})
+	doc = deepCopy(doc)
+
+	var patchDoc interface{}
+	if err := json.Unmarshal(body, &patchDoc); err != nil {
+		return patched, nil, newPatchError(http.StatusBadRequest, "Invalid patch document: %v", err)
+	}
+
+	if mediaType == mergePatchContentType {
+		doc = mergePatch(doc, patchDoc)
+	} else {
+		ops, ok := patchDoc.([]interface{})
+		if !ok {
+			return patched, nil, newPatchError(http.StatusBadRequest, "JSON Patch must be an array of operations")
+		}
+		doc, err = applyJSONPatch(doc, ops)
+		if err != nil {
+			return patched, nil, err
+		}
+	}
+
+	result, ok := doc.(map[string]interface{})
+	if !ok {
+		return patched, nil, newPatchError(http.StatusUnprocessableEntity, "Patch must produce an object")
+	}
+
+	var changed []string
+	for key := range result {
+		if _, known := original[key]; !known {
+			return patched, nil, newPatchError(http.StatusUnprocessableEntity, "Unknown field %q", key)
+		}
+	}
+	for key, before := range original {
+		after, present := result[key]
+		if present && reflect.DeepEqual(before, after) {
+			continue
+		}
+		for _, field := range readOnly {
+			if field == key {
+				return patched, nil, newPatchError(http.StatusUnprocessableEntity, "Field %q is read-only", key)
+			}
+		}
+		changed = append(changed, key)
+	}
+
+	patchedJSON, err := json.Marshal(result)
+	if err != nil {
+		return patched, nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(patchedJSON)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		return patched, nil, newPatchError(http.StatusUnprocessableEntity, "Patched entity is invalid: %v", err)
+	}
+	return patched, changed, nil
+}
+
+// mergePatch implements RFC 7396: objects merge recursively, null removes, anything else replaces
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// applyJSONPatch implements the RFC 6902 operations: add, remove, replace, move, copy and test
+func applyJSONPatch(doc interface{}, ops []interface{}) (interface{}, error) {
+	for i, rawOp := range ops {
+		op, ok := rawOp.(map[string]interface{})
+		if !ok {
+			return nil, newPatchError(http.StatusBadRequest, "Operation %d is not an object", i)
+		}
+		name, _ := op["op"].(string)
+		path, ok := op["path"].(string)
+		if !ok {
+			return nil, newPatchError(http.StatusBadRequest, "Operation %d has no path", i)
+		}
+		value, hasValue := op["value"]
+
+		var err error
+		switch name {
+		case "add":
+			if !hasValue {
+				return nil, newPatchError(http.StatusBadRequest, "Operation %d (add) has no value", i)
+			}
+			doc, err = pointerAdd(doc, path, value)
+		case "remove":
+			doc, _, err = pointerRemove(doc, path)
+		case "replace":
+			if !hasValue {
+				return nil, newPatchError(http.StatusBadRequest, "Operation %d (replace) has no value", i)
+			}
+			doc, _, err = pointerRemove(doc, path)
+			if err == nil {
+				doc, err = pointerAdd(doc, path, value)
+			}
+		case "move", "copy":
+			from, ok := op["from"].(string)
+			if !ok {
+				return nil, newPatchError(http.StatusBadRequest, "Operation %d (%s) has no from", i, name)
+			}
+			var moved interface{}
+			if name == "move" {
+				if strings.HasPrefix(path, from+"/") {
+					return nil, newPatchError(http.StatusBadRequest, "Operation %d moves a value into itself", i)
+				}
+				doc, moved, err = pointerRemove(doc, from)
+			} else {
+				moved, err = pointerGet(doc, from)
+				moved = deepCopy(moved)
+			}
+			if err == nil {
+				doc, err = pointerAdd(doc, path, moved)
+			}
+		case "test":
+			var actual interface{}
+			actual, err = pointerGet(doc, path)
+			if err == nil && !reflect.DeepEqual(actual, value) {
+				return nil, newPatchError(http.StatusConflict, "Test failed at %s", path)
+			}
+		default:
+			return nil, newPatchError(http.StatusBadRequest, "Operation %d has unknown op %q", i, name)
+		}
+		if err != nil {
+			return nil, newPatchError(http.StatusUnprocessableEntity, "Operation %d (%s %s): %v", i, name, path, err)
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := length - 1
+	if allowEnd {
+		limit = length
+	}
+	if idx > limit {
+		return 0, fmt.Errorf("array index %d out of range", idx)
+	}
+	return idx, nil
+}
+
+func pointerGet(doc interface{}, path string) (interface{}, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	current := doc
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", path)
+			}
+			current = value
+		case []interface{}:
+			idx, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[idx]
+		default:
+			return nil, fmt.Errorf("path %s not found", path)
+		}
+	}
+	return current, nil
+}
+
+// pointerAdd returns the document with value inserted at path; arrays are rebuilt, so the new root is returned
+func pointerAdd(doc interface{}, path string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	return addAt(doc, tokens, value)
+}
+
+func addAt(node interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			n[token] = value
+			return n, nil
+		}
+		child, ok := n[token]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+		updated, err := addAt(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = updated
+		return n, nil
+	case []interface{}:
+		if len(rest) == 0 {
+			idx, err := arrayIndex(token, len(n), true)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+			return n, nil
+		}
+		idx, err := arrayIndex(token, len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := addAt(n[idx], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = updated
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot add to a scalar at %q", token)
+	}
+}
+
+// pointerRemove returns the document without the value at path, and the removed value
+func pointerRemove(doc interface{}, path string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	return removeAt(doc, tokens)
+}
+
+func removeAt(node interface{}, tokens []string) (interface{}, interface{}, error) {
+	token, rest := tokens[0], tokens[1:]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("member %q not found", token)
+		}
+		if len(rest) == 0 {
+			delete(n, token)
+			return n, child, nil
+		}
+		updated, removed, err := removeAt(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[token] = updated
+		return n, removed, nil
+	case []interface{}:
+		idx, err := arrayIndex(token, len(n), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := n[idx]
+			return append(n[:idx:idx], n[idx+1:]...), removed, nil
+		}
+		updated, removed, err := removeAt(n[idx], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[idx] = updated
+		return n, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("member %q not found", token)
+	}
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = deepCopy(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = deepCopy(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
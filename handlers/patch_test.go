@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseJSONValue(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	return value
+}
+
+// TestJSONPatchAppendixA runs the examples of RFC 6902 Appendix A
+func TestJSONPatchAppendixA(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		patch  string
+		want   string
+		status int
+	}{
+		{"A.1 adding an object member", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`, 0},
+		{"A.2 adding an array element", `{"foo": ["bar", "baz"]}`,
+			`[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`, 0},
+		{"A.3 removing an object member", `{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`, 0},
+		{"A.4 removing an array element", `{"foo": ["bar", "qux", "baz"]}`,
+			`[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`, 0},
+		{"A.5 replacing a value", `{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`, 0},
+		{"A.6 moving a value", `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`, 0},
+		{"A.7 moving an array element", `{"foo": ["all", "grass", "cows", "eat"]}`,
+			`[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`, 0},
+		{"A.8 testing a value: success", `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			`[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			`{"baz": "qux", "foo": ["a", 2, "c"]}`, 0},
+		{"A.9 testing a value: error", `{"baz": "qux"}`,
+			`[{"op": "test", "path": "/baz", "value": "bar"}]`, "", http.StatusConflict},
+		{"A.10 adding a nested member object", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo": "bar", "child": {"grandchild": {}}}`, 0},
+		{"A.11 ignoring unrecognized elements", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`, `{"foo": "bar", "baz": "qux"}`, 0},
+		{"A.12 adding to a nonexistent target", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, "", http.StatusUnprocessableEntity},
+		{"A.14 ~ escape ordering", `{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": 10}]`, `{"/": 9, "~1": 10}`, 0},
+		{"A.15 comparing strings and numbers", `{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": "10"}]`, "", http.StatusConflict},
+		{"A.16 adding an array value", `{"foo": ["bar"]}`,
+			`[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar", ["abc", "def"]]}`, 0},
+		{"~1 in a path", `{"a/b": 1}`,
+			`[{"op": "replace", "path": "/a~1b", "value": 2}]`, `{"a/b": 2}`, 0},
+		{"copy", `{"foo": {"bar": [1]}}`,
+			`[{"op": "copy", "from": "/foo/bar", "path": "/baz"}, {"op": "add", "path": "/baz/-", "value": 2}]`,
+			`{"foo": {"bar": [1]}, "baz": [1, 2]}`, 0},
+		{"move into itself", `{"foo": {"bar": 1}}`,
+			`[{"op": "move", "from": "/foo", "path": "/foo/bar"}]`, "", http.StatusBadRequest},
+		{"index past the end", `{"foo": ["bar"]}`,
+			`[{"op": "add", "path": "/foo/2", "value": "baz"}]`, "", http.StatusUnprocessableEntity},
+		{"unknown op", `{"foo": "bar"}`,
+			`[{"op": "merge", "path": "/foo", "value": "baz"}]`, "", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ops, _ := parseJSONValue(t, tc.patch).([]interface{})
+			got, err := applyJSONPatch(parseJSONValue(t, tc.doc), ops)
+			if tc.status != 0 {
+				var pe *patchError
+				if !errors.As(err, &pe) || pe.status != tc.status {
+					t.Fatalf("err = %v, want status %d", err, tc.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := parseJSONValue(t, tc.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+type patchTestEntity struct {
+	ID        uint
+	CreatedAt time.Time
+	Name      string
+	Note      string
+	Tags      []string
+}
+
+func patchRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPatch, "/things/1", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	return r
+}
+
+func TestPatchEntity(t *testing.T) {
+	current := patchTestEntity{ID: 1, CreatedAt: time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC), Name: "Lodge",
+		Note: "Late arrival", Tags: []string{"quiet"}}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        patchTestEntity
+		changed     []string
+		status      int
+	}{
+		{"merge patch null deletes a key", mergePatchContentType, `{"Note": null}`,
+			patchTestEntity{ID: 1, CreatedAt: current.CreatedAt, Name: "Lodge", Tags: []string{"quiet"}}, []string{"Note"}, 0},
+		{"merge patch replaces arrays", mergePatchContentType, `{"Tags": ["quiet", "view"], "Name": "Lodge"}`,
+			patchTestEntity{ID: 1, CreatedAt: current.CreatedAt, Name: "Lodge", Note: "Late arrival", Tags: []string{"quiet", "view"}},
+			[]string{"Tags"}, 0},
+		{"JSON Patch appends with -", jsonPatchContentType, `[{"op": "add", "path": "/Tags/-", "value": "view"}]`,
+			patchTestEntity{ID: 1, CreatedAt: current.CreatedAt, Name: "Lodge", Note: "Late arrival", Tags: []string{"quiet", "view"}},
+			[]string{"Tags"}, 0},
+		{"read-only field sent unchanged", mergePatchContentType, `{"ID": 1, "Name": "Chalet"}`,
+			patchTestEntity{ID: 1, CreatedAt: current.CreatedAt, Name: "Chalet", Note: "Late arrival", Tags: []string{"quiet"}},
+			[]string{"Name"}, 0},
+		{"merge patch changes the id", mergePatchContentType, `{"ID": 2}`, patchTestEntity{}, nil, http.StatusUnprocessableEntity},
+		{"merge patch removes a timestamp", mergePatchContentType, `{"CreatedAt": null}`, patchTestEntity{}, nil,
+			http.StatusUnprocessableEntity},
+		{"JSON Patch replaces a timestamp", jsonPatchContentType,
+			`[{"op": "replace", "path": "/CreatedAt", "value": "2020-01-01T00:00:00Z"}]`, patchTestEntity{}, nil,
+			http.StatusUnprocessableEntity},
+		{"JSON Patch moves a value into the id", jsonPatchContentType, `[{"op": "copy", "from": "/Name", "path": "/ID"}]`,
+			patchTestEntity{}, nil, http.StatusUnprocessableEntity},
+		{"failing test op", jsonPatchContentType,
+			`[{"op": "test", "path": "/Name", "value": "Chalet"}, {"op": "replace", "path": "/Name", "value": "Villa"}]`,
+			patchTestEntity{}, nil, http.StatusConflict},
+		{"unknown field", mergePatchContentType, `{"Colour": "blue"}`, patchTestEntity{}, nil, http.StatusUnprocessableEntity},
+		{"plain JSON", "application/json", `{"Name": "Chalet"}`, patchTestEntity{}, nil, http.StatusUnsupportedMediaType},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, changed, err := patchEntity(patchRequest(tc.contentType, tc.body), &current, "ID", "CreatedAt")
+			if tc.status != 0 {
+				w := httptest.NewRecorder()
+				writePatchError(w, err)
+				if w.Code != tc.status {
+					t.Fatalf("err = %v answered %d, want %d", err, w.Code, tc.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("patched = %+v, want %+v", got, tc.want)
+			}
+			slices.Sort(changed)
+			if !slices.Equal(changed, tc.changed) {
+				t.Errorf("changed = %v, want %v", changed, tc.changed)
+			}
+		})
+	}
+	if current.Note != "Late arrival" || len(current.Tags) != 1 {
+		t.Errorf("patches changed the current entity: %+v", current)
+	}
+}
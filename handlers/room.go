@@ -2,19 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"go.mod/models"
 	"go.mod/services"
+	"gorm.io/gorm"
 )
 
 type RoomHandler struct {
 	Service services.RoomService
 }
 
+func NewRoomHandler(service services.RoomService) *RoomHandler {
+	return &RoomHandler{Service: service}
+}
+
 func (h *RoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
 	var id string
@@ -24,6 +31,11 @@ func (h *RoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
+	if strings.HasSuffix(id, restoreSuffix) {
+		restoreEntity(w, r, id, "Room", h.Service.Restore, h.Service.GetByID)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		if id != "" {
@@ -39,6 +51,12 @@ func (h *RoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		} else {
 			http.Error(w, "ID required for update", http.StatusBadRequest)
 		}
+	case http.MethodPatch:
+		if id != "" {
+			h.patchRoom(w, r, id)
+		} else {
+			http.Error(w, "ID required for patch", http.StatusBadRequest)
+		}
 	case http.MethodDelete:
 		if id != "" {
 			h.deleteRoom(w, r, id)
@@ -50,126 +68,223 @@ func (h *RoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *RoomHandler) getAllRooms(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+var roomCSVColumns = []csvColumn[models.Room]{
+	{"id", func(r *models.Room) string { return csvUint(r.ID) }},
+	{"hotel_id", func(r *models.Room) string { return csvUint(r.HotelID) }},
+	{"room_type", func(r *models.Room) string { return csvText(r.RoomType) }},
+	{"room_type_id", func(r *models.Room) string {
+		if r.RoomTypeID == nil {
+			return ""
+		}
+		return csvUint(*r.RoomTypeID)
+	}},
+	{"number", func(r *models.Room) string { return csvText(r.Number) }},
+	{"floor", func(r *models.Room) string { return strconv.Itoa(r.Floor) }},
+	{"status", func(r *models.Room) string { return r.Status }},
+	{"max_occupancy", func(r *models.Room) string { return strconv.Itoa(r.MaxOccupancy) }},
+	{"price", func(r *models.Room) string { return csvFloat(r.Price) }},
+	{"facilities", func(r *models.Room) string { return csvList(r.Facilities) }},
+	{"created_at", func(r *models.Room) string { return csvTime(r.CreatedAt) }},
+}
+
+// roomFilter builds a predicate from the room_type, min_price and max_price query parameters
+func roomFilter(query url.Values) (func(room *models.Room) bool, error) {
 	roomType := query.Get("room_type")
 	minPriceStr := query.Get("min_price")
 	maxPriceStr := query.Get("max_price")
 
-	rooms, err := h.Service.GetAll()
-	if err != nil {
-		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
-		return
+	var minPrice, maxPrice float64
+	var err error
+	if minPriceStr != "" {
+		if minPrice, err = strconv.ParseFloat(minPriceStr, 32); err != nil {
+			return nil, errors.New("Invalid min_price format")
+		}
 	}
-
-	// Фільтрація за типом кімнати
-	if roomType != "" {
-		var filtered []models.Room
-		for _, room := range rooms {
-			if strings.EqualFold(room.RoomType, roomType) {
-				filtered = append(filtered, room)
-			}
+	if maxPriceStr != "" {
+		if maxPrice, err = strconv.ParseFloat(maxPriceStr, 32); err != nil {
+			return nil, errors.New("Invalid max_price format")
 		}
-		rooms = filtered
 	}
 
-	// Фільтрація за мінімальною ціною
-	if minPriceStr != "" {
-		minPrice, err := strconv.ParseFloat(minPriceStr, 32)
-		if err != nil {
-			http.Error(w, "Invalid min_price format", http.StatusBadRequest)
-			return
+	return func(room *models.Room) bool {
+		// Фільтрація за типом кімнати
+		if roomType != "" && !strings.EqualFold(room.RoomType, roomType) {
+			return false
 		}
-		priceValue := float32(minPrice)
-
-		var filtered []models.Room
-		for _, room := range rooms {
-			if room.Price >= priceValue {
-				filtered = append(filtered, room)
-			}
+		// Фільтрація за мінімальною ціною
+		if minPriceStr != "" && room.Price < float32(minPrice) {
+			return false
 		}
-		rooms = filtered
+		// Фільтрація за максимальною ціною
+		if maxPriceStr != "" && room.Price > float32(maxPrice) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func (h *RoomHandler) getAllRooms(w http.ResponseWriter, r *http.Request) {
+	match, err := roomFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Фільтрація за максимальною ціною
-	if maxPriceStr != "" {
-		maxPrice, err := strconv.ParseFloat(maxPriceStr, 32)
-		if err != nil {
-			http.Error(w, "Invalid max_price format", http.StatusBadRequest)
+	if wantsCSV(r) {
+		writeCSV(w, r, "rooms.csv", roomCSVColumns, func(fn func(item *models.Room) error) error {
+			return h.Service.Stream(r.Context(), includeDeleted(r.URL.Query()), fn)
+		}, match)
+		return
+	}
+
+	rooms, err := h.Service.GetAll(r.Context(), includeDeleted(r.URL.Query()))
+	if err != nil {
+		if writeForbidden(w, err) {
 			return
 		}
-		priceValue := float32(maxPrice)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
 
-		var filtered []models.Room
-		for _, room := range rooms {
-			if room.Price <= priceValue {
-				filtered = append(filtered, room)
-			}
+	filtered := make([]models.Room, 0, len(rooms))
+	for i := range rooms {
+		if match(&rooms[i]) {
+			filtered = append(filtered, rooms[i])
 		}
-		rooms = filtered
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rooms)
+	json.NewEncoder(w).Encode(filtered)
 }
 
 func (h *RoomHandler) getRoomByID(w http.ResponseWriter, r *http.Request, id string) {
-	room, found := h.Service.GetByID(id)
-	if !found {
-		http.Error(w, "Room not found", http.StatusNotFound)
+	roomID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid room ID", http.StatusBadRequest)
+		return
+	}
+
+	room, err := h.Service.GetByID(r.Context(), roomID)
+	if err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Room") {
+			return
+		}
+		log.Printf("Error reading room: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
 		return
 	}
 	json.NewEncoder(w).Encode(room)
 }
 
+const roomNumberTaken = "Another room of this hotel already has this number"
+
 func (h *RoomHandler) createRoom(w http.ResponseWriter, r *http.Request) {
 	var newRoom models.Room
-	if err := json.NewDecoder(r.Body).Decode(&newRoom); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &newRoom); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	createdRoom, err := h.Service.Create(newRoom)
-	if err != nil {
+	if err := h.Service.Create(r.Context(), &newRoom); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Room") || writeValidation(w, err) || writeConflict(w, err) ||
+			writeDuplicate(w, err, roomNumberTaken) {
+			return
+		}
 		log.Printf("Error creating room: %v", err)
 		http.Error(w, "Server error during creation", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdRoom)
+	json.NewEncoder(w).Encode(newRoom)
 }
 
 func (h *RoomHandler) updateRoom(w http.ResponseWriter, r *http.Request, id string) {
+	roomID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid room ID", http.StatusBadRequest)
+		return
+	}
+
 	var updatedRoom models.Room
-	if err := json.NewDecoder(r.Body).Decode(&updatedRoom); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &updatedRoom); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
+	updatedRoom.ID = roomID
 
-	resultRoom, found, err := h.Service.Update(id, updatedRoom)
-	if err != nil {
+	if err := h.Service.Update(r.Context(), &updatedRoom); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Room") || writeValidation(w, err) || writeConflict(w, err) ||
+			writeDuplicate(w, err, roomNumberTaken) {
+			return
+		}
 		log.Printf("Error updating room: %v", err)
 		http.Error(w, "Server error during update", http.StatusInternalServerError)
 		return
 	}
-	if !found {
-		http.Error(w, "Room not found", http.StatusNotFound)
+
+	json.NewEncoder(w).Encode(updatedRoom)
+}
+
+// patchRoom applies a merge patch or JSON patch to the stored room and saves only the changed columns
+func (h *RoomHandler) patchRoom(w http.ResponseWriter, r *http.Request, id string) {
+	roomID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid room ID", http.StatusBadRequest)
 		return
 	}
 
-	json.NewEncoder(w).Encode(resultRoom)
+	current, err := h.Service.GetByID(r.Context(), roomID)
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error reading room: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+
+	patched, fields, err := patchEntity(r, &current, "ID", "CreatedAt", "UpdatedAt", "DeletedAt")
+	if err != nil {
+		writePatchError(w, err)
+		return
+	}
+
+	if err := h.Service.Patch(r.Context(), &patched, fields); err != nil {
+		if writeForbidden(w, err) || writeDuplicate(w, err, roomNumberTaken) {
+			return
+		}
+		var validationErr *services.ValidationError
+		if errors.As(err, &validationErr) {
+			http.Error(w, validationErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("Error patching room: %v", err)
+		http.Error(w, "Server error during update", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(patched)
 }
 
 func (h *RoomHandler) deleteRoom(w http.ResponseWriter, r *http.Request, id string) {
-	found, err := h.Service.Delete(id)
+	roomID, err := parseID(id)
 	if err != nil {
-		log.Printf("Error deleting room: %v", err)
-		http.Error(w, "Server error during deletion", http.StatusInternalServerError)
+		http.Error(w, "Invalid room ID", http.StatusBadRequest)
 		return
 	}
-	if !found {
-		http.Error(w, "Room not found", http.StatusNotFound)
+	cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+
+	if err := h.Service.Delete(r.Context(), roomID, cascade); err != nil {
+		if writeForbidden(w, err) || writeNotFound(w, err, "Room") || writeDeleteBlocked(w, err) || writeConflict(w, err) {
+			return
+		}
+		log.Printf("Error deleting room: %v", err)
+		http.Error(w, "Server error during deletion", http.StatusInternalServerError)
 		return
 	}
 
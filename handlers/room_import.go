@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+// importRooms handles POST /hotels/{id}/rooms:import with a CSV file or a JSON array of rooms
+func (h *HotelHandler) importRooms(w http.ResponseWriter, r *http.Request, id string) {
+	hotelID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		http.Error(w, "Invalid hotel ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Service.GetByID(r.Context(), uint(hotelID)); err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Hotel not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error reading hotel: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var rows []services.RoomImportRow
+	switch mediaType {
+	case "text/csv":
+		rows, err = parseRoomsCSV(r.Body)
+	case "application/json":
+		rows, err = parseRoomsJSON(r.Body)
+	default:
+		http.Error(w, "Content-Type must be text/csv or application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	result, err := h.RoomService.Import(r.Context(), uint(hotelID), rows, dryRun)
+	if err != nil {
+		// Номер зайняли між перевіркою та вставкою
+		if writeForbidden(w, err) || writeDuplicate(w, err, roomNumberTaken) {
+			return
+		}
+		log.Printf("Error importing rooms: %v", err)
+		http.Error(w, "Server error during import", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case result.Failed > 0 && !dryRun:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case result.Created > 0:
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseRoomsCSV expects a header with room_type, price and optionally facilities ("a; b; c"), number, floor, status and max_occupancy
+func parseRoomsCSV(r io.Reader) ([]services.RoomImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, utf8BOM)))] = i
+	}
+	for _, required := range []string{"room_type", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header must contain %q", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []services.RoomImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, services.RoomImportRow{Line: parseErr.StartLine, ParseError: parseErr.Err.Error()})
+				continue
+			}
+			return nil, err
+		}
+
+		// Рядок файлу, а не номер запису: поле в лапках може займати кілька рядків
+		line, _ := reader.FieldPos(0)
+		row := services.RoomImportRow{Line: line}
+		row.Room.RoomType = field(record, "room_type")
+
+		if priceStr := field(record, "price"); priceStr != "" {
+			price, err := strconv.ParseFloat(priceStr, 32)
+			if err != nil {
+				row.ParseError = fmt.Sprintf("invalid price %q", priceStr)
+			}
+			row.Room.Price = float32(price)
+		}
+
+		row.Room.Number = field(record, "number")
+		row.Room.Status = field(record, "status")
+		if floorStr := field(record, "floor"); floorStr != "" {
+			floor, err := strconv.Atoi(floorStr)
+			if err != nil {
+				row.ParseError = fmt.Sprintf("invalid floor %q", floorStr)
+			}
+			row.Room.Floor = floor
+		}
+
+		if occupancyStr := field(record, "max_occupancy"); occupancyStr != "" {
+			occupancy, err := strconv.Atoi(occupancyStr)
+			if err != nil {
+				row.ParseError = fmt.Sprintf("invalid max_occupancy %q", occupancyStr)
+			}
+			row.Room.MaxOccupancy = occupancy
+		}
+
+		row.Room.Facilities = models.StringSlice{}
+		if facilities := field(record, "facilities"); facilities != "" {
+			for _, facility := range strings.Split(facilities, ";") {
+				row.Room.Facilities = append(row.Room.Facilities, strings.TrimSpace(facility))
+			}
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseRoomsJSON reads an array of rooms; elements that don't decode are reported per row
+func parseRoomsJSON(r io.Reader) ([]services.RoomImportRow, error) {
+	var raw []json.RawMessage
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errTrailingData
+	}
+
+	rows := make([]services.RoomImportRow, 0, len(raw))
+	for i, item := range raw {
+		row := services.RoomImportRow{Line: i + 1}
+		if err := decodeStrict(item, &row.Room); err != nil {
+			row.ParseError = err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRoomsCSVReportsFileLines(t *testing.T) {
+	csv := "room_type,price,facilities,number\n" +
+		"Double,120,\"wifi;\nbalcony\",101\n" +
+		"Double,abc,,102\n" +
+		"Double,120,\"wifi\"x,103\n" +
+		"Single,80,,104\n"
+
+	rows, err := parseRoomsCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		line       int
+		parseError bool
+	}{{2, false}, {4, true}, {5, true}, {6, false}}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, w := range want {
+		if rows[i].Line != w.line || (rows[i].ParseError != "") != w.parseError {
+			t.Errorf("row %d = line %d, parse error %q; want line %d, parse error %v", i, rows[i].Line, rows[i].ParseError, w.line, w.parseError)
+		}
+	}
+	if got := rows[0].Room.Facilities; len(got) != 2 || got[1] != "balcony" {
+		t.Errorf("facilities = %v, want the two split across lines", got)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mod/models"
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+// takenNumberRooms refuses every write the way the unique room number index does
+type takenNumberRooms struct {
+	services.RoomService
+}
+
+func (takenNumberRooms) Create(ctx context.Context, room *models.Room) error {
+	return gorm.ErrDuplicatedKey
+}
+
+func (takenNumberRooms) Update(ctx context.Context, room *models.Room) error {
+	return gorm.ErrDuplicatedKey
+}
+
+func TestRoomNumberTakenIsConflict(t *testing.T) {
+	handler := NewRoomHandler(takenNumberRooms{})
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		path := "/rooms"
+		if method == http.MethodPut {
+			path = "/rooms/3"
+		}
+		r := httptest.NewRequest(method, path, strings.NewReader(`{"HotelID": 1, "Number": "101", "RoomType": "Double", "Price": 120}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), roomNumberTaken) {
+			t.Errorf("%s %s = %d %q, want 409", method, path, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+type RoomTypeHandler struct {
+	Service services.RoomTypeService
+}
+
+func NewRoomTypeHandler(service services.RoomTypeService) *RoomTypeHandler {
+	return &RoomTypeHandler{Service: service}
+}
+
+func (h *RoomTypeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var id string
+	if len(pathSegments) == 2 && pathSegments[0] == "room-types" {
+		id = pathSegments[1]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if id != "" {
+			h.getRoomTypeByID(w, r, id)
+		} else {
+			h.getAllRoomTypes(w, r)
+		}
+	case http.MethodPost:
+		h.createRoomType(w, r)
+	case http.MethodPut:
+		if id != "" {
+			h.updateRoomType(w, r, id)
+		} else {
+			http.Error(w, "ID required for update", http.StatusBadRequest)
+		}
+	case http.MethodDelete:
+		if id != "" {
+			h.deleteRoomType(w, r, id)
+		} else {
+			http.Error(w, "ID required for delete", http.StatusBadRequest)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeRoomTypeError maps service errors of the room type endpoints to HTTP statuses
+func writeRoomTypeError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case writeForbidden(w, err), writeValidation(w, err), writeDeleteBlocked(w, err):
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Room type not found", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrRoomTypeInUse):
+		http.Error(w, "The room type still has rooms; delete them or move them to another type first", http.StatusConflict)
+	default:
+		log.Printf("Error %s room type: %v", action, err)
+		http.Error(w, "Server error during "+action, http.StatusInternalServerError)
+	}
+}
+
+// getAllRoomTypes lists the room types, optionally of one hotel with ?hotel_id=
+func (h *RoomTypeHandler) getAllRoomTypes(w http.ResponseWriter, r *http.Request) {
+	var hotelID uint64
+	if hotelIDStr := r.URL.Query().Get("hotel_id"); hotelIDStr != "" {
+		var err error
+		if hotelID, err = strconv.ParseUint(hotelIDStr, 10, 0); err != nil {
+			http.Error(w, "Invalid hotel_id format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	roomTypes, err := h.Service.GetAll(r.Context(), includeDeleted(r.URL.Query()))
+	if err != nil {
+		writeRoomTypeError(w, err, "reading")
+		return
+	}
+
+	filtered := make([]models.RoomType, 0, len(roomTypes))
+	for _, roomType := range roomTypes {
+		if hotelID == 0 || uint64(roomType.HotelID) == hotelID {
+			filtered = append(filtered, roomType)
+		}
+	}
+	json.NewEncoder(w).Encode(filtered)
+}
+
+func (h *RoomTypeHandler) getRoomTypeByID(w http.ResponseWriter, r *http.Request, id string) {
+	roomTypeID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid room type ID", http.StatusBadRequest)
+		return
+	}
+
+	roomType, err := h.Service.GetByID(r.Context(), roomTypeID)
+	if err != nil {
+		writeRoomTypeError(w, err, "reading")
+		return
+	}
+	json.NewEncoder(w).Encode(roomType)
+}
+
+func (h *RoomTypeHandler) createRoomType(w http.ResponseWriter, r *http.Request) {
+	var roomType models.RoomType
+	if err := decodeJSON(r, &roomType); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if err := h.Service.Create(r.Context(), &roomType); err != nil {
+		writeRoomTypeError(w, err, "creating")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(roomType)
+}
+
+func (h *RoomTypeHandler) updateRoomType(w http.ResponseWriter, r *http.Request, id string) {
+	roomTypeID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid room type ID", http.StatusBadRequest)
+		return
+	}
+
+	var roomType models.RoomType
+	if err := decodeJSON(r, &roomType); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	roomType.ID = roomTypeID
+
+	if err := h.Service.Update(r.Context(), &roomType); err != nil {
+		writeRoomTypeError(w, err, "updating")
+		return
+	}
+	json.NewEncoder(w).Encode(roomType)
+}
+
+func (h *RoomTypeHandler) deleteRoomType(w http.ResponseWriter, r *http.Request, id string) {
+	roomTypeID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid room type ID", http.StatusBadRequest)
+		return
+	}
+
+	cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+	if err := h.Service.Delete(r.Context(), roomTypeID, cascade); err != nil {
+		writeRoomTypeError(w, err, "deleting")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+type TaxRuleHandler struct {
+	Service services.TaxRuleService
+}
+
+func NewTaxRuleHandler(service services.TaxRuleService) *TaxRuleHandler {
+	return &TaxRuleHandler{Service: service}
+}
+
+func (h *TaxRuleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var id string
+	if len(pathSegments) == 2 && pathSegments[0] == "tax-rules" {
+		id = pathSegments[1]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if id != "" {
+			h.getTaxRuleByID(w, r, id)
+		} else {
+			h.getAllTaxRules(w, r)
+		}
+	case http.MethodPost:
+		h.createTaxRule(w, r)
+	case http.MethodPut:
+		if id != "" {
+			h.updateTaxRule(w, r, id)
+		} else {
+			http.Error(w, "ID required for update", http.StatusBadRequest)
+		}
+	case http.MethodDelete:
+		if id != "" {
+			h.deleteTaxRule(w, r, id)
+		} else {
+			http.Error(w, "ID required for delete", http.StatusBadRequest)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeTaxRuleError maps service errors of the tax rule endpoints to HTTP statuses
+func writeTaxRuleError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case writeForbidden(w, err), writeValidation(w, err):
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Tax rule not found", http.StatusNotFound)
+	default:
+		log.Printf("Error %s tax rule: %v", action, err)
+		http.Error(w, "Server error during "+action, http.StatusInternalServerError)
+	}
+}
+
+// getAllTaxRules lists the tax rules, optionally of one hotel with ?hotel_id=
+func (h *TaxRuleHandler) getAllTaxRules(w http.ResponseWriter, r *http.Request) {
+	var hotelID uint64
+	if hotelIDStr := r.URL.Query().Get("hotel_id"); hotelIDStr != "" {
+		var err error
+		if hotelID, err = strconv.ParseUint(hotelIDStr, 10, 0); err != nil {
+			http.Error(w, "Invalid hotel_id format", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rules, err := h.Service.GetAll(r.Context(), includeDeleted(r.URL.Query()))
+	if err != nil {
+		writeTaxRuleError(w, err, "reading")
+		return
+	}
+
+	filtered := make([]models.TaxRule, 0, len(rules))
+	for _, rule := range rules {
+		if hotelID == 0 || uint64(rule.HotelID) == hotelID {
+			filtered = append(filtered, rule)
+		}
+	}
+	json.NewEncoder(w).Encode(filtered)
+}
+
+func (h *TaxRuleHandler) getTaxRuleByID(w http.ResponseWriter, r *http.Request, id string) {
+	ruleID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid tax rule ID", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.Service.GetByID(r.Context(), ruleID)
+	if err != nil {
+		writeTaxRuleError(w, err, "reading")
+		return
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *TaxRuleHandler) createTaxRule(w http.ResponseWriter, r *http.Request) {
+	var rule models.TaxRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if err := h.Service.Create(r.Context(), &rule); err != nil {
+		writeTaxRuleError(w, err, "creating")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *TaxRuleHandler) updateTaxRule(w http.ResponseWriter, r *http.Request, id string) {
+	ruleID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid tax rule ID", http.StatusBadRequest)
+		return
+	}
+
+	var rule models.TaxRule
+	if err := decodeJSON(r, &rule); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	rule.ID = ruleID
+
+	if err := h.Service.Update(r.Context(), &rule); err != nil {
+		writeTaxRuleError(w, err, "updating")
+		return
+	}
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (h *TaxRuleHandler) deleteTaxRule(w http.ResponseWriter, r *http.Request, id string) {
+	ruleID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid tax rule ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Delete(r.Context(), ruleID); err != nil {
+		writeTaxRuleError(w, err, "deleting")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
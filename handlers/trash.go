@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+const restoreSuffix = ":restore"
+
+// includeDeleted reads ?include_deleted=true; soft-deleted items then come back with DeletedAt set
+func includeDeleted(query url.Values) bool {
+	value, _ := strconv.ParseBool(query.Get("include_deleted"))
+	return value
+}
+
+// restoreEntity handles POST /{resource}/{id}:restore and answers with the restored entity
+func restoreEntity[T any](w http.ResponseWriter, r *http.Request, id, entity string,
+	restore func(ctx context.Context, id uint) error, get func(ctx context.Context, id uint) (T, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entityID, err := parseID(strings.TrimSuffix(id, restoreSuffix))
+	if err != nil {
+		http.Error(w, "Invalid "+strings.ToLower(entity)+" ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := restore(r.Context(), entityID); err != nil {
+		writeTrashError(w, err, entity, "restore")
+		return
+	}
+
+	restored, err := get(r.Context(), entityID)
+	if err != nil {
+		log.Printf("Error reading restored %s: %v", strings.ToLower(entity), err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(restored)
+}
+
+func writeTrashError(w http.ResponseWriter, err error, entity, action string) {
+	switch {
+	case writeForbidden(w, err):
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, entity+" not found in trash", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrParentDeleted):
+		http.Error(w, "Restore the hotel, room type or guest this "+strings.ToLower(entity)+" belongs to first", http.StatusConflict)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		http.Error(w, "Another "+strings.ToLower(entity)+" now uses the number or name of this one; change one of them first", http.StatusConflict)
+	case errors.Is(err, repositories.ErrInvoiced):
+		http.Error(w, "Invoices are kept for good, so bookings with an invoice can't be purged", http.StatusConflict)
+	default:
+		log.Printf("Error during %s of %s: %v", action, strings.ToLower(entity), err)
+		http.Error(w, "Server error during "+action, http.StatusInternalServerError)
+	}
+}
+
+// trashContents is the body of GET /trash; with ?entity= only that list is filled
+type trashContents struct {
+	Hotels   []models.Hotel   `json:"hotels,omitempty"`
+	Rooms    []models.Room    `json:"rooms,omitempty"`
+	Guests   []models.Guest   `json:"guests,omitempty"`
+	Bookings []models.Booking `json:"bookings,omitempty"`
+}
+
+type TrashHandler struct {
+	HotelService   services.HotelService
+	RoomService    services.RoomService
+	GuestService   services.GuestService
+	BookingService services.BookingService
+}
+
+func NewTrashHandler(hotels services.HotelService, rooms services.RoomService,
+	guests services.GuestService, bookings services.BookingService) *TrashHandler {
+	return &TrashHandler{HotelService: hotels, RoomService: rooms, GuestService: guests, BookingService: bookings}
+}
+
+// ServeHTTP handles GET /trash?entity=rooms and DELETE /trash/{entity}/{id}, the admin-only hard purge
+func (h *TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == http.MethodGet && len(pathSegments) == 1:
+		h.listTrash(w, r)
+	case r.Method == http.MethodDelete && len(pathSegments) == 3:
+		h.purge(w, r, pathSegments[1], pathSegments[2])
+	case len(pathSegments) == 1 || len(pathSegments) == 3:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (h *TrashHandler) listTrash(w http.ResponseWriter, r *http.Request) {
+	entity := r.URL.Query().Get("entity")
+	var trash trashContents
+	var err error
+
+	switch entity {
+	case "":
+		if trash.Hotels, err = h.HotelService.GetDeleted(r.Context()); err != nil {
+			break
+		}
+		if trash.Rooms, err = h.RoomService.GetDeleted(r.Context()); err != nil {
+			break
+		}
+		if trash.Guests, err = h.GuestService.GetDeleted(r.Context()); err != nil {
+			break
+		}
+		trash.Bookings, err = h.BookingService.GetDeleted(r.Context())
+	case "hotels":
+		trash.Hotels, err = h.HotelService.GetDeleted(r.Context())
+	case "rooms":
+		trash.Rooms, err = h.RoomService.GetDeleted(r.Context())
+	case "guests":
+		trash.Guests, err = h.GuestService.GetDeleted(r.Context())
+	case "bookings":
+		trash.Bookings, err = h.BookingService.GetDeleted(r.Context())
+	default:
+		http.Error(w, "entity must be one of hotels, rooms, guests, bookings", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		if writeForbidden(w, err) {
+			return
+		}
+		log.Printf("Error reading trash: %v", err)
+		http.Error(w, "Internal server error reading data", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(trash)
+}
+
+func (h *TrashHandler) purge(w http.ResponseWriter, r *http.Request, entity, id string) {
+	var purge func(ctx context.Context, id uint) error
+	var name string
+	switch entity {
+	case "hotels":
+		purge, name = h.HotelService.Purge, "Hotel"
+	case "rooms":
+		purge, name = h.RoomService.Purge, "Room"
+	case "guests":
+		purge, name = h.GuestService.Purge, "Guest"
+	case "bookings":
+		purge, name = h.BookingService.Purge, "Booking"
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	entityID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid "+strings.ToLower(name)+" ID", http.StatusBadRequest)
+		return
+	}
+	if err := purge(r.Context(), entityID); err != nil {
+		writeTrashError(w, err, name, "purge")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
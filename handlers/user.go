@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"go.mod/services"
+	"gorm.io/gorm"
+)
+
+type UserHandler struct {
+	Service services.UserService
+}
+
+func NewUserHandler(service services.UserService) *UserHandler {
+	return &UserHandler{Service: service}
+}
+
+func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pathSegments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var id string
+	if len(pathSegments) == 2 && pathSegments[0] == "users" {
+		id = pathSegments[1]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		if id != "" {
+			h.getUserByID(w, r, id)
+		} else {
+			h.getAllUsers(w, r)
+		}
+	case http.MethodPost:
+		h.createUser(w, r)
+	case http.MethodPut:
+		if id != "" {
+			h.updateUser(w, r, id)
+		} else {
+			http.Error(w, "ID required for update", http.StatusBadRequest)
+		}
+	case http.MethodDelete:
+		if id != "" {
+			h.deleteUser(w, r, id)
+		} else {
+			http.Error(w, "ID required for delete", http.StatusBadRequest)
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeUserError maps service errors of the user endpoints to HTTP statuses
+func writeUserError(w http.ResponseWriter, err error, action string) {
+	var validationErr *services.ValidationError
+	switch {
+	case writeForbidden(w, err):
+	case errors.As(err, &validationErr):
+		http.Error(w, validationErr.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		http.Error(w, "Username already exists", http.StatusConflict)
+	default:
+		log.Printf("Error %s user: %v", action, err)
+		http.Error(w, "Server error during "+action, http.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) getAllUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.Service.GetAll(r.Context())
+	if err != nil {
+		writeUserError(w, err, "reading")
+		return
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+func (h *UserHandler) getUserByID(w http.ResponseWriter, r *http.Request, id string) {
+	userID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Service.GetByID(r.Context(), userID)
+	if err != nil {
+		writeUserError(w, err, "reading")
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
+	var input services.UserInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	user, err := h.Service.Create(r.Context(), input)
+	if err != nil {
+		writeUserError(w, err, "creating")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) updateUser(w http.ResponseWriter, r *http.Request, id string) {
+	userID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var input services.UserInput
+	if err := decodeJSON(r, &input); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	user, err := h.Service.Update(r.Context(), userID, input)
+	if err != nil {
+		writeUserError(w, err, "updating")
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *UserHandler) deleteUser(w http.ResponseWriter, r *http.Request, id string) {
+	userID, err := parseID(id)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Delete(r.Context(), userID); err != nil {
+		writeUserError(w, err, "deleting")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
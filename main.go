@@ -1,57 +1,308 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	// Часові пояси готелів не залежать від бази поясів на сервері
+	_ "time/tzdata"
 
+	"go.mod/docs"
 	"go.mod/handlers"
 	"go.mod/middlewares"
 	"go.mod/repositories"
+	"go.mod/security"
 	"go.mod/services"
 )
 
 func main() {
 
+	// Хешування не потребує бази даних
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		runHashPassword(os.Args[2:])
+		return
+	}
+
 	repositories.InitDB()
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
 
-	hotelRepo := repositories.NewHotelRepository(repositories.DB)
-	hotelService := services.NewHotelService(hotelRepo)
-	hotelHandler := handlers.NewHotelHandler(hotelService)
+	rateLimits, err := middlewares.LoadRateLimitConfig()
+	if err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+	rateLimitStore := middlewares.NewMemoryRateLimitStore()
+	clientRateLimit := middlewares.ClientRateLimitMiddleware(rateLimitStore, rateLimits)
+	rateLimit := middlewares.RateLimitMiddleware(rateLimitStore, rateLimits)
+
+	idempotencyTTL, err := middlewares.IdempotencyTTL()
+	if err != nil {
+		log.Fatalf("Invalid idempotency configuration: %v", err)
+	}
+	idempotency := middlewares.IdempotencyMiddleware(repositories.NewIdempotencyRepository(repositories.DB), idempotencyTTL)
+
+	tlsConfig, err := security.LoadTLSConfig()
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
+	if tlsConfig != nil {
+		for subject, mapping := range tlsConfig.ClientIdentities {
+			for _, role := range mapping.Roles {
+				if !services.IsValidRole(role) {
+					log.Fatalf("Invalid TLS configuration: %q has unknown role %q", subject, role)
+				}
+			}
+		}
+	}
+
+	jwtConfig, err := security.LoadJWTConfig()
+	if err != nil {
+		log.Fatalf("Invalid JWT configuration: %v", err)
+	}
+	authenticators := []middlewares.Authenticator{middlewares.NewAPIKeyAuthenticator()}
+	if jwtConfig != nil {
+		authenticators = append(authenticators, &middlewares.JWTAuthenticator{Verifier: jwtConfig.Verifier})
+	}
+	auth := middlewares.AuthMiddleware(authenticators...)
+
+	auditHandler := handlers.NewAuditHandler(services.NewAuditService(repositories.NewAuditRepository(repositories.DB)))
+
+	userRepo := repositories.NewUserRepository(repositories.DB)
+	userHandler := handlers.NewUserHandler(services.NewUserService(userRepo))
+
+	// Файл облікових записів лишається для розгортань без таблиці users
+	staff := services.NewUserStaffStore(userRepo)
+	if path := os.Getenv("GO_API_STAFF_FILE"); path != "" {
+		if staff, err = services.LoadStaffFile(path); err != nil {
+			log.Fatalf("Failed to load staff accounts: %v", err)
+		}
+	}
+	authHandler := handlers.NewAuthHandler(services.NewAuthService(staff, jwtConfig))
+
+	corsConfig, err := middlewares.LoadCORSConfig()
+	if err != nil {
+		log.Fatalf("Invalid CORS configuration: %v", err)
+	}
+	cors := middlewares.CORSMiddleware(corsConfig)
+
+	route := func(h http.Handler, maxBody int64, contentTypes []string) http.Handler {
+		return middlewares.Chain(h,
+			middlewares.RequestIDMiddleware,
+			middlewares.RecoveryMiddleware,
+			middlewares.LoggingMiddleware,
+			middlewares.SecurityHeadersMiddleware(""),
+			cors,
+			middlewares.ClientCertMiddleware(tlsConfig),
+			clientRateLimit,
+			auth,
+			rateLimit,
+			middlewares.BodyLimitMiddleware(maxBody),
+			middlewares.ContentTypeMiddleware(contentTypes...),
+			idempotency,
+		)
+	}
+
+	notifier := services.LogNotifier{}
+	// Платіжний шлюз ще не підключено, тож картки обробляє локальна заглушка
+	paymentProvider := services.NewFakePaymentProvider()
+
+	taxRuleRepo := repositories.NewTaxRuleRepository(repositories.DB)
+	taxRuleService := services.NewTaxRuleService(taxRuleRepo)
+	taxRuleHandler := handlers.NewTaxRuleHandler(taxRuleService)
+
+	roomTypeRepo := repositories.NewRoomTypeRepository(repositories.DB)
+	roomTypeService := services.NewRoomTypeService(roomTypeRepo, taxRuleRepo, notifier)
+	roomTypeHandler := handlers.NewRoomTypeHandler(roomTypeService)
 
 	roomRepo := repositories.NewRoomRepository(repositories.DB)
-	roomService := services.NewRoomService(roomRepo)
+	roomService := services.NewRoomService(roomRepo, roomTypeRepo, notifier)
 	roomHandler := handlers.NewRoomHandler(roomService)
 
+	policyRepo := repositories.NewCancellationPolicyRepository(repositories.DB)
+	policyService := services.NewCancellationPolicyService(policyRepo)
+	policyHandler := handlers.NewCancellationPolicyHandler(policyService)
+
+	hotelRepo := repositories.NewHotelRepository(repositories.DB)
+	hotelService := services.NewHotelService(hotelRepo, policyRepo, notifier)
+	hotelHandler := handlers.NewHotelHandler(hotelService, roomService, roomTypeService)
+
 	guestRepo := repositories.NewGuestRepository(repositories.DB)
-	guestService := services.NewGuestService(guestRepo)
+	guestService := services.NewGuestService(guestRepo, notifier)
 	guestHandler := handlers.NewGuestHandler(guestService)
 
 	bookingRepo := repositories.NewBookingRepository(repositories.DB)
-	bookingService := services.NewBookingService(bookingRepo)
-	bookingHandler := handlers.NewBookingHandler(bookingService)
-
+	paymentRepo := repositories.NewPaymentRepository(repositories.DB)
+	paymentService := services.NewPaymentService(paymentRepo, bookingRepo, paymentProvider)
+	invoiceRepo := repositories.NewInvoiceRepository(repositories.DB)
+	invoiceService := services.NewInvoiceService(invoiceRepo, bookingRepo, paymentRepo)
+	bookingService := services.NewBookingService(bookingRepo, policyRepo, invoiceService)
+	bookingHandler := handlers.NewBookingHandler(bookingService, paymentService, invoiceService)
 
-	http.Handle("/hotels", route(hotelHandler))
-	http.Handle("/hotels/", route(hotelHandler))
+	nightAudit := services.NewNightAuditService(hotelRepo, bookingRepo, policyRepo)
+	go nightAudit.Run(nil)
 
-	http.Handle("/rooms", route(roomHandler))
-	http.Handle("/rooms/", route(roomHandler))
+	searchService := services.NewSearchService(services.NewMemoryIndex(), hotelRepo, guestRepo, bookingRepo,
+		repositories.NewAuditRepository(repositories.DB))
+	go searchService.Run(nil)
+	searchHandler := handlers.NewSearchHandler(searchService)
 
-	http.Handle("/guests", route(guestHandler))
-	http.Handle("/guests/", route(guestHandler))
+	trashHandler := handlers.NewTrashHandler(hotelService, roomService, guestService, bookingService)
 
-	http.Handle("/bookings", route(bookingHandler))
-	http.Handle("/bookings/", route(bookingHandler))
+	patterns := registerRoutes(http.DefaultServeMux, apiHandlers{
+		hotels: hotelHandler, rooms: roomHandler, roomTypes: roomTypeHandler, policies: policyHandler,
+		taxRules: taxRuleHandler, guests: guestHandler, bookings: bookingHandler, users: userHandler,
+		search: searchHandler, audit: auditHandler, trash: trashHandler, auth: authHandler,
+	}, routeWrappers{
+		api: route,
+		token: func(h http.Handler) http.Handler {
+			return middlewares.Chain(h,
+				middlewares.RequestIDMiddleware,
+				middlewares.RecoveryMiddleware,
+				middlewares.LoggingMiddleware,
+				middlewares.SecurityHeadersMiddleware(""),
+				cors,
+				clientRateLimit,
+				middlewares.BodyLimitMiddleware(defaultBodyLimit),
+				middlewares.ContentTypeMiddleware("application/json"),
+			)
+		},
+		spec: func(h http.Handler) http.Handler {
+			return middlewares.Chain(h,
+				middlewares.RequestIDMiddleware,
+				middlewares.RecoveryMiddleware,
+				middlewares.LoggingMiddleware,
+				middlewares.SecurityHeadersMiddleware(""),
+				cors,
+			)
+		},
+		ui: func(h http.Handler) http.Handler {
+			return middlewares.Chain(h,
+				middlewares.RequestIDMiddleware,
+				middlewares.RecoveryMiddleware,
+				middlewares.LoggingMiddleware,
+				middlewares.SecurityHeadersMiddleware(docs.ContentSecurityPolicy),
+			)
+		},
+	})
 
+	if err := docs.VerifyRoutes(http.DefaultServeMux, patterns); err != nil {
+		log.Fatal(err)
+	}
 
 	port := ":8080"
-	log.Printf("Сервер REST API запущено на http://localhost%s", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	if tlsConfig == nil {
+		log.Printf("Сервер REST API запущено на http://localhost%s", port)
+		log.Fatal(http.ListenAndServe(port, nil))
+	}
+
+	reloader, err := security.NewCertReloader(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load TLS certificate: %v", err)
+	}
+	go reloader.Watch(nil)
+
+	serverTLS, err := tlsConfig.ServerTLSConfig(reloader)
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
+	server := &http.Server{Addr: port, TLSConfig: serverTLS}
+
+	log.Printf("Сервер REST API запущено на https://localhost%s", port)
+	log.Fatal(server.ListenAndServeTLS("", ""))
+}
+
+// runExport: go run . export -format ndjson|tar.gz -out backup.ndjson
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", repositories.BackupFormatNDJSON, "ndjson or tar.gz")
+	out := fs.String("out", "", "output file (stdout if empty)")
+	fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var err error
+	switch *format {
+	case repositories.BackupFormatNDJSON:
+		err = repositories.ExportNDJSON(repositories.DB, w)
+	case repositories.BackupFormatTarGz:
+		err = repositories.ExportBundle(repositories.DB, w)
+	default:
+		log.Fatalf("Unknown export format %q", *format)
+	}
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	log.Println("Export completed.")
+}
+
+// runRestore: go run . restore -in backup.tar.gz
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := fs.String("in", "", "backup file")
+	format := fs.String("format", "", "ndjson or tar.gz (detected from the file name if empty)")
+	fs.Parse(args)
+
+	if *in == "" {
+		log.Fatal("restore requires -in")
+	}
+	if *format == "" {
+		*format = repositories.BackupFormatNDJSON
+		if strings.HasSuffix(*in, ".tar.gz") || strings.HasSuffix(*in, ".tgz") {
+			*format = repositories.BackupFormatTarGz
+		}
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *in, err)
+	}
+	defer f.Close()
+
+	switch *format {
+	case repositories.BackupFormatNDJSON:
+		err = repositories.RestoreNDJSON(repositories.DB, f)
+	case repositories.BackupFormatTarGz:
+		err = repositories.RestoreBundle(repositories.DB, f)
+	default:
+		log.Fatalf("Unknown restore format %q", *format)
+	}
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+	log.Println("Restore completed.")
 }
 
-func route(h http.Handler) http.Handler {
-	return middlewares.LoggingMiddleware(
-		middlewares.AuthMiddleware(h),
-	)
+// runHashPassword prints the hash and salt for a staff account or API key: go run . hash-password <secret>
+func runHashPassword(args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: hash-password <secret>")
+	}
+	hash, salt, err := security.HashSecret(args[0])
+	if err != nil {
+		log.Fatalf("Failed to hash secret: %v", err)
+	}
+	fmt.Printf("hash: %s\nsalt: %s\n", hash, salt)
 }
@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.mod/models"
+	"go.mod/security"
+)
+
+// Authenticator is one way of proving who the caller is.
+// ok is false when the request carries no credentials of this kind, so the next strategy is tried;
+// an error means credentials were present but wrong.
+type Authenticator interface {
+	Authenticate(r *http.Request) (identity security.Identity, ok bool, err error)
+}
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// APIKeyAuthenticator checks X-API-Key against the shared secret hash; the shared key has admin rights
+type APIKeyAuthenticator struct {
+	secretHash string
+	secretSalt string
+}
+
+func NewAPIKeyAuthenticator() *APIKeyAuthenticator {
+	secretHash := os.Getenv("GO_API_SECRET_HASH")
+	secretSalt := os.Getenv("GO_API_SECRET_SALT")
+	if secretHash == "" || secretSalt == "" {
+		log.Fatal("Environment variables GO_API_SECRET_HASH or GO_API_SECRET_SALT are not set.")
+	}
+	return &APIKeyAuthenticator{secretHash: secretHash, secretSalt: secretSalt}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (security.Identity, bool, error) {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		return security.Identity{}, false, nil
+	}
+	if !security.CompareHash(apiKey, a.secretHash, a.secretSalt) {
+		return security.Identity{}, true, errInvalidCredentials
+	}
+	return security.Identity{Method: security.AuthMethodAPIKey, Name: security.KeyID(apiKey), Roles: []string{models.RoleAdmin}}, true, nil
+}
+
+// JWTAuthenticator accepts "Authorization: Bearer <token>"
+type JWTAuthenticator struct {
+	Verifier *security.JWTVerifier
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (security.Identity, bool, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return security.Identity{}, false, nil
+	}
+
+	claims, err := a.Verifier.Verify(strings.TrimSpace(token), time.Now())
+	if err != nil {
+		return security.Identity{}, true, err
+	}
+	return security.Identity{
+		Method:   security.AuthMethodJWT,
+		Name:     claims.Subject,
+		Roles:    claims.Roles,
+		HotelIDs: claims.HotelIDs,
+	}, true, nil
+}
+
+// AuthMiddleware tries each authenticator in order and rejects the request if none accepts it.
+// Callers already identified by ClientCertMiddleware pass straight through.
+func AuthMiddleware(authenticators ...Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Клієнт уже автентифікований сертифікатом
+			if _, ok := security.IdentityFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, authenticator := range authenticators {
+				identity, ok, err := authenticator.Authenticate(r)
+				if !ok {
+					continue
+				}
+				if err != nil {
+					if _, isBearer := authenticator.(*JWTAuthenticator); isBearer {
+						w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+						http.Error(w, "Unauthorized", http.StatusUnauthorized)
+						return
+					}
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(security.WithIdentity(r.Context(), identity)))
+				return
+			}
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"go.mod/security"
+)
+
+// ClientCertMiddleware authenticates callers that present a verified client certificate
+// whose subject is listed in the TLS config, with the roles and hotels of its mapping.
+// Others fall through to AuthMiddleware.
+func ClientCertMiddleware(config *security.TLSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if identity, ok := config.ClientCertIdentity(r.TLS); ok {
+				r = r.WithContext(security.WithIdentity(r.Context(), identity))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.mod/models"
+	"go.mod/security"
+)
+
+func TestClientCertMiddlewareTakesRolesFromMapping(t *testing.T) {
+	config := &security.TLSConfig{ClientIdentities: map[string]security.ClientCertMapping{
+		"CN=partner-a,O=Acme": {Name: "partner-a", Roles: []string{models.RoleReceptionist}, HotelIDs: []uint{3}},
+	}}
+	verified := func(subject pkix.Name) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: subject}}}}
+	}
+
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  *security.Identity
+	}{
+		{"mapped certificate", verified(pkix.Name{CommonName: "partner-a", Organization: []string{"Acme"}}),
+			&security.Identity{Method: security.AuthMethodClientCert, Name: "partner-a", Roles: []string{models.RoleReceptionist}, HotelIDs: []uint{3}}},
+		{"unmapped certificate", verified(pkix.Name{CommonName: "partner-b"}), nil},
+		{"no certificate", &tls.ConnectionState{}, nil},
+		{"plain HTTP", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *security.Identity
+			handler := ClientCertMiddleware(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if identity, ok := security.IdentityFromContext(r.Context()); ok {
+					got = &identity
+				}
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/hotels", nil)
+			r.TLS = tt.state
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			switch {
+			case tt.want == nil && got != nil:
+				t.Fatalf("identity = %+v, want none", *got)
+			case tt.want != nil && got == nil:
+				t.Fatal("no identity")
+			case tt.want != nil && (got.Method != tt.want.Method || got.Name != tt.want.Name ||
+				!slices.Equal(got.Roles, tt.want.Roles) || !slices.Equal(got.HotelIDs, tt.want.HotelIDs)):
+				t.Fatalf("identity = %+v, want %+v", *got, *tt.want)
+			}
+			if got != nil && got.HasRole(models.RoleAdmin) {
+				t.Error("mapped certificate got the admin role")
+			}
+		})
+	}
+}
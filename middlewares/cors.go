@@ -0,0 +1,170 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lists what browser clients may do. AllowedOrigins may contain "*".
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// LoadCORSConfig reads GO_API_CORS_ORIGINS, GO_API_CORS_METHODS, GO_API_CORS_HEADERS,
+// GO_API_CORS_CREDENTIALS and GO_API_CORS_MAX_AGE. With no origins configured CORS stays off.
+func LoadCORSConfig() (CORSConfig, error) {
+	config := CORSConfig{
+		AllowedOrigins: splitList(os.Getenv("GO_API_CORS_ORIGINS")),
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key", "Accept", "X-Request-ID"},
+		ExposedHeaders: []string{
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "X-Quota-Remaining",
+			"Retry-After", "Idempotent-Replayed", "Content-Disposition", "X-Request-ID", "WWW-Authenticate",
+		},
+		MaxAge: 10 * time.Minute,
+	}
+
+	if v := os.Getenv("GO_API_CORS_METHODS"); v != "" {
+		config.AllowedMethods = splitList(strings.ToUpper(v))
+	}
+	if v := os.Getenv("GO_API_CORS_HEADERS"); v != "" {
+		config.AllowedHeaders = splitList(v)
+	}
+	if v := os.Getenv("GO_API_CORS_CREDENTIALS"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return config, fmt.Errorf("GO_API_CORS_CREDENTIALS: %w", err)
+		}
+		config.AllowCredentials = allow
+	}
+	if v := os.Getenv("GO_API_CORS_MAX_AGE"); v != "" {
+		maxAge, err := time.ParseDuration(v)
+		if err != nil {
+			return config, fmt.Errorf("GO_API_CORS_MAX_AGE: %w", err)
+		}
+		config.MaxAge = maxAge
+	}
+
+	// Браузери не приймають облікові дані разом з "*"
+	if config.AllowCredentials && config.allowsAnyOrigin() {
+		return config, fmt.Errorf("GO_API_CORS_CREDENTIALS cannot be used with origin *")
+	}
+	return config, nil
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (c CORSConfig) allowsAnyOrigin() bool {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) allowsMethod(method string) bool {
+	for _, allowed := range c.AllowedMethods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware must run before AuthMiddleware: browsers send preflight requests without X-API-Key
+func CORSMiddleware(config CORSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			if origin == "" || !config.allowsOrigin(origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if config.allowsAnyOrigin() && !config.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			requestedMethod := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || requestedMethod == "" {
+				h.Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Preflight: відповідаємо одразу, далі запит не йде
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if !config.allowsMethod(requestedMethod) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(config.AllowedMethods, ", "))
+			h.Set("Access-Control-Allow-Headers", strings.Join(config.AllowedHeaders, ", "))
+			if config.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeadersMiddleware adds the standard hardening headers to every response.
+// An empty csp means the default API policy, which forbids loading anything.
+func SecurityHeadersMiddleware(csp string) func(http.Handler) http.Handler {
+	if csp == "" {
+		csp = apiContentSecurityPolicy
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+			h.Set("Cross-Origin-Opener-Policy", "same-origin")
+			h.Set("Content-Security-Policy", csp)
+			// HSTS має сенс лише для HTTPS-відповідей
+			if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+				h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
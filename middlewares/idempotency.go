@@ -0,0 +1,192 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	maxIdempotencyKeyLen  = 255
+)
+
+// IdempotencyTTL reads GO_API_IDEMPOTENCY_TTL (a Go duration such as "12h"), 24h by default
+func IdempotencyTTL() (time.Duration, error) {
+	v := os.Getenv("GO_API_IDEMPOTENCY_TTL")
+	if v == "" {
+		return defaultIdempotencyTTL, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("GO_API_IDEMPOTENCY_TTL must be a positive duration, got %q", v)
+	}
+	return ttl, nil
+}
+
+// recordingWriter passes the response through and keeps a copy for replay
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+// IdempotencyMiddleware makes POST requests with an Idempotency-Key header safe to retry.
+// The first response is stored and replayed for the same key; reusing a key with a different
+// request gives 422, and a retry that arrives while the first request is still running gives 409.
+// Runs after AuthMiddleware, and keys are scoped to the caller's identity.
+func IdempotencyMiddleware(repo repositories.IdempotencyRepository, ttl time.Duration) func(http.Handler) http.Handler {
+	var sweepMu sync.Mutex
+	var swept time.Time
+
+	sweep := func(now time.Time) {
+		sweepMu.Lock()
+		due := now.Sub(swept) > 10*time.Minute
+		if due {
+			swept = now
+		}
+		sweepMu.Unlock()
+		if due {
+			if _, err := repo.DeleteExpired(now); err != nil {
+				log.Printf("Error deleting expired idempotency keys: %v", err)
+			}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					WriteProblem(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+					return
+				}
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			sweep(now)
+
+			record := &models.IdempotencyRecord{
+				Identity:    idempotencyScope(r),
+				Key:         key,
+				Fingerprint: requestFingerprint(r, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+			}
+
+			existing, err := repo.Reserve(record)
+			if err != nil {
+				logRequest(r, "Error reserving idempotency key: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if existing != nil {
+				replayIdempotent(w, existing, record.Fingerprint)
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				// Ключ звільняється, якщо обробник впав або повернув 5xx, щоб клієнт міг повторити запит
+				if !completed {
+					if err := repo.Release(record.Identity, record.Key); err != nil {
+						logRequest(r, "Error releasing idempotency key: %v", err)
+					}
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+
+			if rw.status == 0 || rw.status >= 500 {
+				return
+			}
+			err = repo.Complete(record.Identity, record.Key, rw.status, rw.Header().Get("Content-Type"), rw.body.Bytes())
+			if err != nil {
+				logRequest(r, "Error storing idempotent response: %v", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+func replayIdempotent(w http.ResponseWriter, existing *models.IdempotencyRecord, fingerprint string) {
+	if existing.Fingerprint != fingerprint {
+		http.Error(w, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if existing.Status == 0 {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+		return
+	}
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(existing.Body)))
+	w.WriteHeader(existing.Status)
+	w.Write(existing.Body)
+}
+
+// requestFingerprint covers everything that makes two POSTs "the same request"
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n", r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyScope keeps keys of different callers apart: the verified identity, or the address without one.
+// It is stored as a SHA-256, because a JWT subject or certificate name has no length limit.
+func idempotencyScope(r *http.Request) string {
+	scope, ok := verifiedIdentity(r)
+	if !ok {
+		scope, _ = clientIP(r)
+	}
+	sum := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mod/models"
+	"go.mod/security"
+)
+
+// memoryIdempotencyRepository refuses identities longer than the column, as MySQL in strict mode does
+type memoryIdempotencyRepository struct {
+	records map[[2]string]*models.IdempotencyRecord
+}
+
+func (r *memoryIdempotencyRepository) Reserve(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	if len(record.Identity) > 64 {
+		return nil, fmt.Errorf("data too long for column 'identity'")
+	}
+	if existing, ok := r.records[[2]string{record.Identity, record.Key}]; ok {
+		return existing, nil
+	}
+	copied := *record
+	r.records[[2]string{record.Identity, record.Key}] = &copied
+	return nil, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(identity, key string, status int, contentType string, body []byte) error {
+	record := r.records[[2]string{identity, key}]
+	record.Status, record.ContentType, record.Body = status, contentType, body
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Release(identity, key string) error {
+	delete(r.records, [2]string{identity, key})
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpired(now time.Time) (int64, error) { return 0, nil }
+
+func TestIdempotencyScopesLongIdentities(t *testing.T) {
+	repo := &memoryIdempotencyRepository{records: map[[2]string]*models.IdempotencyRecord{}}
+	created := 0
+	handler := IdempotencyMiddleware(repo, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		created++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id": %d}`, created)
+	}))
+	send := func(subject string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/bookings", strings.NewReader(`{}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Idempotency-Key", "order-1")
+		identity := security.Identity{Method: security.AuthMethodJWT, Name: subject}
+		r = r.WithContext(security.WithIdentity(r.Context(), identity))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	long := "https://login.example.com/realms/hotels/users/" + strings.Repeat("f", 40)
+	first := send(long)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request = %d %s", first.Code, first.Body.String())
+	}
+	if replay := send(long); replay.Body.String() != first.Body.String() || created != 1 {
+		t.Fatalf("retry = %q after %d creates, want the first response replayed", replay.Body.String(), created)
+	}
+	if other := send(long + "-other"); other.Body.String() == first.Body.String() || created != 2 {
+		t.Fatalf("another caller with the same key got %q, want its own response", other.Body.String())
+	}
+}
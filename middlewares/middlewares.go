@@ -1,48 +1,56 @@
 package middlewares
 
 import (
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"sync"
 
 	"go.mod/security"
 )
 
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// Chain wraps h so that the first middleware in the list runs first
+func Chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// requestLog is the one logger of the request lines; requests.log is opened on first use and stays open
+var (
+	requestLogOnce sync.Once
+	requestLog     *log.Logger
+)
+
+func requestLogger() *log.Logger {
+	requestLogOnce.Do(func() {
+		var out io.Writer = os.Stderr
 		logFile, err := os.OpenFile("requests.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			log.Printf("Error opening log file: %v", err)
+			log.Printf("Error opening log file, request lines go to stderr: %v", err)
+		} else {
+			out = logFile
 		}
-		defer logFile.Close()
+		requestLog = log.New(out, "", log.LstdFlags)
+	})
+	return requestLog
+}
 
-		log.SetOutput(logFile)
+// logRequest writes to the standard log with the request ID in front, so a line can be matched to its request
+func logRequest(r *http.Request, format string, args ...any) {
+	log.Printf("[%s] "+format, append([]any{security.RequestIDFromContext(r.Context())}, args...)...)
+}
 
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fullURL := r.URL.Path
 		if r.URL.RawQuery != "" {
 			fullURL += "?" + r.URL.RawQuery
 		}
 
-		log.Printf("Request: %s %s", r.Method, fullURL)
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-func AuthMiddleware(next http.Handler) http.Handler {
-	secretHash := os.Getenv("GO_API_SECRET_HASH")
-	secretSalt := os.Getenv("GO_API_SECRET_SALT")
-	if secretHash == "" || secretSalt == "" {
-		log.Fatal("Environment variables GO_API_SECRET_HASH or GO_API_SECRET_SALT are not set.")
-	}
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := r.Header.Get("X-API-Key")
-
-		if !security.CompareHash(apiKey, secretHash, secretSalt) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
+		requestLogger().Printf("Request: %s %s [%s]", r.Method, fullURL, security.RequestIDFromContext(r.Context()))
 
 		next.ServeHTTP(w, r)
 	})
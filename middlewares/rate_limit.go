@@ -0,0 +1,268 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mod/security"
+)
+
+// RateLimit is a token bucket (RequestsPerSecond refill, Burst capacity) plus an optional daily quota
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+	DailyQuota        int     `json:"daily_quota"`
+}
+
+// RateLimitConfig holds the default limit and per-identity overrides.
+// Identities are "key:<security.KeyID>" for API keys, "cert:<name>" for client certificates
+// and "ip:<address>" for the per-address limit every request passes before authentication.
+type RateLimitConfig struct {
+	Default RateLimit            `json:"default"`
+	Keys    map[string]RateLimit `json:"keys"`
+}
+
+func (c RateLimitConfig) limitFor(identity string) RateLimit {
+	if limit, ok := c.Keys[identity]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// RateLimitResult is the outcome of one Take call
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is set when the request is rejected
+	RetryAfter time.Duration
+	// QuotaRemaining is -1 when there is no daily quota
+	QuotaRemaining int
+}
+
+// RateLimitStore keeps counters. Take must check and consume atomically, so a shared
+// implementation (Redis, database) lets several instances enforce the same limits.
+type RateLimitStore interface {
+	Take(identity string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+type bucketState struct {
+	tokens     float64
+	updated    time.Time
+	quotaDay   string
+	quotaCount int
+}
+
+// MemoryRateLimitStore is the default single-instance store
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucketState
+	swept   time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*bucketState{}}
+}
+
+func (s *MemoryRateLimitStore) Take(identity string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[identity]
+	if !ok {
+		b = &bucketState{tokens: float64(limit.Burst), updated: now}
+		s.buckets[identity] = b
+	}
+	return takeToken(b, limit, now), nil
+}
+
+// sweep drops idle buckets once a minute so unknown clients can't grow the map forever
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	today := now.UTC().Format(time.DateOnly)
+	for identity, b := range s.buckets {
+		if now.Sub(b.updated) > time.Hour && b.quotaDay != today {
+			delete(s.buckets, identity)
+		}
+	}
+}
+
+func takeToken(b *bucketState, limit RateLimit, now time.Time) RateLimitResult {
+	capacity := float64(limit.Burst)
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*limit.RequestsPerSecond)
+	}
+	b.updated = now
+
+	// Денна квота рахується за UTC-добу
+	today := now.UTC().Format(time.DateOnly)
+	if b.quotaDay != today {
+		b.quotaDay = today
+		b.quotaCount = 0
+	}
+
+	result := RateLimitResult{QuotaRemaining: -1}
+	if limit.DailyQuota > 0 && b.quotaCount >= limit.DailyQuota {
+		midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		result.RetryAfter = midnight.Sub(now)
+		result.Reset = result.RetryAfter
+		result.QuotaRemaining = 0
+		result.Remaining = int(b.tokens)
+		return result
+	}
+
+	if b.tokens < 1 {
+		result.RetryAfter = time.Duration((1 - b.tokens) / limit.RequestsPerSecond * float64(time.Second))
+	} else {
+		b.tokens--
+		b.quotaCount++
+		result.Allowed = true
+	}
+
+	result.Remaining = int(b.tokens)
+	if limit.RequestsPerSecond > 0 {
+		result.Reset = time.Duration((capacity - b.tokens) / limit.RequestsPerSecond * float64(time.Second))
+	}
+	if limit.DailyQuota > 0 {
+		result.QuotaRemaining = limit.DailyQuota - b.quotaCount
+	}
+	return result
+}
+
+// LoadRateLimitConfig reads the JSON file named by GO_API_RATE_LIMITS, or falls back to
+// GO_API_RATE_LIMIT_RPS / GO_API_RATE_LIMIT_BURST / GO_API_DAILY_QUOTA
+func LoadRateLimitConfig() (RateLimitConfig, error) {
+	config := RateLimitConfig{
+		Default: RateLimit{RequestsPerSecond: 10, Burst: 20},
+	}
+
+	if path := os.Getenv("GO_API_RATE_LIMITS"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if v := os.Getenv("GO_API_RATE_LIMIT_RPS"); v != "" {
+		rps, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return config, fmt.Errorf("GO_API_RATE_LIMIT_RPS: %w", err)
+		}
+		config.Default.RequestsPerSecond = rps
+	}
+	if v := os.Getenv("GO_API_RATE_LIMIT_BURST"); v != "" {
+		burst, err := strconv.Atoi(v)
+		if err != nil {
+			return config, fmt.Errorf("GO_API_RATE_LIMIT_BURST: %w", err)
+		}
+		config.Default.Burst = burst
+	}
+	if v := os.Getenv("GO_API_DAILY_QUOTA"); v != "" {
+		quota, err := strconv.Atoi(v)
+		if err != nil {
+			return config, fmt.Errorf("GO_API_DAILY_QUOTA: %w", err)
+		}
+		config.Default.DailyQuota = quota
+	}
+
+	for identity, limit := range config.Keys {
+		if limit.RequestsPerSecond <= 0 || limit.Burst < 1 {
+			return config, fmt.Errorf("rate limit for %s needs positive requests_per_second and burst", identity)
+		}
+	}
+	if config.Default.RequestsPerSecond <= 0 || config.Default.Burst < 1 {
+		return config, fmt.Errorf("default rate limit needs positive requests_per_second and burst")
+	}
+	return config, nil
+}
+
+// clientIP is the bucket of everything that arrives from one address
+func clientIP(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, true
+}
+
+// verifiedIdentity is the bucket of an authenticated caller; credentials are never trusted before they are verified
+func verifiedIdentity(r *http.Request) (string, bool) {
+	identity, ok := security.IdentityFromContext(r.Context())
+	if !ok {
+		return "", false
+	}
+	return identity.String(), true
+}
+
+// ClientRateLimitMiddleware runs before AuthMiddleware and limits by client IP, so made-up keys
+// can neither burn CPU on argon2 nor get buckets of their own
+func ClientRateLimitMiddleware(store RateLimitStore, config RateLimitConfig) func(http.Handler) http.Handler {
+	return rateLimitMiddleware(store, config, clientIP)
+}
+
+// RateLimitMiddleware runs after AuthMiddleware and limits by the verified identity;
+// requests without one are left to ClientRateLimitMiddleware
+func RateLimitMiddleware(store RateLimitStore, config RateLimitConfig) func(http.Handler) http.Handler {
+	return rateLimitMiddleware(store, config, verifiedIdentity)
+}
+
+func rateLimitMiddleware(store RateLimitStore, config RateLimitConfig, key func(*http.Request) (string, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := key(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limit := config.limitFor(identity)
+
+			result, err := store.Take(identity, limit, time.Now())
+			if err != nil {
+				// Якщо сховище недоступне, не блокуємо клієнтів
+				logRequest(r, "Rate limit store error: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			policy := fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(time.Duration(float64(limit.Burst)/limit.RequestsPerSecond*float64(time.Second))))
+			if limit.DailyQuota > 0 {
+				policy += fmt.Sprintf(", %d;w=86400", limit.DailyQuota)
+				h.Set("X-Quota-Remaining", strconv.Itoa(result.QuotaRemaining))
+			}
+			h.Set("RateLimit-Policy", policy)
+
+			if !result.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"go.mod/models"
+	"go.mod/security"
+)
+
+// fakeAuthenticator accepts only the key "valid"
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Authenticate(r *http.Request) (security.Identity, bool, error) {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		return security.Identity{}, false, nil
+	}
+	if apiKey != "valid" {
+		return security.Identity{}, true, errInvalidCredentials
+	}
+	return security.Identity{Method: security.AuthMethodAPIKey, Name: security.KeyID(apiKey), Roles: []string{models.RoleAdmin}}, true, nil
+}
+
+func TestRateLimitKeysOnVerifiedIdentityOnly(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	config := RateLimitConfig{
+		Default: RateLimit{RequestsPerSecond: 0.001, Burst: 3},
+		Keys:    map[string]RateLimit{"key:" + security.KeyID("valid"): {RequestsPerSecond: 0.001, Burst: 5}},
+	}
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		ClientRateLimitMiddleware(store, config),
+		AuthMiddleware(fakeAuthenticator{}),
+		RateLimitMiddleware(store, config),
+	)
+	send := func(apiKey, addr string) int {
+		r := httptest.NewRequest(http.MethodGet, "/hotels", nil)
+		r.RemoteAddr = addr
+		r.Header.Set("X-API-Key", apiKey)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// Вигадані ключі не мають власних лічильників: усі вони витрачають ліміт адреси
+	var codes []int
+	for i := range 4 {
+		codes = append(codes, send("made-up-"+strconv.Itoa(i), "203.0.113.7:1000"))
+	}
+	if want := []int{http.StatusForbidden, http.StatusForbidden, http.StatusForbidden, http.StatusTooManyRequests}; !slices.Equal(codes, want) {
+		t.Fatalf("made-up keys got %v, want %v", codes, want)
+	}
+	if len(store.buckets) != 1 {
+		t.Fatalf("store has %d buckets after made-up keys, want only the address", len(store.buckets))
+	}
+
+	// Перевірений ключ має власний ліміт після автентифікації
+	if code := send("valid", "198.51.100.1:1000"); code != http.StatusOK {
+		t.Fatalf("valid key got %d", code)
+	}
+	bucket := store.buckets["key:"+security.KeyID("valid")]
+	if bucket == nil || int(bucket.tokens) != 4 {
+		t.Fatalf("key bucket = %+v, want 4 tokens left of 5", bucket)
+	}
+	if code := send("valid", "203.0.113.7:1000"); code != http.StatusTooManyRequests {
+		t.Fatalf("valid key from an exhausted address got %d, want 429", code)
+	}
+}
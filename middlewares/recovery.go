@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// Problem is an RFC 9457 (formerly 7807) problem details body
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// WriteProblem sends an application/problem+json response
+func WriteProblem(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// RecoveryMiddleware turns a panic in any handler into a logged stack trace and a 500 response.
+// It runs after RequestIDMiddleware, so the log line carries the ID the client got back.
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// Штатний спосіб перервати відповідь, net/http обробляє його сам
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			logRequest(r, "panic serving %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+			WriteProblem(w, http.StatusInternalServerError, "The server encountered an unexpected error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BodyLimitMiddleware caps the request body; reading past the limit fails with *http.MaxBytesError
+func BodyLimitMiddleware(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				WriteProblem(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ContentTypeMiddleware rejects POST, PUT and PATCH bodies whose media type is not in allowed;
+// requests without a body and routes that list no types pass through
+func ContentTypeMiddleware(allowed ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+			// :restore, check-in, check-out і cancel надсилають POST без тіла
+			if len(allowed) == 0 || (r.ContentLength == 0 && len(r.TransferEncoding) == 0) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err == nil {
+				for _, t := range allowed {
+					if strings.EqualFold(mediaType, t) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+
+			w.Header().Set("Accept-Post", strings.Join(allowed, ", "))
+			WriteProblem(w, http.StatusUnsupportedMediaType, "Content-Type must be one of: "+strings.Join(allowed, ", "))
+		})
+	}
+}
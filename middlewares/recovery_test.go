@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryLogsRequestID(t *testing.T) {
+	var logged bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), RequestIDMiddleware, RecoveryMiddleware)
+
+	r := httptest.NewRequest(http.MethodGet, "/hotels/1", nil)
+	r.Header.Set(requestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if got := w.Header().Get(requestIDHeader); got != "req-42" {
+		t.Errorf("X-Request-ID = %q, want req-42", got)
+	}
+	if line, _, _ := strings.Cut(logged.String(), "\n"); !strings.Contains(line, "[req-42] panic serving GET /hotels/1: boom") {
+		t.Errorf("log line = %q, want the request ID and the panic", line)
+	}
+}
+
+func TestContentTypeMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	tests := []struct {
+		name        string
+		allowed     []string
+		body        string
+		chunked     bool
+		contentType string
+		want        int
+	}{
+		{"allowed type", []string{"application/json"}, `{}`, false, "application/json; charset=utf-8", http.StatusNoContent},
+		{"other type", []string{"application/json"}, `{}`, false, "text/plain", http.StatusUnsupportedMediaType},
+		{"missing type", []string{"application/json"}, `{}`, false, "", http.StatusUnsupportedMediaType},
+		{"chunked body without a type", []string{"application/json"}, `{}`, true, "", http.StatusUnsupportedMediaType},
+		{"no body", []string{"application/json"}, "", false, "", http.StatusNoContent},
+		{"no types on the route", nil, `{}`, false, "text/plain", http.StatusNoContent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/bookings/1:cancel", strings.NewReader(tc.body))
+			if tc.chunked {
+				r.ContentLength = -1
+				r.TransferEncoding = []string{"chunked"}
+			}
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			ContentTypeMiddleware(tc.allowed...)(ok).ServeHTTP(w, r)
+			if w.Code != tc.want {
+				t.Fatalf("status = %d, want %d", w.Code, tc.want)
+			}
+			if tc.want == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Post") == "" {
+				t.Error("415 without Accept-Post")
+			}
+		})
+	}
+}
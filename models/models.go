@@ -1,33 +1,316 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Hotel struct {
 	gorm.Model
-	Name  string `gorm:"unique;not null"`
-	Rooms []Room `gorm:"foreignKey:HotelID"`
+	Name    string `gorm:"unique;not null"`
+	Address string `gorm:"size:500"`
+	// Latitude and Longitude are set together, in degrees
+	Latitude  *float64 `gorm:"index:idx_hotels_location"`
+	Longitude *float64 `gorm:"index:idx_hotels_location"`
+	// TimeZone is an IANA name such as Europe/Kyiv
+	TimeZone string `gorm:"size:64"`
+	// StarRating is 1 to 5 stars, 0 while the hotel is unrated
+	StarRating int `gorm:"not null;default:0"`
+	// CheckInTime and CheckOutTime are local times of day, "15:00"
+	CheckInTime  string      `gorm:"size:5;not null;default:'15:00'"`
+	CheckOutTime string      `gorm:"size:5;not null;default:'11:00'"`
+	Phone        string      `gorm:"size:30"`
+	Email        string      `gorm:"size:254"`
+	Website      string      `gorm:"size:255"`
+	Amenities    StringSlice `gorm:"type:json"`
+	Rooms        []Room      `gorm:"foreignKey:HotelID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	RoomTypes    []RoomType  `gorm:"foreignKey:HotelID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	TaxRules     []TaxRule   `gorm:"foreignKey:HotelID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	// CancellationPolicyID is the default for bookings that don't name their own policy
+	CancellationPolicyID *uint `gorm:"index"`
+}
+
+const (
+	PenaltyTypePercent    = "percent"
+	PenaltyTypeFirstNight = "first_night"
+)
+
+// CancellationPolicy makes cancelling free until FreeUntilDays days before arrival.
+// Later cancellations pay PenaltyPercent of the stay or the price of the first night.
+type CancellationPolicy struct {
+	gorm.Model
+	HotelID        uint    `gorm:"not null;index"`
+	Name           string  `gorm:"not null;size:100"`
+	FreeUntilDays  int     `gorm:"not null;default:0"`
+	PenaltyType    string  `gorm:"size:20;not null;default:first_night"`
+	PenaltyPercent float32 `gorm:"not null;default:0"`
+}
+
+const (
+	TaxKindPercent        = "percent"
+	TaxKindPerPersonNight = "per_person_night"
+)
+
+// TaxRule is a tax or fee a hotel charges. Percent rules, such as VAT or a service fee, take Rate percent
+// of the room nights and extra charges; Inclusive ones are already part of the prices. Per-person-per-night
+// rules, such as a city tax, charge Rate for every guest and night, leaving children out if ExemptChildren.
+type TaxRule struct {
+	gorm.Model
+	HotelID        uint    `gorm:"not null;index"`
+	Name           string  `gorm:"not null;size:100"`
+	Kind           string  `gorm:"size:20;not null"`
+	Rate           float32 `gorm:"not null"`
+	Inclusive      bool    `gorm:"not null;default:false"`
+	ExemptChildren bool    `gorm:"not null;default:false"`
+	// EffectiveFrom and EffectiveUntil limit the rule to the stays that start on or after EffectiveFrom
+	// and before EffectiveUntil; a zero date leaves that end open
+	EffectiveFrom  Date `gorm:"type:date"`
+	EffectiveUntil Date `gorm:"type:date"`
+}
+
+// InEffect reports whether a stay starting on checkIn is charged the rule
+func (r *TaxRule) InEffect(checkIn Date) bool {
+	return (r.EffectiveFrom.IsZero() || !checkIn.Before(r.EffectiveFrom.Time)) &&
+		(r.EffectiveUntil.IsZero() || checkIn.Before(r.EffectiveUntil.Time))
+}
+
+// RoomType is a bookable category of rooms in a hotel. Guests reserve a type, not a particular room.
+type RoomType struct {
+	gorm.Model
+	HotelID      uint        `gorm:"not null;index"`
+	Name         string      `gorm:"not null;size:100"`
+	BasePrice    float32     `gorm:"not null"`
+	MaxOccupancy int         `gorm:"not null;default:2"`
+	Beds         BedList     `gorm:"type:json"`
+	Facilities   StringSlice `gorm:"type:json"`
+	Units        []Room      `gorm:"foreignKey:RoomTypeID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
 }
 
+const (
+	RoomStatusAvailable    = "available"
+	RoomStatusOccupied     = "occupied"
+	RoomStatusCleaning     = "cleaning"
+	RoomStatusOutOfService = "out_of_service"
+)
+
+// Room is a physical room unit. RoomType and Price repeat the name and base price of its type,
+// so filters and clients written before room types existed keep working.
 type Room struct {
 	gorm.Model
 	RoomType   string      `gorm:"not null"`
 	Price      float32     `gorm:"not null"`
 	Facilities StringSlice `gorm:"type:json"`
-	HotelID    uint
+	HotelID    uint        `gorm:"not null;uniqueIndex:idx_rooms_live_number,priority:1"`
+	RoomTypeID *uint       `gorm:"index"`
+	Number     string      `gorm:"size:20"`
+	Floor      int
+	Status     string `gorm:"size:20;not null;default:available"`
+	// MaxOccupancy defaults to the room type's when the room is saved without one
+	MaxOccupancy int `gorm:"not null;default:2"`
+	// LiveNumber is Number while the room is out of the trash, NULL otherwise. Its unique index keeps
+	// a hotel's room numbers apart; NULLs never clash, so deleted and unnumbered rooms are left alone.
+	LiveNumber *string `gorm:"->;type:varchar(20) GENERATED ALWAYS AS (IF(deleted_at IS NULL AND number <> '', number, NULL)) STORED;uniqueIndex:idx_rooms_live_number,priority:2" json:"-"`
 }
 
+const (
+	DocumentTypePassport        = "passport"
+	DocumentTypeIDCard          = "id_card"
+	DocumentTypeDrivingLicence  = "driving_licence"
+	DocumentTypeResidencePermit = "residence_permit"
+)
+
+// Guest holds the contact and identity details that check-in regulations ask for. Nationality is
+// an ISO 3166-1 alpha-2 code such as UA; the Document fields describe one identity document.
 type Guest struct {
 	gorm.Model
-	Name         string      `gorm:"not null"`
-	MobileNumber string      `gorm:"unique;not null"`
-	Preferences  StringSlice `gorm:"type:json"`
+	Name           string      `gorm:"not null"`
+	MobileNumber   string      `gorm:"unique;not null"`
+	Preferences    StringSlice `gorm:"type:json"`
+	Email          string      `gorm:"size:254;index"`
+	DateOfBirth    Date        `gorm:"type:date"`
+	Nationality    string      `gorm:"size:2"`
+	Address        string      `gorm:"size:500"`
+	DocumentType   string      `gorm:"size:20"`
+	DocumentNumber string      `gorm:"size:50"`
+	DocumentExpiry Date        `gorm:"type:date"`
+	// Marketing consents are opt-in; ConsentUpdatedAt is when either of them last changed
+	MarketingEmailConsent bool `gorm:"not null;default:false"`
+	MarketingSMSConsent   bool `gorm:"not null;default:false"`
+	ConsentUpdatedAt      *time.Time
 }
 
+const (
+	BookingStatusConfirmed  = "confirmed"
+	BookingStatusCheckedIn  = "checked_in"
+	BookingStatusCheckedOut = "checked_out"
+	BookingStatusCancelled  = "cancelled"
+	// BookingStatusNoShow is set when the guest hasn't checked in by the hotel's no-show cutoff
+	BookingStatusNoShow = "no_show"
+)
+
+// Booking covers the nights from CheckIn up to, but not including, CheckOut. The dates are calendar dates
+// at the hotel, in its time zone; CancelledAt, like every other timestamp, is stored in UTC.
+// BookedRooms are the physical rooms the guests stay in; for Reservations they are filled at check-in.
+// CancellationPolicyID overrides the hotel's default policy, e.g. for a non-refundable rate.
+// Payments is the money ledger; it is served by its own endpoint and never saved with the booking.
 type Booking struct {
 	gorm.Model
-	GuestID     uint
-	HotelID     uint
-	Guest       Guest `gorm:"foreignKey:GuestID"`
-	Hotel       Hotel `gorm:"foreignKey:HotelID"`
-	BookedRooms []Room `gorm:"many2many:booking_rooms;"`
+	GuestID              uint   `gorm:"not null"`
+	HotelID              uint   `gorm:"not null"`
+	CheckIn              Date   `gorm:"type:date;index"`
+	CheckOut             Date   `gorm:"type:date;index;check:chk_bookings_stay,check_out > check_in"`
+	Status               string `gorm:"size:20;not null;default:confirmed;index"`
+	CancelledAt          *time.Time
+	Adults               int           `gorm:"not null;default:1"`
+	Children             int           `gorm:"not null;default:0"`
+	ChildAges            IntSlice      `gorm:"type:json"`
+	CancellationPolicyID *uint         `gorm:"index"`
+	CancellationReason   string        `gorm:"size:500"`
+	CancellationPenalty  float32       `gorm:"not null;default:0"`
+	Guest                Guest         `gorm:"foreignKey:GuestID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	Hotel                Hotel         `gorm:"foreignKey:HotelID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	BookedRooms          []Room        `gorm:"many2many:booking_rooms;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	Reservations         []Reservation `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Payments             []Payment     `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// Reservation holds one room of a type for the booking's dates. RoomID is set when a
+// concrete room is assigned at check-in.
+type Reservation struct {
+	ID         uint      `gorm:"primaryKey"`
+	BookingID  uint      `gorm:"not null;index"`
+	RoomTypeID uint      `gorm:"not null;index"`
+	RoomID     *uint     `gorm:"index"`
+	RoomType   *RoomType `gorm:"foreignKey:RoomTypeID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	Room       *Room     `gorm:"foreignKey:RoomID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+}
+
+const (
+	PaymentKindCharge  = "charge"
+	PaymentKindPayment = "payment"
+	PaymentKindDeposit = "deposit"
+	PaymentKindRefund  = "refund"
+
+	PaymentMethodCash = "cash"
+	PaymentMethodCard = "card"
+
+	// A card entry is pending while the provider processes it, then captured or failed
+	PaymentStatusPending  = "pending"
+	PaymentStatusCaptured = "captured"
+	PaymentStatusFailed   = "failed"
+)
+
+// Payment is one entry of a booking's ledger. Amount is always positive: charges add to what the guest owes,
+// payments and deposits pay it off and refunds give money back. Entries are never changed, apart from
+// the provider's outcome of a pending card entry; a mistake is corrected with a new entry. Reference is
+// the provider's transaction ID for card entries, RefundOf the entry a refund gives money back for.
+type Payment struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	BookingID uint      `gorm:"not null;index"`
+	Kind      string    `gorm:"size:20;not null"`
+	Amount    float32   `gorm:"not null"`
+	Method    string    `gorm:"size:20"`
+	Status    string    `gorm:"size:20;not null;default:'captured'"`
+	Reference string    `gorm:"size:100"`
+	RefundOf  *uint     `gorm:"index"`
+	Note      string    `gorm:"size:500"`
+}
+
+// Settled reports whether the entry counts in the balance, i.e. the provider isn't processing it and didn't refuse it
+func (p *Payment) Settled() bool {
+	return p.Status != PaymentStatusPending && p.Status != PaymentStatusFailed
+}
+
+// ErrInvoiceImmutable is returned when something tries to change or delete an issued invoice
+var ErrInvoiceImmutable = errors.New("issued invoices can't be changed")
+
+// Invoice is the folio of a booking as it was issued, kept verbatim in Folio. Number counts
+// the hotel's invoices from 1 without gaps. Issued invoices are never changed or deleted.
+type Invoice struct {
+	ID        uint         `gorm:"primaryKey"`
+	IssuedAt  time.Time    `gorm:"autoCreateTime;not null"`
+	HotelID   uint         `gorm:"not null;uniqueIndex:idx_invoice_number"`
+	Number    uint         `gorm:"not null;uniqueIndex:idx_invoice_number"`
+	BookingID uint         `gorm:"not null;uniqueIndex"`
+	Folio     JSONDocument `gorm:"type:json;not null"`
+	Hotel     *Hotel       `gorm:"foreignKey:HotelID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+	Booking   *Booking     `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+}
+
+func (Invoice) BeforeUpdate(*gorm.DB) error { return ErrInvoiceImmutable }
+
+func (Invoice) BeforeDelete(*gorm.DB) error { return ErrInvoiceImmutable }
+
+// InvoiceSequence holds the last invoice number of a hotel. It is locked while an invoice is issued,
+// so numbers are handed out one at a time and a failed issue gives its number back.
+type InvoiceSequence struct {
+	HotelID    uint `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber uint `gorm:"not null;default:0"`
+}
+
+// IdempotencyRecord remembers the response to a POST sent with an Idempotency-Key header.
+// Identity is the hex SHA-256 of the caller. Status 0 means the first request is still being processed.
+type IdempotencyRecord struct {
+	Identity    string `gorm:"primaryKey;size:64"`
+	Key         string `gorm:"primaryKey;size:255"`
+	Fingerprint string `gorm:"size:64;not null"`
+	Status      int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+const (
+	RoleAdmin        = "admin"
+	RoleManager      = "manager"
+	RoleReceptionist = "receptionist"
+	RoleReadOnly     = "read-only"
+)
+
+// User is a staff account. Receptionists only work with the hotels they are assigned to.
+type User struct {
+	gorm.Model
+	Username     string  `gorm:"unique;not null;size:100"`
+	PasswordHash string  `gorm:"not null" json:"-"`
+	PasswordSalt string  `gorm:"not null" json:"-"`
+	Role         string  `gorm:"not null;size:20"`
+	Hotels       []Hotel `gorm:"many2many:user_hotels;"`
+}
+
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionRestore = "restore"
+	AuditActionPurge   = "purge"
+
+	AuditEntityHotel    = "hotel"
+	AuditEntityRoom     = "room"
+	AuditEntityRoomType = "room_type"
+	AuditEntityGuest    = "guest"
+	AuditEntityBooking  = "booking"
+	AuditEntityPolicy   = "cancellation_policy"
+	AuditEntityPayment  = "payment"
+	AuditEntityInvoice  = "invoice"
+	AuditEntityTaxRule  = "tax_rule"
+)
+
+// AuditEntry records one create, update or delete. Before and After are snapshots of the row,
+// Changes maps every changed field to its old and new value.
+type AuditEntry struct {
+	ID         uint         `gorm:"primaryKey"`
+	CreatedAt  time.Time    `gorm:"index"`
+	Actor      string       `gorm:"size:255;not null"`
+	AuthMethod string       `gorm:"size:20"`
+	Action     string       `gorm:"size:10;not null"`
+	EntityType string       `gorm:"size:20;not null;index:idx_audit_entity"`
+	EntityID   uint         `gorm:"not null;index:idx_audit_entity"`
+	RequestID  string       `gorm:"size:64;index"`
+	Changes    JSONDocument `gorm:"type:json"`
+	Before     JSONDocument `gorm:"type:json"`
+	After      JSONDocument `gorm:"type:json"`
 }
@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"go.mod/models"
+	"go.mod/security"
+	"gorm.io/gorm"
+)
+
+// AuditFilter narrows the audit log; zero values match everything
+type AuditFilter struct {
+	EntityType string
+	EntityID   uint
+	Limit      int
+}
+
+type AuditRepository interface {
+	Find(filter AuditFilter) ([]models.AuditEntry, error)
+	// After returns up to limit entries of the given entity types written after the entry afterID, oldest first
+	After(afterID uint, entityTypes []string, limit int) ([]models.AuditEntry, error)
+	// LastID is the ID of the newest entry, 0 when the log is empty
+	LastID() (uint, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Find returns the newest entries first
+func (r *auditRepository) Find(filter AuditFilter) ([]models.AuditEntry, error) {
+	query := r.db.Order("id DESC").Limit(filter.Limit)
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+
+	var entries []models.AuditEntry
+	err := query.Find(&entries).Error
+	return entries, err
+}
+
+func (r *auditRepository) After(afterID uint, entityTypes []string, limit int) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	err := r.db.Where("id > ? AND entity_type IN ?", afterID, entityTypes).Order("id").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+func (r *auditRepository) LastID() (uint, error) {
+	var id uint
+	err := r.db.Model(&models.AuditEntry{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// withAudit runs change in a transaction and writes its audit entry in the same transaction,
+// so neither can be committed without the other
+func withAudit[T any](ctx context.Context, db *gorm.DB, action, entityType string, id func() uint,
+	change func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return auditedChange[T](tx, action, entityType, id, change)
+	})
+}
+
+// auditedChange applies change inside tx and audits it. The row is read before and after the change
+// to take the snapshots; id is called after change, because a create only gets its ID then.
+func auditedChange[T any](tx *gorm.DB, action, entityType string, id func() uint, change func(tx *gorm.DB) error) error {
+	var before, after *T
+	if action != models.AuditActionCreate {
+		read := tx
+		// Відновлювати й остаточно видаляти можна лише записи з кошика
+		if action == models.AuditActionRestore || action == models.AuditActionPurge {
+			read = tx.Unscoped()
+		}
+		before = new(T)
+		if err := read.First(before, id()).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := change(tx); err != nil {
+		return err
+	}
+
+	if action != models.AuditActionDelete && action != models.AuditActionPurge {
+		after = new(T)
+		if err := tx.First(after, id()).Error; err != nil {
+			return err
+		}
+	}
+	return recordAudit(tx, action, entityType, id(), before, after)
+}
+
+// recordAudit writes one entry; the actor and request ID come from the transaction's context
+func recordAudit[T any](tx *gorm.DB, action, entityType string, entityID uint, before, after *T) error {
+	entry := models.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Actor:      "unknown",
+	}
+
+	ctx := tx.Statement.Context
+	if identity, ok := security.IdentityFromContext(ctx); ok {
+		entry.Actor = identity.Name
+		entry.AuthMethod = identity.Method
+	}
+	entry.RequestID = security.RequestIDFromContext(ctx)
+
+	var beforeFields, afterFields map[string]interface{}
+	var err error
+	if before != nil {
+		if entry.Before, beforeFields, err = auditSnapshot(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if entry.After, afterFields, err = auditSnapshot(after); err != nil {
+			return err
+		}
+	}
+	if entry.Changes, err = auditChanges(beforeFields, afterFields); err != nil {
+		return err
+	}
+
+	return tx.Create(&entry).Error
+}
+
+func auditSnapshot(value interface{}) (models.JSONDocument, map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, nil, err
+	}
+	return models.JSONDocument(data), fields, nil
+}
+
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// auditChanges lists the top-level fields that differ. UpdatedAt is left out:
+// it changes on every update and is already the entry's own timestamp.
+func auditChanges(before, after map[string]interface{}) (models.JSONDocument, error) {
+	changes := map[string]auditChange{}
+	for key, value := range before {
+		if !reflect.DeepEqual(value, after[key]) {
+			changes[key] = auditChange{Before: value, After: after[key]}
+		}
+	}
+	for key, value := range after {
+		if _, seen := before[key]; !seen {
+			changes[key] = auditChange{After: value}
+		}
+	}
+	delete(changes, "UpdatedAt")
+
+	data, err := json.Marshal(changes)
+	return models.JSONDocument(data), err
+}
@@ -0,0 +1,572 @@
+package repositories
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BackupFormatNDJSON = "ndjson"
+	BackupFormatTarGz  = "tar.gz"
+
+	backupVersion = 1
+	manifestName  = "manifest.json"
+)
+
+// BookingRoom is a row of the booking_rooms join table
+type BookingRoom struct {
+	BookingID uint `gorm:"column:booking_id"`
+	RoomID    uint `gorm:"column:room_id"`
+}
+
+// UserHotel is a row of the user_hotels join table
+type UserHotel struct {
+	UserID  uint `gorm:"column:user_id"`
+	HotelID uint `gorm:"column:hotel_id"`
+}
+
+// backupUser is a users row with the password hash and salt, which models.User keeps out of JSON
+type backupUser struct {
+	gorm.Model
+	Username     string
+	PasswordHash string
+	PasswordSalt string
+	Role         string
+}
+
+func (backupUser) TableName() string { return "users" }
+
+// BackupManifest describes the files of a tar.gz bundle
+type BackupManifest struct {
+	Version   int                   `json:"version"`
+	CreatedAt time.Time             `json:"created_at"`
+	Files     []BackupManifestEntry `json:"files"`
+}
+
+type BackupManifestEntry struct {
+	Name   string `json:"name"`
+	Table  string `json:"table"`
+	Count  int    `json:"count"`
+	SHA256 string `json:"sha256"`
+}
+
+// ndjsonRecord is a single line of an NDJSON export
+type ndjsonRecord struct {
+	Table string          `json:"table"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// backupTable describes how one table is exported and restored.
+// Order of backupTables matters: parents go before children.
+// serial marks tables with an auto-increment id, whose counter has to follow the restored ids.
+type backupTable struct {
+	name   string
+	file   string
+	serial bool
+	export func(db *gorm.DB, emit func(v interface{}) error) (int, error)
+	insert func(tx *gorm.DB, raw json.RawMessage) error
+}
+
+// notBackedUp are migrated tables that are left out on purpose: idempotency records expire within a day
+var notBackedUp = []string{"idempotency_records"}
+
+var backupTables = []backupTable{
+	{
+		name:   "hotels",
+		file:   "hotels.json",
+		serial: true,
+		export: exportBatches[models.Hotel],
+		insert: insertRecord[models.Hotel],
+	},
+	{
+		name:   "cancellation_policies",
+		file:   "cancellation_policies.json",
+		serial: true,
+		export: exportBatches[models.CancellationPolicy],
+		insert: insertRecord[models.CancellationPolicy],
+	},
+	{
+		name:   "tax_rules",
+		file:   "tax_rules.json",
+		serial: true,
+		export: exportBatches[models.TaxRule],
+		insert: insertRecord[models.TaxRule],
+	},
+	{
+		name:   "room_types",
+		file:   "room_types.json",
+		serial: true,
+		export: exportBatches[models.RoomType],
+		insert: insertRecord[models.RoomType],
+	},
+	{
+		name:   "rooms",
+		file:   "rooms.json",
+		serial: true,
+		export: exportBatches[models.Room],
+		insert: insertRecord[models.Room],
+	},
+	{
+		name:   "guests",
+		file:   "guests.json",
+		serial: true,
+		export: exportBatches[models.Guest],
+		insert: insertRecord[models.Guest],
+	},
+	{
+		name:   "bookings",
+		file:   "booking.json",
+		serial: true,
+		export: exportBatches[models.Booking],
+		insert: insertRecord[models.Booking],
+	},
+	{
+		name:   "reservations",
+		file:   "reservations.json",
+		serial: true,
+		export: exportBatches[models.Reservation],
+		insert: insertRecord[models.Reservation],
+	},
+	{
+		name:   "payments",
+		file:   "payments.json",
+		serial: true,
+		export: exportBatches[models.Payment],
+		insert: insertRecord[models.Payment],
+	},
+	{
+		name:   "invoices",
+		file:   "invoices.json",
+		serial: true,
+		export: exportBatches[models.Invoice],
+		insert: insertRecord[models.Invoice],
+	},
+	{
+		name:   "invoice_sequences",
+		file:   "invoice_sequences.json",
+		export: exportInvoiceSequences,
+		insert: insertRecord[models.InvoiceSequence],
+	},
+	{
+		name: "booking_rooms",
+		file: "booking_rooms.json",
+		export: exportJoinTable("booking_rooms", "booking_id", "room_id", func(bookingID, roomID uint) BookingRoom {
+			return BookingRoom{BookingID: bookingID, RoomID: roomID}
+		}),
+		insert: insertJoinTable("booking_rooms", "booking_id", "room_id", func(link BookingRoom) (uint, uint) {
+			return link.BookingID, link.RoomID
+		}),
+	},
+	{
+		name:   "users",
+		file:   "users.json",
+		serial: true,
+		export: exportBatches[backupUser],
+		insert: insertRecord[backupUser],
+	},
+	{
+		name: "user_hotels",
+		file: "user_hotels.json",
+		export: exportJoinTable("user_hotels", "user_id", "hotel_id", func(userID, hotelID uint) UserHotel {
+			return UserHotel{UserID: userID, HotelID: hotelID}
+		}),
+		insert: insertJoinTable("user_hotels", "user_id", "hotel_id", func(link UserHotel) (uint, uint) {
+			return link.UserID, link.HotelID
+		}),
+	},
+	{
+		name:   "audit_entries",
+		file:   "audit_entries.json",
+		serial: true,
+		export: exportBatches[models.AuditEntry],
+		insert: insertRecord[models.AuditEntry],
+	},
+}
+
+func exportBatches[T any](db *gorm.DB, emit func(v interface{}) error) (int, error) {
+	var batch []T
+	count := 0
+	result := db.Unscoped().Order("id").FindInBatches(&batch, streamBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := emit(&batch[i]); err != nil {
+				return err
+			}
+		}
+		count += len(batch)
+		return nil
+	})
+	return count, result.Error
+}
+
+// exportJoinTable streams a many2many table, which has no model of its own, by its two key columns
+func exportJoinTable[T any](table, left, right string, row func(left, right uint) T) func(db *gorm.DB, emit func(v interface{}) error) (int, error) {
+	return func(db *gorm.DB, emit func(v interface{}) error) (int, error) {
+		rows, err := db.Table(table).Select(left, right).Order(left + ", " + right).Rows()
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+
+		count := 0
+		for rows.Next() {
+			var l, r uint
+			if err := rows.Scan(&l, &r); err != nil {
+				return count, err
+			}
+			link := row(l, r)
+			if err := emit(&link); err != nil {
+				return count, err
+			}
+			count++
+		}
+		return count, rows.Err()
+	}
+}
+
+// exportInvoiceSequences orders by hotel, because the sequences have no id column
+func exportInvoiceSequences(db *gorm.DB, emit func(v interface{}) error) (int, error) {
+	var sequences []models.InvoiceSequence
+	if err := db.Order("hotel_id").Find(&sequences).Error; err != nil {
+		return 0, err
+	}
+	for i := range sequences {
+		if err := emit(&sequences[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(sequences), nil
+}
+
+func insertRecord[T any](tx *gorm.DB, raw json.RawMessage) error {
+	var record T
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return err
+	}
+	// Зв'язки відновлюються окремими таблицями, тому тут їх не чіпаємо
+	return tx.Omit(clause.Associations).Create(&record).Error
+}
+
+func insertJoinTable[T any](table, left, right string, keys func(T) (uint, uint)) func(tx *gorm.DB, raw json.RawMessage) error {
+	return func(tx *gorm.DB, raw json.RawMessage) error {
+		var link T
+		if err := json.Unmarshal(raw, &link); err != nil {
+			return err
+		}
+		l, r := keys(link)
+		return tx.Table(table).Create(map[string]interface{}{left: l, right: r}).Error
+	}
+}
+
+// resetSequences moves the id counters past the restored rows. MySQL and SQLite do it on their own
+// when a row is inserted with an explicit id; PostgreSQL sequences have to be set.
+func resetSequences(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, t := range backupTables {
+		if !t.serial {
+			continue
+		}
+		err := tx.Exec("SELECT setval(pg_get_serial_sequence(?, 'id'), COALESCE((SELECT MAX(id) FROM "+t.name+"), 0) + 1, false)", t.name).Error
+		if err != nil {
+			return fmt.Errorf("reset %s id sequence: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+func findBackupTable(name string) (backupTable, bool) {
+	for _, t := range backupTables {
+		if t.name == name || t.file == name {
+			return t, true
+		}
+	}
+	return backupTable{}, false
+}
+
+// ExportNDJSON streams every table, including soft-deleted rows, as one JSON object per line
+func ExportNDJSON(db *gorm.DB, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	for _, t := range backupTables {
+		table := t.name
+		_, err := t.export(db, func(v interface{}) error {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			return enc.Encode(ndjsonRecord{Table: table, Data: data})
+		})
+		if err != nil {
+			return fmt.Errorf("export %s: %w", table, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// ExportBundle writes a tar.gz with one JSON array per table in the repositories/data
+// shape, plus a manifest with row counts and SHA-256 checksums
+func ExportBundle(db *gorm.DB, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := BackupManifest{Version: backupVersion, CreatedAt: time.Now().UTC()}
+	for _, t := range backupTables {
+		entry, err := writeBundleFile(db, tw, t)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", t.name, err)
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, manifestName, int64(len(data)), bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeBundleFile spools the table to a temp file first, because a tar header needs the size up front
+func writeBundleFile(db *gorm.DB, tw *tar.Writer, t backupTable) (BackupManifestEntry, error) {
+	tmp, err := os.CreateTemp("", "backup-"+t.name+"-*.json")
+	if err != nil {
+		return BackupManifestEntry{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	bw := bufio.NewWriter(io.MultiWriter(tmp, hasher))
+
+	first := true
+	bw.WriteString("[\n")
+	count, err := t.export(db, func(v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if !first {
+			bw.WriteString(",\n")
+		}
+		first = false
+		bw.WriteString("  ")
+		_, err = bw.Write(data)
+		return err
+	})
+	if err != nil {
+		return BackupManifestEntry{}, err
+	}
+	bw.WriteString("\n]\n")
+	if err := bw.Flush(); err != nil {
+		return BackupManifestEntry{}, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return BackupManifestEntry{}, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return BackupManifestEntry{}, err
+	}
+	if err := writeTarEntry(tw, t.file, size, tmp); err != nil {
+		return BackupManifestEntry{}, err
+	}
+
+	return BackupManifestEntry{
+		Name:   t.file,
+		Table:  t.name,
+		Count:  count,
+		SHA256: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+func writeTarEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+// RestoreNDJSON loads an NDJSON export into an empty database in a single transaction
+func RestoreNDJSON(db *gorm.DB, r io.Reader) error {
+	if err := ensureEmpty(db); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		dec := json.NewDecoder(bufio.NewReader(r))
+		line := 0
+		for {
+			var rec ndjsonRecord
+			err := dec.Decode(&rec)
+			if errors.Is(err, io.EOF) {
+				return resetSequences(tx)
+			}
+			line++
+			if err != nil {
+				return fmt.Errorf("record %d: %w", line, err)
+			}
+
+			t, ok := findBackupTable(rec.Table)
+			if !ok {
+				return fmt.Errorf("record %d: unknown table %q", line, rec.Table)
+			}
+			if err := t.insert(tx, rec.Data); err != nil {
+				return fmt.Errorf("record %d (%s): %w", line, rec.Table, err)
+			}
+		}
+	})
+}
+
+// RestoreBundle loads a tar.gz bundle into an empty database after verifying its checksums
+func RestoreBundle(db *gorm.DB, r io.Reader) error {
+	if err := ensureEmpty(db); err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	// Файли спершу читаються у тимчасові, бо маніфест записаний останнім
+	files := map[string]*os.File{}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	var manifest *BackupManifest
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Name == manifestName {
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return fmt.Errorf("manifest: %w", err)
+			}
+			continue
+		}
+
+		tmp, err := os.CreateTemp("", "restore-*.json")
+		if err != nil {
+			return err
+		}
+		files[hdr.Name] = tmp
+		if _, err := io.Copy(tmp, tr); err != nil {
+			return err
+		}
+	}
+
+	if manifest == nil {
+		return errors.New("bundle has no manifest")
+	}
+	if manifest.Version != backupVersion {
+		return fmt.Errorf("unsupported bundle version %d", manifest.Version)
+	}
+
+	for _, entry := range manifest.Files {
+		f, ok := files[entry.Name]
+		if !ok {
+			return fmt.Errorf("bundle is missing %s", entry.Name)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, f); err != nil {
+			return err
+		}
+		if hex.EncodeToString(hasher.Sum(nil)) != entry.SHA256 {
+			return fmt.Errorf("checksum mismatch for %s", entry.Name)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, t := range backupTables {
+			f, ok := files[t.file]
+			if !ok {
+				continue
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := restoreArray(tx, t, f); err != nil {
+				return fmt.Errorf("restore %s: %w", t.name, err)
+			}
+		}
+		return resetSequences(tx)
+	})
+}
+
+// restoreArray reads a JSON array element by element so large tables are not loaded at once
+func restoreArray(tx *gorm.DB, t backupTable, r io.Reader) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		if err := t.insert(tx, raw); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// ensureEmpty checks every table the backup covers; counting by table name includes soft-deleted rows
+func ensureEmpty(db *gorm.DB) error {
+	for _, t := range backupTables {
+		var count int64
+		if err := db.Table(t.name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("restore requires an empty database, %s has %d rows", t.name, count)
+		}
+	}
+	return nil
+}
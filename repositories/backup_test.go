@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// TestBackupCoversEveryTable keeps backupTables in step with the models: every migrated table and
+// many2many table is backed up, after the tables it refers to
+func TestBackupCoversEveryTable(t *testing.T) {
+	position := map[string]int{}
+	for i, table := range backupTables {
+		position[table.name] = i
+	}
+	after := func(child, parent string) {
+		t.Helper()
+		if position[parent] >= position[child] {
+			t.Errorf("%s is restored before %s, which it refers to", child, parent)
+		}
+	}
+
+	cache := &sync.Map{}
+	for _, model := range migratedModels {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slices.Contains(notBackedUp, s.Table) {
+			continue
+		}
+		if _, ok := position[s.Table]; !ok {
+			t.Errorf("%s is not backed up", s.Table)
+			continue
+		}
+		for _, rel := range s.Relationships.BelongsTo {
+			after(s.Table, rel.FieldSchema.Table)
+		}
+		for _, rel := range s.Relationships.Many2Many {
+			if _, ok := position[rel.JoinTable.Table]; !ok {
+				t.Errorf("%s is not backed up", rel.JoinTable.Table)
+				continue
+			}
+			after(rel.JoinTable.Table, s.Table)
+			after(rel.JoinTable.Table, rel.FieldSchema.Table)
+		}
+	}
+}
+
+// TestBackupUserHasEveryColumn guards the users copy, which exists only to keep the password hash
+func TestBackupUserHasEveryColumn(t *testing.T) {
+	cache := &sync.Map{}
+	columns := func(model interface{}) []string {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.Sorted(slices.Values(s.DBNames))
+	}
+	if user, backup := columns(&models.User{}), columns(&backupUser{}); !slices.Equal(user, backup) {
+		t.Errorf("users columns = %v, backup has %v", user, backup)
+	}
+}
+
+// TestBackupRoundTrip needs GO_API_TEST_DSN pointing at a scratch MySQL database: it drops every table there
+func TestBackupRoundTrip(t *testing.T) {
+	dsn := os.Getenv("GO_API_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GO_API_TEST_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), gormConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetDatabase(t, db)
+	seedBackupData(t, db)
+
+	var original bytes.Buffer
+	if err := ExportNDJSON(db, &original); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range backupTables {
+		if !strings.Contains(original.String(), `{"table":"`+table.name+`"`) {
+			t.Errorf("export has no %s rows", table.name)
+		}
+	}
+	if err := RestoreNDJSON(db, bytes.NewReader(original.Bytes())); err == nil {
+		t.Fatal("RestoreNDJSON into a database with rows succeeded")
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		resetDatabase(t, db)
+		if err := RestoreNDJSON(db, bytes.NewReader(original.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		compareExport(t, db, original.String())
+	})
+
+	t.Run("bundle", func(t *testing.T) {
+		var bundle bytes.Buffer
+		if err := ExportBundle(db, &bundle); err != nil {
+			t.Fatal(err)
+		}
+		resetDatabase(t, db)
+		if err := RestoreBundle(db, &bundle); err != nil {
+			t.Fatal(err)
+		}
+		compareExport(t, db, original.String())
+	})
+
+	// Нові записи не наштовхуються на відновлені id
+	hotel := models.Hotel{Name: "Added after restore"}
+	if err := db.Create(&hotel).Error; err != nil {
+		t.Fatal(err)
+	}
+	if hotel.ID <= 2 {
+		t.Errorf("new hotel got id %d, which the backup already used", hotel.ID)
+	}
+}
+
+func resetDatabase(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	if err := db.Migrator().DropTable("booking_rooms", "user_hotels"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().DropTable(migratedModels...); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func compareExport(t *testing.T, db *gorm.DB, want string) {
+	t.Helper()
+	var got bytes.Buffer
+	if err := ExportNDJSON(db, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want {
+		t.Errorf("export after restore differs:\n got: %s\nwant: %s", got.String(), want)
+	}
+}
+
+// seedBackupData writes a row to every backed-up table, with soft-deleted rows among them
+func seedBackupData(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	at := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	policyID, roomTypeID, roomID := uint(1), uint(1), uint(1)
+	deleted := gorm.DeletedAt{Time: at, Valid: true}
+
+	rows := []interface{}{
+		&models.Hotel{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, Name: "Carpathian Lodge", TimeZone: "Europe/Kyiv",
+			Amenities: models.StringSlice{"spa"}, CancellationPolicyID: &policyID},
+		&models.Hotel{Model: gorm.Model{ID: 2, CreatedAt: at, UpdatedAt: at, DeletedAt: deleted}, Name: "Closed Inn"},
+		&models.CancellationPolicy{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, HotelID: 1, Name: "Flexible",
+			FreeUntilDays: 2, PenaltyType: models.PenaltyTypeFirstNight},
+		&models.TaxRule{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, HotelID: 1, Name: "City tax",
+			Kind: models.TaxKindPerPersonNight, Rate: 1.5},
+		&models.RoomType{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, HotelID: 1, Name: "Double", BasePrice: 120, MaxOccupancy: 2},
+		&models.Room{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, HotelID: 1, RoomTypeID: &roomTypeID, RoomType: "Double",
+			Price: 120, Number: "101", Status: models.RoomStatusAvailable, MaxOccupancy: 2},
+		&models.Guest{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, Name: "Olena Koval", MobileNumber: "+380501234567",
+			Email: "olena@example.com"},
+		&models.Booking{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, GuestID: 1, HotelID: 1,
+			CheckIn: models.NewDate(2026, 4, 10), CheckOut: models.NewDate(2026, 4, 12), Status: models.BookingStatusCheckedIn, Adults: 2},
+		&models.Reservation{ID: 1, BookingID: 1, RoomTypeID: 1, RoomID: &roomID},
+		&models.Payment{ID: 1, CreatedAt: at, BookingID: 1, Kind: models.PaymentKindCharge, Amount: 240},
+		&models.Payment{ID: 2, CreatedAt: at, BookingID: 1, Kind: models.PaymentKindPayment, Amount: 240, Method: models.PaymentMethodCash},
+		&models.Invoice{ID: 1, IssuedAt: at, HotelID: 1, Number: 1, BookingID: 1, Folio: models.JSONDocument(`{"total":240}`)},
+		&models.InvoiceSequence{HotelID: 1, LastNumber: 1},
+		&backupUser{Model: gorm.Model{ID: 1, CreatedAt: at, UpdatedAt: at}, Username: "reception", PasswordHash: "00ff", PasswordSalt: "ff00",
+			Role: models.RoleReceptionist},
+		&models.AuditEntry{ID: 1, CreatedAt: at, Actor: "key:1a2b", Action: models.AuditActionCreate, EntityType: models.AuditEntityHotel,
+			EntityID: 1, After: models.JSONDocument(`{"Name":"Carpathian Lodge"}`)},
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+	if err := db.Table("booking_rooms").Create(map[string]interface{}{"booking_id": 1, "room_id": 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Table("user_hotels").Create(map[string]interface{}{"user_id": 1, "hotel_id": 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,16 +1,35 @@
 package repositories
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
 	"go.mod/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BookingRepository interface {
-	GetAll() ([]models.Booking, error)
+	GetAll(includeDeleted bool) ([]models.Booking, error)
+	Stream(includeDeleted bool, fn func(booking *models.Booking) error) error
 	GetByID(id uint) (models.Booking, error)
-	Create(booking *models.Booking) error
-	Update(booking *models.Booking) error
-	Delete(id uint) error
+	GetDeleted() ([]models.Booking, error)
+	GetDeletedByID(id uint) (models.Booking, error)
+	Create(ctx context.Context, booking *models.Booking) error
+	Update(ctx context.Context, booking *models.Booking) error
+	Patch(ctx context.Context, booking *models.Booking, fields []string) error
+	Delete(ctx context.Context, id uint) error
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
+	CheckIn(ctx context.Context, id uint, assignments map[uint]uint) error
+	Cancel(ctx context.Context, id uint, reason string, penalty float32) error
+	NoShow(ctx context.Context, id uint, penalty float32) error
+	GetUnarrived(hotelID uint, arrivingBy models.Date) ([]models.Booking, error)
+	GetOnDate(hotelID uint, date models.Date) ([]models.Booking, error)
+	CheckOut(ctx context.Context, id uint, settle func(booking *models.Booking, ledger []models.Payment) error) error
+	Capacity(booking *models.Booking) (int, bool, error)
 }
 
 type bookingRepository struct {
@@ -21,26 +40,319 @@ func NewBookingRepository(db *gorm.DB) BookingRepository {
 	return &bookingRepository{db: db}
 }
 
-func (r *bookingRepository) GetAll() ([]models.Booking, error) {
+func (r *bookingRepository) GetAll(includeDeleted bool) ([]models.Booking, error) {
 	var bookings []models.Booking
-	err := r.db.Preload("Guest").Preload("Hotel").Preload("BookedRooms").Find(&bookings).Error
+	err := withDeleted(r.db, includeDeleted).Preload("Guest").Preload("Hotel").Preload("BookedRooms").Preload("Reservations.RoomType").Find(&bookings).Error
 	return bookings, err
 }
 
+// Stream walks all bookings in batches without loading the whole table
+func (r *bookingRepository) Stream(includeDeleted bool, fn func(booking *models.Booking) error) error {
+	var batch []models.Booking
+	return withDeleted(r.db, includeDeleted).Preload("Guest").Preload("Hotel").Preload("BookedRooms").Preload("Reservations.RoomType").FindInBatches(&batch, streamBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
 func (r *bookingRepository) GetByID(id uint) (models.Booking, error) {
 	var booking models.Booking
-	err := r.db.Preload("Guest").Preload("Hotel").Preload("BookedRooms").First(&booking, id).Error
+	err := r.db.Preload("Guest").Preload("Hotel.TaxRules").Preload("BookedRooms").Preload("Reservations.RoomType").First(&booking, id).Error
 	return booking, err
 }
 
-func (r *bookingRepository) Create(booking *models.Booking) error {
-	return r.db.Create(booking).Error
+// GetDeleted lists the bookings in the trash
+func (r *bookingRepository) GetDeleted() ([]models.Booking, error) {
+	return findDeleted[models.Booking](r.db)
+}
+
+func (r *bookingRepository) GetDeletedByID(id uint) (models.Booking, error) {
+	return findDeletedByID[models.Booking](r.db, id)
+}
+
+// Create fails with a ConflictError when a reserved room type has no rooms left for the booking's dates
+func (r *bookingRepository) Create(ctx context.Context, booking *models.Booking) error {
+	return withAudit[models.Booking](ctx, r.db, models.AuditActionCreate, models.AuditEntityBooking, func() uint { return booking.ID },
+		func(tx *gorm.DB) error {
+			if err := reserveRoomTypes(tx, booking, booking.Reservations); err != nil {
+				return err
+			}
+			return tx.Create(booking).Error
+		})
+}
+
+// Update keeps the booking's reservations; they are checked again against the new dates
+func (r *bookingRepository) Update(ctx context.Context, booking *models.Booking) error {
+	return withAudit[models.Booking](ctx, r.db, models.AuditActionUpdate, models.AuditEntityBooking, func() uint { return booking.ID },
+		func(tx *gorm.DB) error {
+			if err := r.recheckReservations(tx, booking); err != nil {
+				return err
+			}
+			return tx.Omit("Reservations").Save(booking).Error
+		})
+}
+
+// Patch writes only the listed fields, so columns the client didn't touch keep their values
+func (r *bookingRepository) Patch(ctx context.Context, booking *models.Booking, fields []string) error {
+	return withAudit[models.Booking](ctx, r.db, models.AuditActionUpdate, models.AuditEntityBooking, func() uint { return booking.ID },
+		func(tx *gorm.DB) error {
+			if err := r.recheckReservations(tx, booking); err != nil {
+				return err
+			}
+			return tx.Model(booking).Select(append(fields, "UpdatedAt")).Updates(booking).Error
+		})
+}
+
+func (r *bookingRepository) recheckReservations(tx *gorm.DB, booking *models.Booking) error {
+	var reservations []models.Reservation
+	if err := tx.Where("booking_id = ?", booking.ID).Find(&reservations).Error; err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+	return reserveRoomTypes(tx, booking, reservations)
+}
+
+// Capacity adds up how many people the booking's rooms sleep: the booked rooms plus one room of every
+// reserved type that has no room assigned yet. A stored booking is measured by its stored reservations,
+// since updates don't change them. hasRooms is false when the booking holds no rooms at all.
+func (r *bookingRepository) Capacity(booking *models.Booking) (capacity int, hasRooms bool, err error) {
+	reservations := booking.Reservations
+	if booking.ID != 0 {
+		if err := r.db.Where("booking_id = ?", booking.ID).Find(&reservations).Error; err != nil {
+			return 0, false, err
+		}
+	}
+
+	roomIDs := make([]uint, 0, len(booking.BookedRooms))
+	for _, room := range booking.BookedRooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+	for _, reservation := range reservations {
+		if reservation.RoomID != nil && !slices.Contains(roomIDs, *reservation.RoomID) {
+			roomIDs = append(roomIDs, *reservation.RoomID)
+		}
+	}
+
+	if len(roomIDs) > 0 {
+		var rooms []models.Room
+		if err := r.db.Where("id IN ?", roomIDs).Find(&rooms).Error; err != nil {
+			return 0, false, err
+		}
+		for _, room := range rooms {
+			capacity += room.MaxOccupancy
+		}
+	}
+
+	for _, reservation := range reservations {
+		if reservation.RoomID != nil {
+			continue
+		}
+		var roomType models.RoomType
+		if err := r.db.First(&roomType, reservation.RoomTypeID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return 0, false, err
+		}
+		capacity += roomType.MaxOccupancy
+	}
+	return capacity, len(roomIDs) > 0 || len(reservations) > 0, nil
+}
+
+// CheckIn assigns a room to every reservation of the booking that doesn't have one yet, marks the rooms
+// occupied and the booking checked in. assignments maps reservation IDs to the rooms picked at the desk;
+// the other reservations get any available room of the reserved type.
+func (r *bookingRepository) CheckIn(ctx context.Context, id uint, assignments map[uint]uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var booking models.Booking
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Reservations").First(&booking, id).Error
+		if err != nil {
+			return err
+		}
+		if booking.Status != models.BookingStatusConfirmed {
+			return &ConflictError{Message: fmt.Sprintf("booking %d is %s and can't be checked in", id, booking.Status)}
+		}
+
+		taken := map[uint]bool{}
+		for _, reservation := range booking.Reservations {
+			if reservation.RoomID != nil {
+				continue
+			}
+			room, err := assignRoom(tx, reservation, assignments[reservation.ID], taken)
+			if err != nil {
+				return err
+			}
+			taken[room.ID] = true
+
+			if err := tx.Model(&models.Reservation{}).Where("id = ?", reservation.ID).Update("room_id", room.ID).Error; err != nil {
+				return err
+			}
+			err = tx.Table("booking_rooms").Clauses(clause.OnConflict{DoNothing: true}).Create(map[string]interface{}{
+				"booking_id": id,
+				"room_id":    room.ID,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// Кімнати, заброньовані напряму, теж стають зайнятими
+		var roomIDs []uint
+		if err := tx.Table("booking_rooms").Where("booking_id = ?", id).Pluck("room_id", &roomIDs).Error; err != nil {
+			return err
+		}
+		for _, roomID := range roomIDs {
+			err := auditedChange[models.Room](tx, models.AuditActionUpdate, models.AuditEntityRoom, fixedID(roomID), func(tx *gorm.DB) error {
+				return tx.Model(&models.Room{}).Where("id = ?", roomID).Update("status", models.RoomStatusOccupied).Error
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return auditedChange[models.Booking](tx, models.AuditActionUpdate, models.AuditEntityBooking, fixedID(id), func(tx *gorm.DB) error {
+			return tx.Model(&models.Booking{}).Where("id = ?", id).Update("status", models.BookingStatusCheckedIn).Error
+		})
+	})
+}
+
+// CheckOut marks a checked-in booking checked out and its rooms due for cleaning. settle sees the booking
+// and its ledger while the booking is locked, so no payment or refund can slip in between; its error stops the check-out.
+func (r *bookingRepository) CheckOut(ctx context.Context, id uint, settle func(booking *models.Booking, ledger []models.Payment) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Booking{}, id).Error; err != nil {
+			return err
+		}
+		var booking models.Booking
+		if err := tx.Preload("Hotel.TaxRules").Preload("BookedRooms").Preload("Reservations.RoomType").First(&booking, id).Error; err != nil {
+			return err
+		}
+		if booking.Status != models.BookingStatusCheckedIn {
+			return &ConflictError{Message: fmt.Sprintf("booking %d is %s and can't be checked out", id, booking.Status)}
+		}
+		var ledger []models.Payment
+		if err := tx.Where("booking_id = ?", id).Order("id").Find(&ledger).Error; err != nil {
+			return err
+		}
+		if err := settle(&booking, ledger); err != nil {
+			return err
+		}
+
+		for _, room := range booking.BookedRooms {
+			err := auditedChange[models.Room](tx, models.AuditActionUpdate, models.AuditEntityRoom, fixedID(room.ID), func(tx *gorm.DB) error {
+				return tx.Model(&models.Room{}).Where("id = ?", room.ID).Update("status", models.RoomStatusCleaning).Error
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return auditedChange[models.Booking](tx, models.AuditActionUpdate, models.AuditEntityBooking, fixedID(id), func(tx *gorm.DB) error {
+			return tx.Model(&models.Booking{}).Where("id = ?", id).Update("status", models.BookingStatusCheckedOut).Error
+		})
+	})
+}
+
+// Cancel marks a confirmed booking cancelled with the reason and the penalty charged for it.
+// The booking stays in place, so its history and the penalty can still be looked up.
+func (r *bookingRepository) Cancel(ctx context.Context, id uint, reason string, penalty float32) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, id).Error; err != nil {
+			return err
+		}
+		if booking.Status != models.BookingStatusConfirmed {
+			return &ConflictError{Message: fmt.Sprintf("booking %d is %s and can't be cancelled", id, booking.Status)}
+		}
+
+		return auditedChange[models.Booking](tx, models.AuditActionUpdate, models.AuditEntityBooking, fixedID(id), func(tx *gorm.DB) error {
+			return tx.Model(&models.Booking{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"status":               models.BookingStatusCancelled,
+				"cancelled_at":         tx.NowFunc(),
+				"cancellation_reason":  reason,
+				"cancellation_penalty": penalty,
+			}).Error
+		})
+	})
+}
+
+// NoShow closes a confirmed booking whose guest never arrived and records the penalty charged for it
+func (r *bookingRepository) NoShow(ctx context.Context, id uint, penalty float32) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var booking models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, id).Error; err != nil {
+			return err
+		}
+		if booking.Status != models.BookingStatusConfirmed {
+			return &ConflictError{Message: fmt.Sprintf("booking %d is %s and can't become a no-show", id, booking.Status)}
+		}
+
+		return auditedChange[models.Booking](tx, models.AuditActionUpdate, models.AuditEntityBooking, fixedID(id), func(tx *gorm.DB) error {
+			return tx.Model(&models.Booking{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"status":               models.BookingStatusNoShow,
+				"cancellation_penalty": penalty,
+			}).Error
+		})
+	})
+}
+
+// GetUnarrived lists the hotel's confirmed bookings arriving on or before the date
+func (r *bookingRepository) GetUnarrived(hotelID uint, arrivingBy models.Date) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.Preload("Hotel").Preload("BookedRooms").Preload("Reservations.RoomType").
+		Where("hotel_id = ? AND status = ? AND check_in <= ?", hotelID, models.BookingStatusConfirmed, arrivingBy).
+		Order("check_in, id").Find(&bookings).Error
+	return bookings, err
 }
 
-func (r *bookingRepository) Update(booking *models.Booking) error {
-	return r.db.Save(booking).Error
+// GetOnDate lists the hotel's bookings that arrive, stay or leave on the date
+func (r *bookingRepository) GetOnDate(hotelID uint, date models.Date) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.Preload("BookedRooms").Preload("Reservations.RoomType").
+		Where("hotel_id = ? AND check_in <= ? AND check_out >= ?", hotelID, date, date).
+		Order("check_in, id").Find(&bookings).Error
+	return bookings, err
+}
+
+func (r *bookingRepository) Delete(ctx context.Context, id uint) error {
+	return withAudit[models.Booking](ctx, r.db, models.AuditActionDelete, models.AuditEntityBooking, func() uint { return id },
+		func(tx *gorm.DB) error { return tx.Delete(&models.Booking{}, id).Error })
+}
+
+// Restore fails with ErrParentDeleted while the booking's guest or hotel is in the trash
+func (r *bookingRepository) Restore(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		booking, err := findDeletedByID[models.Booking](tx, id)
+		if err != nil {
+			return err
+		}
+
+		guestLive, err := isLive[models.Guest](tx, booking.GuestID)
+		if err != nil {
+			return err
+		}
+		hotelLive, err := isLive[models.Hotel](tx, booking.HotelID)
+		if err != nil {
+			return err
+		}
+		if !guestLive || !hotelLive {
+			return ErrParentDeleted
+		}
+		return restoreDeleted[models.Booking](tx, models.AuditEntityBooking, id)
+	})
 }
 
-func (r *bookingRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Booking{}, id).Error
+// Purge permanently removes a booking from the trash
+func (r *bookingRepository) Purge(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := findDeletedByID[models.Booking](tx, id); err != nil {
+			return err
+		}
+		return purgeBooking(tx, id)
+	})
 }
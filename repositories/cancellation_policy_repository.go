@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+)
+
+type CancellationPolicyRepository interface {
+	GetAll(includeDeleted bool) ([]models.CancellationPolicy, error)
+	GetByID(id uint) (models.CancellationPolicy, error)
+	GetAnyByID(id uint) (models.CancellationPolicy, error)
+	Create(ctx context.Context, policy *models.CancellationPolicy) error
+	Update(ctx context.Context, policy *models.CancellationPolicy) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type cancellationPolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewCancellationPolicyRepository(db *gorm.DB) CancellationPolicyRepository {
+	return &cancellationPolicyRepository{db: db}
+}
+
+func (r *cancellationPolicyRepository) GetAll(includeDeleted bool) ([]models.CancellationPolicy, error) {
+	var policies []models.CancellationPolicy
+	err := withDeleted(r.db, includeDeleted).Order("hotel_id, name").Find(&policies).Error
+	return policies, err
+}
+
+func (r *cancellationPolicyRepository) GetByID(id uint) (models.CancellationPolicy, error) {
+	var policy models.CancellationPolicy
+	err := r.db.First(&policy, id).Error
+	return policy, err
+}
+
+// GetAnyByID also finds deleted policies: bookings made under a policy keep its terms
+func (r *cancellationPolicyRepository) GetAnyByID(id uint) (models.CancellationPolicy, error) {
+	var policy models.CancellationPolicy
+	err := r.db.Unscoped().First(&policy, id).Error
+	return policy, err
+}
+
+func (r *cancellationPolicyRepository) Create(ctx context.Context, policy *models.CancellationPolicy) error {
+	return withAudit[models.CancellationPolicy](ctx, r.db, models.AuditActionCreate, models.AuditEntityPolicy, func() uint { return policy.ID },
+		func(tx *gorm.DB) error { return tx.Create(policy).Error })
+}
+
+func (r *cancellationPolicyRepository) Update(ctx context.Context, policy *models.CancellationPolicy) error {
+	return withAudit[models.CancellationPolicy](ctx, r.db, models.AuditActionUpdate, models.AuditEntityPolicy, func() uint { return policy.ID },
+		func(tx *gorm.DB) error { return tx.Save(policy).Error })
+}
+
+// Delete moves the policy to the trash and drops it as the default of its hotel.
+// Bookings made under it keep it, so their cancellations are still charged by its terms.
+func (r *cancellationPolicyRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var hotelIDs []uint
+		if err := tx.Model(&models.Hotel{}).Where("cancellation_policy_id = ?", id).Pluck("id", &hotelIDs).Error; err != nil {
+			return err
+		}
+		for _, hotelID := range hotelIDs {
+			err := auditedChange[models.Hotel](tx, models.AuditActionUpdate, models.AuditEntityHotel, fixedID(hotelID), func(tx *gorm.DB) error {
+				return tx.Model(&models.Hotel{}).Where("id = ?", hotelID).Update("cancellation_policy_id", nil).Error
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return softDelete[models.CancellationPolicy](tx, models.AuditEntityPolicy, id, tx.NowFunc())
+	})
+}
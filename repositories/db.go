@@ -1,18 +1,24 @@
 package repositories
 
 import (
+	"fmt"
+	"log"
+	"time"
+
+	"go.mod/models"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"go.mod/models"
-	"log"
 )
 
 var DB *gorm.DB
 
+const streamBatchSize = 500
+
 func InitDB() {
 	var err error
-	dsn := "root:admin@tcp(127.0.0.1:3306)/go_db?charset=utf8mb4&parseTime=True&loc=Local"
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// Моменти часу зберігаються в UTC і в Go, і в сесії MySQL; дати проживання — місцеві дати готелю
+	dsn := "root:admin@tcp(127.0.0.1:3306)/go_db?charset=utf8mb4&parseTime=True&loc=UTC&time_zone=%27%2B00%3A00%27"
+	DB, err = gorm.Open(mysql.Open(dsn), gormConfig())
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -22,10 +28,35 @@ func InitDB() {
 	autoMigrate()
 }
 
+func gormConfig() *gorm.Config {
+	return &gorm.Config{
+		TranslateError: true,
+		NowFunc:        func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// migratedModels are the tables AutoMigrate keeps up to date
+var migratedModels = []interface{}{&models.Hotel{}, &models.CancellationPolicy{}, &models.TaxRule{}, &models.RoomType{}, &models.Room{}, &models.Guest{}, &models.Booking{}, &models.Reservation{}, &models.Payment{}, &models.Invoice{}, &models.InvoiceSequence{}, &models.IdempotencyRecord{}, &models.User{}, &models.AuditEntry{}}
+
 func autoMigrate() {
-	err := DB.AutoMigrate(&models.Hotel{}, &models.Room{}, &models.Guest{}, &models.Booking{})
-	if err != nil {
+	if err := migrate(DB); err != nil {
 		log.Fatalf("Failed to auto-migrate database: %v", err)
 	}
 	log.Println("Database migration completed.")
 }
+
+func migrate(db *gorm.DB) error {
+	if err := checkOrphans(db); err != nil {
+		return err
+	}
+	if err := checkRoomNumbers(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(migratedModels...); err != nil {
+		return err
+	}
+	if err := backfillRoomTypes(db); err != nil {
+		return fmt.Errorf("create room types for existing rooms: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DeleteOptions decides what happens to future bookings that still reference the record being deleted:
+// without Cascade they block the delete, with Cascade they are cancelled in the same transaction.
+// The guest didn't cancel those bookings, so no cancellation policy applies and no penalty is charged;
+// the bookings keep Reason instead.
+type DeleteOptions struct {
+	Cascade bool
+	// Today is the date at the booking's hotel, the first day that counts as the future there
+	Today  func(hotel *models.Hotel) models.Date
+	Reason string
+}
+
+// earliestZone is the zone furthest behind UTC; no hotel's calendar is a day behind it
+var earliestZone = time.FixedZone("UTC-12", -12*60*60)
+
+// DeleteBlockedError lists the bookings that prevent a delete
+type DeleteBlockedError struct {
+	EntityType string
+	Bookings   []models.Booking
+}
+
+func (e *DeleteBlockedError) Error() string {
+	return fmt.Sprintf("%s has %d future booking(s)", e.EntityType, len(e.Bookings))
+}
+
+// futureBookings locks the confirmed and checked-in bookings matching the condition that haven't ended yet
+// by the calendar of their hotel. The query only skips what has ended everywhere, with a day to spare.
+func futureBookings(tx *gorm.DB, today func(hotel *models.Hotel) models.Date, query string, args ...interface{}) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Hotel").
+		Where("status IN ?", activeBookingStatuses).
+		Where("check_out IS NULL OR check_out >= ?", models.DateOf(tx.NowFunc().In(earliestZone).AddDate(0, 0, -1))).
+		Where(query, args...).
+		Order("check_in, id").
+		Find(&bookings).Error
+	if err != nil {
+		return nil, err
+	}
+	return notEnded(bookings, today), nil
+}
+
+// notEnded keeps the bookings that end today or later at their hotel.
+// Bookings without dates are kept, since nothing says they are over.
+func notEnded(bookings []models.Booking, today func(hotel *models.Hotel) models.Date) []models.Booking {
+	kept := bookings[:0]
+	for _, booking := range bookings {
+		if booking.CheckOut.IsZero() || !booking.CheckOut.Before(today(&booking.Hotel).Time) {
+			kept = append(kept, booking)
+		}
+	}
+	return kept
+}
+
+// applyDeletePolicy refuses the delete or cancels the blocking bookings, and returns the cancelled ones
+func applyDeletePolicy(tx *gorm.DB, entityType string, opts DeleteOptions, query string, args ...interface{}) ([]models.Booking, error) {
+	bookings, err := futureBookings(tx, opts.Today, query, args...)
+	if err != nil || len(bookings) == 0 {
+		return nil, err
+	}
+	if !opts.Cascade {
+		return nil, &DeleteBlockedError{EntityType: entityType, Bookings: bookings}
+	}
+	return cancelForDelete(tx, bookings, opts.Reason)
+}
+
+// cancelForDelete cancels the bookings a cascading delete takes with it, with no penalty
+func cancelForDelete(tx *gorm.DB, bookings []models.Booking, reason string) ([]models.Booking, error) {
+	cancelledAt := tx.NowFunc()
+	for i := range bookings {
+		id := bookings[i].ID
+		err := auditedChange[models.Booking](tx, models.AuditActionUpdate, models.AuditEntityBooking, fixedID(id),
+			func(tx *gorm.DB) error {
+				return tx.Model(&models.Booking{}).Where("id = ?", id).Updates(map[string]interface{}{
+					"status":               models.BookingStatusCancelled,
+					"cancelled_at":         cancelledAt,
+					"cancellation_reason":  reason,
+					"cancellation_penalty": 0,
+				}).Error
+			})
+		if err != nil {
+			return nil, err
+		}
+		bookings[i].Status = models.BookingStatusCancelled
+		bookings[i].CancelledAt = &cancelledAt
+		bookings[i].CancellationReason = reason
+		bookings[i].CancellationPenalty = 0
+	}
+	return bookings, nil
+}
+
+// applyCapacityPolicy runs after a room of the type has gone. If the rooms left can't hold the type's
+// future bookings, those that no longer fit block the delete or, with Cascade, are cancelled.
+func applyCapacityPolicy(tx *gorm.DB, roomTypeID uint, opts DeleteOptions) ([]models.Booking, error) {
+	// Блокуємо тип, як і під час бронювання, щоб ніхто не зайняв кімнату паралельно
+	var roomType models.RoomType
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&roomType, roomTypeID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	units, err := countUnits(tx, roomTypeID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookings, err := futureBookings(tx, opts.Today, `id IN (SELECT booking_id FROM reservations WHERE room_type_id = ?
+		UNION SELECT br.booking_id FROM booking_rooms br JOIN rooms rm ON rm.id = br.room_id WHERE rm.room_type_id = ?)`,
+		roomTypeID, roomTypeID)
+	if err != nil {
+		return nil, err
+	}
+	future := map[uint]bool{}
+	var from, to models.Date
+	for _, booking := range bookings {
+		if booking.CheckIn.IsZero() || booking.CheckOut.IsZero() {
+			continue
+		}
+		future[booking.ID] = true
+		if from.IsZero() || booking.CheckIn.Before(from.Time) {
+			from = booking.CheckIn
+		}
+		if booking.CheckOut.After(to.Time) {
+			to = booking.CheckOut
+		}
+	}
+	if len(future) == 0 {
+		return nil, nil
+	}
+
+	stays, err := reservedStays(tx, roomTypeID, from, to, 0)
+	if err != nil {
+		return nil, err
+	}
+	over := overbooked(stays, future, units, from, to)
+	if len(over) == 0 {
+		return nil, nil
+	}
+	var blocking []models.Booking
+	for _, booking := range bookings {
+		if slices.Contains(over, booking.ID) {
+			blocking = append(blocking, booking)
+		}
+	}
+	if !opts.Cascade {
+		return nil, &DeleteBlockedError{EntityType: models.AuditEntityRoom, Bookings: blocking}
+	}
+	return cancelForDelete(tx, blocking, opts.Reason)
+}
+
+// overbooked picks the bookings to let go so that no night between from and to needs more than units rooms.
+// On every night that is over, the most recently made of the future bookings staying that night goes first;
+// stays of other bookings, e.g. ones already over at their hotel, are never picked.
+func overbooked(stays []stay, future map[uint]bool, units int, from, to models.Date) []uint {
+	var over []uint
+	for night := from.Time; night.Before(to.Time); night = night.AddDate(0, 0, 1) {
+		for {
+			taken := staysOn(stays, night)
+			if len(taken) <= units {
+				break
+			}
+			var latest uint
+			for _, s := range taken {
+				if future[s.BookingID] && s.BookingID > latest {
+					latest = s.BookingID
+				}
+			}
+			if latest == 0 {
+				break
+			}
+			over = append(over, latest)
+			stays = slices.DeleteFunc(slices.Clone(stays), func(s stay) bool { return s.BookingID == latest })
+		}
+	}
+	return over
+}
+
+// orphanChecks find rows whose parent no longer exists; the foreign keys can't be added while any remain
+var orphanChecks = []struct {
+	table, column, parent string
+}{
+	{"rooms", "hotel_id", "hotels"},
+	{"bookings", "hotel_id", "hotels"},
+	{"bookings", "guest_id", "guests"},
+	{"booking_rooms", "booking_id", "bookings"},
+	{"booking_rooms", "room_id", "rooms"},
+}
+
+// checkOrphans runs before the migration adds foreign keys, so an old database with dangling
+// references fails with a readable report instead of a bare constraint error
+func checkOrphans(db *gorm.DB) error {
+	var problems []string
+	for _, c := range orphanChecks {
+		if !db.Migrator().HasTable(c.table) || !db.Migrator().HasTable(c.parent) {
+			continue
+		}
+		var count int64
+		err := db.Table(c.table).
+			Where(fmt.Sprintf("%[1]s IS NULL OR %[1]s NOT IN (SELECT id FROM %[2]s)", c.column, c.parent)).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			problems = append(problems, fmt.Sprintf("%d row(s) in %s reference missing %s", count, c.table, c.parent))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("dangling references must be fixed before foreign keys can be added: %v", problems)
+	}
+	return nil
+}
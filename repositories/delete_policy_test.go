@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// At 22:30 UTC on 1 June it is already 2 June in Kyiv but still 1 June in New York
+func TestNotEndedUsesEachHotelsCalendar(t *testing.T) {
+	now := time.Date(2026, 6, 1, 22, 30, 0, 0, time.UTC)
+	today := func(hotel *models.Hotel) models.Date {
+		location, err := time.LoadLocation(hotel.TimeZone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return models.DateOf(now.In(location))
+	}
+	kyiv := models.Hotel{TimeZone: "Europe/Kyiv"}
+	newYork := models.Hotel{TimeZone: "America/New_York"}
+	bookings := []models.Booking{
+		{Model: gorm.Model{ID: 1}, Hotel: kyiv, CheckOut: models.NewDate(2026, 6, 1)},
+		{Model: gorm.Model{ID: 2}, Hotel: newYork, CheckOut: models.NewDate(2026, 6, 1)},
+		{Model: gorm.Model{ID: 3}, Hotel: kyiv, CheckOut: models.NewDate(2026, 6, 2)},
+		{Model: gorm.Model{ID: 4}, Hotel: newYork, CheckOut: models.NewDate(2026, 5, 31)},
+		{Model: gorm.Model{ID: 5}, Hotel: kyiv},
+	}
+
+	var kept []uint
+	for _, booking := range notEnded(bookings, today) {
+		kept = append(kept, booking.ID)
+	}
+	if want := []uint{2, 3, 5}; !slices.Equal(kept, want) {
+		t.Errorf("kept bookings %v, want %v", kept, want)
+	}
+}
+
+func TestOverbookedLetsTheLatestBookingsGo(t *testing.T) {
+	night := func(day int) models.Date { return models.NewDate(2026, 6, day) }
+	stays := []stay{
+		{BookingID: 1, CheckIn: night(1), CheckOut: night(4)},
+		{BookingID: 2, CheckIn: night(2), CheckOut: night(3)},
+		{BookingID: 3, CheckIn: night(3), CheckOut: night(5)},
+		// Бронювання 4 бере дві кімнати типу
+		{BookingID: 4, CheckIn: night(6), CheckOut: night(7)},
+		{BookingID: 4, CheckIn: night(6), CheckOut: night(7)},
+		// 9 вже завершилося в календарі свого готелю, тож його не скасовують навіть без кімнат
+		{BookingID: 9, CheckIn: night(5), CheckOut: night(6)},
+	}
+	future := map[uint]bool{1: true, 2: true, 3: true, 4: true}
+
+	tests := []struct {
+		units int
+		want  []uint
+	}{
+		{3, nil},
+		{2, nil},
+		{1, []uint{2, 3, 4}},
+		{0, []uint{1, 2, 3, 4}},
+	}
+	for _, tc := range tests {
+		got := overbooked(stays, future, tc.units, night(1), night(7))
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("with %d room(s) overbooked = %v, want %v", tc.units, got, tc.want)
+		}
+	}
+}
+
+// A booking cancelled because its hotel, guest or room went away wasn't cancelled by the guest,
+// so even a policy without a free period charges nothing
+func TestCascadeDeleteChargesNoPenalty(t *testing.T) {
+	dsn := os.Getenv("GO_API_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GO_API_TEST_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), gormConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetDatabase(t, db)
+
+	policyID := uint(1)
+	today := models.DateOf(time.Now().UTC())
+	rows := []interface{}{
+		&models.Hotel{Model: gorm.Model{ID: 1}, Name: "Carpathian Lodge", CancellationPolicyID: &policyID},
+		&models.CancellationPolicy{Model: gorm.Model{ID: 1}, HotelID: 1, Name: "Strict", PenaltyType: models.PenaltyTypeFirstNight},
+		&models.Guest{Model: gorm.Model{ID: 1}, Name: "Olena Koval", MobileNumber: "+380501234567"},
+		&models.Booking{Model: gorm.Model{ID: 1}, GuestID: 1, HotelID: 1, Status: models.BookingStatusConfirmed, Adults: 1,
+			CheckIn: models.Date{Time: today.AddDate(0, 0, 1)}, CheckOut: models.Date{Time: today.AddDate(0, 0, 3)}},
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+
+	opts := DeleteOptions{Today: func(*models.Hotel) models.Date { return today }, Reason: "the guest record was removed"}
+	if _, err := NewGuestRepository(db).Delete(context.Background(), 1, opts); err == nil {
+		t.Fatal("delete without cascade succeeded despite a future booking")
+	}
+	opts.Cascade = true
+	cancelled, err := NewGuestRepository(db).Delete(context.Background(), 1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 1 {
+		t.Fatalf("cancelled %d bookings, want 1", len(cancelled))
+	}
+
+	var stored models.Booking
+	if err := db.Unscoped().First(&stored, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.BookingStatusCancelled || stored.CancellationPenalty != 0 || stored.CancellationReason != opts.Reason {
+		t.Errorf("booking is %s with a penalty of %.2f and reason %q, want cancelled with no penalty and %q",
+			stored.Status, stored.CancellationPenalty, stored.CancellationReason, opts.Reason)
+	}
+}
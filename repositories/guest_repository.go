@@ -1,16 +1,25 @@
 package repositories
 
 import (
+	"context"
+
 	"go.mod/models"
 	"gorm.io/gorm"
 )
 
 type GuestRepository interface {
-	GetAll() ([]models.Guest, error)
+	GetAll(includeDeleted bool) ([]models.Guest, error)
+	Stream(includeDeleted bool, fn func(guest *models.Guest) error) error
 	GetByID(id uint) (models.Guest, error)
-	Create(guest *models.Guest) error
-	Update(guest *models.Guest) error
-	Delete(id uint) error
+	GetDeleted() ([]models.Guest, error)
+	GetDeletedByID(id uint) (models.Guest, error)
+	HotelIDs(id uint, includeDeleted bool) ([]uint, error)
+	Create(ctx context.Context, guest *models.Guest) error
+	Update(ctx context.Context, guest *models.Guest) error
+	Patch(ctx context.Context, guest *models.Guest, fields []string) error
+	Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
 }
 
 type guestRepository struct {
@@ -21,26 +30,115 @@ func NewGuestRepository(db *gorm.DB) GuestRepository {
 	return &guestRepository{db: db}
 }
 
-func (r *guestRepository) GetAll() ([]models.Guest, error) {
+func (r *guestRepository) GetAll(includeDeleted bool) ([]models.Guest, error) {
 	var guests []models.Guest
-	err := r.db.Find(&guests).Error
+	err := withDeleted(r.db, includeDeleted).Find(&guests).Error
 	return guests, err
 }
 
+// Stream walks all guests in batches without loading the whole table
+func (r *guestRepository) Stream(includeDeleted bool, fn func(guest *models.Guest) error) error {
+	var batch []models.Guest
+	return withDeleted(r.db, includeDeleted).FindInBatches(&batch, streamBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
 func (r *guestRepository) GetByID(id uint) (models.Guest, error) {
 	var guest models.Guest
 	err := r.db.First(&guest, id).Error
 	return guest, err
 }
 
-func (r *guestRepository) Create(guest *models.Guest) error {
-	return r.db.Create(guest).Error
+// GetDeleted lists the guests in the trash
+func (r *guestRepository) GetDeleted() ([]models.Guest, error) {
+	return findDeleted[models.Guest](r.db)
+}
+
+func (r *guestRepository) GetDeletedByID(id uint) (models.Guest, error) {
+	return findDeletedByID[models.Guest](r.db, id)
+}
+
+// HotelIDs lists the hotels the guest has bookings at; includeDeleted counts the bookings in the trash too
+func (r *guestRepository) HotelIDs(id uint, includeDeleted bool) ([]uint, error) {
+	var hotelIDs []uint
+	err := withDeleted(r.db, includeDeleted).Model(&models.Booking{}).
+		Where("guest_id = ?", id).Distinct().Pluck("hotel_id", &hotelIDs).Error
+	return hotelIDs, err
+}
+
+func (r *guestRepository) Create(ctx context.Context, guest *models.Guest) error {
+	return withAudit[models.Guest](ctx, r.db, models.AuditActionCreate, models.AuditEntityGuest, func() uint { return guest.ID },
+		func(tx *gorm.DB) error { return tx.Create(guest).Error })
 }
 
-func (r *guestRepository) Update(guest *models.Guest) error {
-	return r.db.Save(guest).Error
+func (r *guestRepository) Update(ctx context.Context, guest *models.Guest) error {
+	return withAudit[models.Guest](ctx, r.db, models.AuditActionUpdate, models.AuditEntityGuest, func() uint { return guest.ID },
+		func(tx *gorm.DB) error { return tx.Save(guest).Error })
+}
+
+// Patch writes only the listed fields, so columns the client didn't touch keep their values
+func (r *guestRepository) Patch(ctx context.Context, guest *models.Guest, fields []string) error {
+	return withAudit[models.Guest](ctx, r.db, models.AuditActionUpdate, models.AuditEntityGuest, func() uint { return guest.ID },
+		func(tx *gorm.DB) error {
+			return tx.Model(guest).Select(append(fields, "UpdatedAt")).Updates(guest).Error
+		})
+}
+
+// Delete applies the delete policy to the guest's future bookings, then moves the guest
+// to the trash together with their bookings. It returns the bookings it cancelled.
+func (r *guestRepository) Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error) {
+	var cancelled []models.Booking
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		cancelled, err = applyDeletePolicy(tx, models.AuditEntityGuest, opts, "guest_id = ?", id)
+		if err != nil {
+			return err
+		}
+
+		at := tx.NowFunc()
+		if err := softDelete[models.Guest](tx, models.AuditEntityGuest, id, at); err != nil {
+			return err
+		}
+		return softDeleteWhere[models.Booking](tx, models.AuditEntityBooking, at, "guest_id = ?", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// Restore brings the guest back with the bookings deleted together with them.
+// Bookings at hotels that are still in the trash stay there.
+func (r *guestRepository) Restore(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		guest, err := findDeletedByID[models.Guest](tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := restoreDeleted[models.Guest](tx, models.AuditEntityGuest, id); err != nil {
+			return err
+		}
+		return restoreWhere[models.Booking](tx, models.AuditEntityBooking, guest.DeletedAt.Time,
+			"guest_id = ? AND hotel_id IN (?)", id, tx.Model(&models.Hotel{}).Select("id"))
+	})
 }
 
-func (r *guestRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Guest{}, id).Error
+// Purge permanently removes a guest from the trash together with all of their bookings
+func (r *guestRepository) Purge(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := findDeletedByID[models.Guest](tx, id); err != nil {
+			return err
+		}
+		if err := purgeBookingsWhere(tx, "guest_id = ?", id); err != nil {
+			return err
+		}
+		return purgeRecord[models.Guest](tx, models.AuditEntityGuest, id)
+	})
 }
@@ -1,16 +1,28 @@
 package repositories
 
 import (
+	"context"
+	"math"
+
 	"go.mod/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type HotelRepository interface {
-	GetAll() ([]models.Hotel, error)
+	GetAll(includeDeleted bool) ([]models.Hotel, error)
+	Stream(includeDeleted bool, fn func(hotel *models.Hotel) error) error
 	GetByID(id uint) (models.Hotel, error)
-	Create(hotel *models.Hotel) error
-	Update(hotel *models.Hotel) error
-	Delete(id uint) error
+	GetAllBrief() ([]models.Hotel, error)
+	GetNear(latitude, longitude, radiusKm float64, includeDeleted bool) ([]models.Hotel, error)
+	GetDeleted() ([]models.Hotel, error)
+	GetDeletedByID(id uint) (models.Hotel, error)
+	Create(ctx context.Context, hotel *models.Hotel) error
+	Update(ctx context.Context, hotel *models.Hotel) error
+	Patch(ctx context.Context, hotel *models.Hotel, fields []string) error
+	Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
 }
 
 type hotelRepository struct {
@@ -21,26 +33,235 @@ func NewHotelRepository(db *gorm.DB) HotelRepository {
 	return &hotelRepository{db: db}
 }
 
-func (r *hotelRepository) GetAll() ([]models.Hotel, error) {
+func (r *hotelRepository) GetAll(includeDeleted bool) ([]models.Hotel, error) {
 	var hotels []models.Hotel
-	err := r.db.Find(&hotels).Error
+	err := withDeleted(r.db, includeDeleted).Preload("Rooms").Preload("RoomTypes").Preload("TaxRules").Find(&hotels).Error
 	return hotels, err
 }
 
+// Stream walks all hotels in batches without loading the whole table
+func (r *hotelRepository) Stream(includeDeleted bool, fn func(hotel *models.Hotel) error) error {
+	var batch []models.Hotel
+	return withDeleted(r.db, includeDeleted).Preload("Rooms").Preload("RoomTypes").Preload("TaxRules").FindInBatches(&batch, streamBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
 func (r *hotelRepository) GetByID(id uint) (models.Hotel, error) {
 	var hotel models.Hotel
 	err := r.db.First(&hotel, id).Error
 	return hotel, err
 }
 
-func (r *hotelRepository) Create(hotel *models.Hotel) error {
-	return r.db.Create(hotel).Error
+// GetAllBrief lists the hotels without their rooms, room types and tax rules
+func (r *hotelRepository) GetAllBrief() ([]models.Hotel, error) {
+	var hotels []models.Hotel
+	err := r.db.Order("id").Find(&hotels).Error
+	return hotels, err
+}
+
+// GetNear returns the hotels inside the box of latitudes and longitudes around a circle of radiusKm.
+// The box holds the whole circle, so callers measure the exact distance to drop the corners.
+func (r *hotelRepository) GetNear(latitude, longitude, radiusKm float64, includeDeleted bool) ([]models.Hotel, error) {
+	south, north, longitudes := boundingBox(latitude, longitude, radiusKm)
+	query := withDeleted(r.db, includeDeleted).Preload("Rooms").Preload("RoomTypes").Preload("TaxRules").
+		Where("latitude BETWEEN ? AND ?", south, north)
+	switch len(longitudes) {
+	case 1:
+		query = query.Where("longitude BETWEEN ? AND ?", longitudes[0][0], longitudes[0][1])
+	case 2:
+		query = query.Where("(longitude BETWEEN ? AND ? OR longitude BETWEEN ? AND ?)",
+			longitudes[0][0], longitudes[0][1], longitudes[1][0], longitudes[1][1])
+	}
+
+	var hotels []models.Hotel
+	err := query.Where("longitude IS NOT NULL").Find(&hotels).Error
+	return hotels, err
+}
+
+// boundingBox returns the latitudes and the west-to-east longitude ranges of a box around the circle of radiusKm.
+// A box crossing the ±180° meridian is split in two ranges; no ranges means every longitude.
+func boundingBox(latitude, longitude, radiusKm float64) (south, north float64, longitudes [][2]float64) {
+	// The mean Earth radius the services measure distances with; a box drawn on a larger one would cut off the edge of the circle
+	const earthRadiusKm = 6371.0
+	kmPerDegree := earthRadiusKm * math.Pi / 180
+	latDelta := radiusKm / kmPerDegree
+	south, north = math.Max(latitude-latDelta, -90), math.Min(latitude+latDelta, 90)
+
+	// Біля полюсів коло охоплює всі довготи
+	if latitude+latDelta >= 90 || latitude-latDelta <= -90 {
+		return south, north, nil
+	}
+	cosLat := math.Cos((math.Abs(latitude) + latDelta) * math.Pi / 180)
+	lngDelta := radiusKm / (kmPerDegree * cosLat)
+	if lngDelta >= 180 {
+		return south, north, nil
+	}
+	west, east := longitude-lngDelta, longitude+lngDelta
+	switch {
+	case west < -180:
+		return south, north, [][2]float64{{west + 360, 180}, {-180, east}}
+	case east > 180:
+		return south, north, [][2]float64{{west, 180}, {-180, east - 360}}
+	default:
+		return south, north, [][2]float64{{west, east}}
+	}
+}
+
+// GetDeleted lists the hotels in the trash
+func (r *hotelRepository) GetDeleted() ([]models.Hotel, error) {
+	return findDeleted[models.Hotel](r.db)
+}
+
+func (r *hotelRepository) GetDeletedByID(id uint) (models.Hotel, error) {
+	return findDeletedByID[models.Hotel](r.db, id)
+}
+
+// Create and Update write the hotel row only; rooms, room types and tax rules have endpoints of their own
+func (r *hotelRepository) Create(ctx context.Context, hotel *models.Hotel) error {
+	return withAudit[models.Hotel](ctx, r.db, models.AuditActionCreate, models.AuditEntityHotel, func() uint { return hotel.ID },
+		func(tx *gorm.DB) error { return tx.Omit(clause.Associations).Create(hotel).Error })
+}
+
+func (r *hotelRepository) Update(ctx context.Context, hotel *models.Hotel) error {
+	return withAudit[models.Hotel](ctx, r.db, models.AuditActionUpdate, models.AuditEntityHotel, func() uint { return hotel.ID },
+		func(tx *gorm.DB) error { return tx.Omit(clause.Associations).Save(hotel).Error })
+}
+
+// Patch writes only the listed fields, so columns the client didn't touch keep their values
+func (r *hotelRepository) Patch(ctx context.Context, hotel *models.Hotel, fields []string) error {
+	return withAudit[models.Hotel](ctx, r.db, models.AuditActionUpdate, models.AuditEntityHotel, func() uint { return hotel.ID },
+		func(tx *gorm.DB) error {
+			return tx.Model(hotel).Select(append(fields, "UpdatedAt")).Updates(hotel).Error
+		})
+}
+
+// Delete applies the delete policy to the hotel's future bookings, then moves the hotel
+// to the trash together with its rooms, room types, cancellation policies, tax rules and bookings. It returns the bookings it cancelled.
+func (r *hotelRepository) Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error) {
+	var cancelled []models.Booking
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		cancelled, err = applyDeletePolicy(tx, models.AuditEntityHotel, opts, "hotel_id = ?", id)
+		if err != nil {
+			return err
+		}
+
+		at := tx.NowFunc()
+		if err := softDelete[models.Hotel](tx, models.AuditEntityHotel, id, at); err != nil {
+			return err
+		}
+		if err := softDeleteWhere[models.Room](tx, models.AuditEntityRoom, at, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		if err := softDeleteWhere[models.RoomType](tx, models.AuditEntityRoomType, at, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		if err := softDeleteWhere[models.CancellationPolicy](tx, models.AuditEntityPolicy, at, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		if err := softDeleteWhere[models.TaxRule](tx, models.AuditEntityTaxRule, at, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		return softDeleteWhere[models.Booking](tx, models.AuditEntityBooking, at, "hotel_id = ?", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
 }
 
-func (r *hotelRepository) Update(hotel *models.Hotel) error {
-	return r.db.Save(hotel).Error
+// Restore brings the hotel back with the rooms, room types, cancellation policies, tax rules and bookings deleted together with it.
+// Bookings of guests that are still in the trash stay there.
+func (r *hotelRepository) Restore(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		hotel, err := findDeletedByID[models.Hotel](tx, id)
+		if err != nil {
+			return err
+		}
+		deletedAt := hotel.DeletedAt.Time
+
+		if err := restoreDeleted[models.Hotel](tx, models.AuditEntityHotel, id); err != nil {
+			return err
+		}
+		if err := restoreWhere[models.RoomType](tx, models.AuditEntityRoomType, deletedAt, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		if err := restoreWhere[models.Room](tx, models.AuditEntityRoom, deletedAt, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		if err := restoreWhere[models.CancellationPolicy](tx, models.AuditEntityPolicy, deletedAt, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		if err := restoreWhere[models.TaxRule](tx, models.AuditEntityTaxRule, deletedAt, "hotel_id = ?", id); err != nil {
+			return err
+		}
+		return restoreWhere[models.Booking](tx, models.AuditEntityBooking, deletedAt,
+			"hotel_id = ? AND guest_id IN (?)", id, tx.Model(&models.Guest{}).Select("id"))
+	})
 }
 
-func (r *hotelRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Hotel{}, id).Error
+// Purge permanently removes a hotel from the trash with all of its rooms, room types, cancellation policies, tax rules, bookings and staff assignments
+func (r *hotelRepository) Purge(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := findDeletedByID[models.Hotel](tx, id); err != nil {
+			return err
+		}
+		if err := purgeBookingsWhere(tx, "hotel_id = ?", id); err != nil {
+			return err
+		}
+
+		roomIDs, err := purgeIDs[models.Room](tx, "hotel_id = ?", id)
+		if err != nil {
+			return err
+		}
+		for _, roomID := range roomIDs {
+			if err := purgeRoom(tx, roomID); err != nil {
+				return err
+			}
+		}
+
+		roomTypeIDs, err := purgeIDs[models.RoomType](tx, "hotel_id = ?", id)
+		if err != nil {
+			return err
+		}
+		for _, roomTypeID := range roomTypeIDs {
+			if err := purgeRecord[models.RoomType](tx, models.AuditEntityRoomType, roomTypeID); err != nil {
+				return err
+			}
+		}
+
+		policyIDs, err := purgeIDs[models.CancellationPolicy](tx, "hotel_id = ?", id)
+		if err != nil {
+			return err
+		}
+		for _, policyID := range policyIDs {
+			if err := purgeRecord[models.CancellationPolicy](tx, models.AuditEntityPolicy, policyID); err != nil {
+				return err
+			}
+		}
+
+		taxRuleIDs, err := purgeIDs[models.TaxRule](tx, "hotel_id = ?", id)
+		if err != nil {
+			return err
+		}
+		for _, taxRuleID := range taxRuleIDs {
+			if err := purgeRecord[models.TaxRule](tx, models.AuditEntityTaxRule, taxRuleID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("DELETE FROM user_hotels WHERE hotel_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM invoice_sequences WHERE hotel_id = ?", id).Error; err != nil {
+			return err
+		}
+		return purgeRecord[models.Hotel](tx, models.AuditEntityHotel, id)
+	})
 }
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"math"
+	"testing"
+)
+
+// destination is the point radiusKm away from a start point in the bearing's direction, in degrees
+func destination(latitude, longitude, bearing, radiusKm float64) (float64, float64) {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	lat, lng, angle, course := toRad(latitude), toRad(longitude), radiusKm/earthRadiusKm, toRad(bearing)
+	lat2 := math.Asin(math.Sin(lat)*math.Cos(angle) + math.Cos(lat)*math.Sin(angle)*math.Cos(course))
+	lng2 := lng + math.Atan2(math.Sin(course)*math.Sin(angle)*math.Cos(lat), math.Cos(angle)-math.Sin(lat)*math.Sin(lat2))
+	// Довгота назад у проміжок від -180 до 180
+	return lat2 * 180 / math.Pi, math.Remainder(lng2*180/math.Pi, 360)
+}
+
+func inBox(south, north float64, longitudes [][2]float64, latitude, longitude float64) bool {
+	if latitude < south || latitude > north {
+		return false
+	}
+	if longitudes == nil {
+		return true
+	}
+	for _, lngs := range longitudes {
+		if longitude >= lngs[0] && longitude <= lngs[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBoundingBox(t *testing.T) {
+	tests := []struct {
+		name                string
+		latitude, longitude float64
+		radiusKm            float64
+		wantRanges          int
+	}{
+		{"Kyiv", 50.4501, 30.5234, 25, 1},
+		{"equator", 0, 0, 500, 1},
+		{"Fiji, east of the antimeridian", -17.7, 179.9, 50, 2},
+		{"Samoa, west of the antimeridian", -13.8, -179.8, 100, 2},
+		{"near the north pole", 89.9, 45, 50, 0},
+		{"near the south pole", -89.5, -120, 100, 0},
+		{"far north, still a box", 80, 10, 100, 1},
+		{"the radius reaches all the way round", 60, 0, 10000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			south, north, longitudes := boundingBox(tt.latitude, tt.longitude, tt.radiusKm)
+			if len(longitudes) != tt.wantRanges {
+				t.Fatalf("longitudes = %v, want %d ranges", longitudes, tt.wantRanges)
+			}
+			if south < -90 || north > 90 || south > tt.latitude || north < tt.latitude {
+				t.Errorf("latitudes %.4f to %.4f don't hold %.4f or leave -90 to 90", south, north, tt.latitude)
+			}
+			for _, lngs := range longitudes {
+				if lngs[0] < -180 || lngs[1] > 180 || lngs[0] > lngs[1] {
+					t.Errorf("longitude range %v isn't west to east within -180 to 180", lngs)
+				}
+			}
+			if !inBox(south, north, longitudes, tt.latitude, tt.longitude) {
+				t.Errorf("the box doesn't hold its own centre")
+			}
+			// Точки на метр всередині кола: на самому колі результат залежить від округлення
+			for bearing := 0.0; bearing < 360; bearing += 5 {
+				lat, lng := destination(tt.latitude, tt.longitude, bearing, tt.radiusKm-0.001)
+				if !inBox(south, north, longitudes, lat, lng) {
+					t.Errorf("%.4f, %.4f on the circle at bearing %g° is outside the box %.4f to %.4f, %v",
+						lat, lng, bearing, south, north, longitudes)
+				}
+			}
+		})
+	}
+}
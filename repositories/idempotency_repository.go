@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository interface {
+	// Reserve stores record if the key is free and returns (nil, nil);
+	// otherwise it returns the record that already holds the key
+	Reserve(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	Complete(identity, key string, status int, contentType string, body []byte) error
+	Release(identity, key string) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+func (r *idempotencyRepository) Reserve(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	// Друга спроба потрібна, якщо знайдений запис уже прострочений
+	for attempt := 0; attempt < 2; attempt++ {
+		result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var existing models.IdempotencyRecord
+		err := r.db.Where(&models.IdempotencyRecord{Identity: record.Identity, Key: record.Key}).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if existing.ExpiresAt.After(record.CreatedAt) {
+			return &existing, nil
+		}
+		if err := r.Release(record.Identity, record.Key); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("idempotency key is being reserved concurrently")
+}
+
+func (r *idempotencyRepository) Complete(identity, key string, status int, contentType string, body []byte) error {
+	return r.db.Model(&models.IdempotencyRecord{}).
+		Where(&models.IdempotencyRecord{Identity: identity, Key: key}).
+		Updates(map[string]interface{}{"status": status, "content_type": contentType, "body": body}).Error
+}
+
+func (r *idempotencyRepository) Release(identity, key string) error {
+	return r.db.Where(&models.IdempotencyRecord{Identity: identity, Key: key}).Delete(&models.IdempotencyRecord{}).Error
+}
+
+func (r *idempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
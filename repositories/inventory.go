@@ -0,0 +1,187 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConflictError is returned when the request is valid but can't be carried out in the current state,
+// e.g. a room type is sold out or a cancelled booking is checked in
+type ConflictError struct {
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// RoomTypeAvailability counts the rooms of one type that are free on every night of a stay
+type RoomTypeAvailability struct {
+	RoomType  models.RoomType `json:"room_type"`
+	Total     int             `json:"total"`
+	Reserved  int             `json:"reserved"`
+	Available int             `json:"available"`
+}
+
+// activeBookingStatuses are the statuses that hold rooms
+var activeBookingStatuses = []string{models.BookingStatusConfirmed, models.BookingStatusCheckedIn}
+
+// countUnits counts the rooms of the type that can be sold; rooms out of service don't count
+func countUnits(tx *gorm.DB, roomTypeID uint) (int, error) {
+	var count int64
+	err := tx.Model(&models.Room{}).
+		Where("room_type_id = ? AND status <> ?", roomTypeID, models.RoomStatusOutOfService).
+		Count(&count).Error
+	return int(count), err
+}
+
+// stay is one room of a type held by a booking for its dates
+type stay struct {
+	BookingID uint
+	CheckIn   models.Date
+	CheckOut  models.Date
+}
+
+// reservedStays lists the rooms of the type held between checkIn and checkOut, one stay per room.
+// Besides reservations it counts rooms booked directly, as bookings made before room types existed do.
+func reservedStays(tx *gorm.DB, roomTypeID uint, checkIn, checkOut models.Date, excludeBookingID uint) ([]stay, error) {
+	var stays []stay
+	err := tx.Raw(`
+		SELECT b.id AS booking_id, b.check_in, b.check_out FROM reservations r
+		JOIN bookings b ON b.id = r.booking_id
+		WHERE r.room_type_id = ? AND b.deleted_at IS NULL AND b.status IN ? AND b.id <> ?
+			AND b.check_in < ? AND b.check_out > ?
+		UNION ALL
+		SELECT b.id AS booking_id, b.check_in, b.check_out FROM booking_rooms br
+		JOIN bookings b ON b.id = br.booking_id
+		JOIN rooms rm ON rm.id = br.room_id
+		WHERE rm.room_type_id = ? AND b.deleted_at IS NULL AND b.status IN ? AND b.id <> ?
+			AND b.check_in < ? AND b.check_out > ?
+			AND NOT EXISTS (SELECT 1 FROM reservations r WHERE r.booking_id = br.booking_id AND r.room_id = br.room_id)`,
+		roomTypeID, activeBookingStatuses, excludeBookingID, checkOut, checkIn,
+		roomTypeID, activeBookingStatuses, excludeBookingID, checkOut, checkIn,
+	).Scan(&stays).Error
+	return stays, err
+}
+
+// staysOn returns the stays that take a room on the night
+func staysOn(stays []stay, night time.Time) []stay {
+	var taken []stay
+	for _, s := range stays {
+		if !night.Before(s.CheckIn.Time) && night.Before(s.CheckOut.Time) {
+			taken = append(taken, s)
+		}
+	}
+	return taken
+}
+
+// peakReserved returns the largest number of rooms of the type taken on any night between checkIn and checkOut
+func peakReserved(tx *gorm.DB, roomTypeID uint, checkIn, checkOut models.Date, excludeBookingID uint) (int, error) {
+	stays, err := reservedStays(tx, roomTypeID, checkIn, checkOut, excludeBookingID)
+	if err != nil {
+		return 0, err
+	}
+
+	peak := 0
+	for night := checkIn.Time; night.Before(checkOut.Time); night = night.AddDate(0, 0, 1) {
+		peak = max(peak, len(staysOn(stays, night)))
+	}
+	return peak, nil
+}
+
+// availability reports how many rooms of the type are free for the whole stay
+func availability(tx *gorm.DB, roomType models.RoomType, checkIn, checkOut models.Date, excludeBookingID uint) (RoomTypeAvailability, error) {
+	total, err := countUnits(tx, roomType.ID)
+	if err != nil {
+		return RoomTypeAvailability{}, err
+	}
+	reserved, err := peakReserved(tx, roomType.ID, checkIn, checkOut, excludeBookingID)
+	if err != nil {
+		return RoomTypeAvailability{}, err
+	}
+	available := total - reserved
+	if available < 0 {
+		available = 0
+	}
+	return RoomTypeAvailability{RoomType: roomType, Total: total, Reserved: reserved, Available: available}, nil
+}
+
+// reserveRoomTypes checks that the booking's hotel still has enough rooms of every reserved type for its dates.
+// The room type rows are locked, so two bookings can't take the last room at the same time.
+func reserveRoomTypes(tx *gorm.DB, booking *models.Booking, reservations []models.Reservation) error {
+	if booking.Status != "" && booking.Status != models.BookingStatusConfirmed && booking.Status != models.BookingStatusCheckedIn {
+		return nil
+	}
+
+	requested := map[uint]int{}
+	var order []uint
+	for _, reservation := range reservations {
+		if requested[reservation.RoomTypeID] == 0 {
+			order = append(order, reservation.RoomTypeID)
+		}
+		requested[reservation.RoomTypeID]++
+	}
+
+	for _, roomTypeID := range order {
+		var roomType models.RoomType
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&roomType, roomTypeID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && roomType.HotelID != booking.HotelID) {
+			return &ConflictError{Message: fmt.Sprintf("room type %d does not exist in hotel %d", roomTypeID, booking.HotelID)}
+		}
+		if err != nil {
+			return err
+		}
+
+		free, err := availability(tx, roomType, booking.CheckIn, booking.CheckOut, booking.ID)
+		if err != nil {
+			return err
+		}
+		if free.Available < requested[roomTypeID] {
+			return &ConflictError{Message: fmt.Sprintf("only %d %q room(s) left for %s - %s, %d requested",
+				free.Available, roomType.Name, booking.CheckIn, booking.CheckOut, requested[roomTypeID])}
+		}
+	}
+	return nil
+}
+
+// assignRoom picks the room for a reservation at check-in: the one the receptionist chose,
+// or else the first available room of the reserved type that isn't already taken
+func assignRoom(tx *gorm.DB, reservation models.Reservation, chosen uint, taken map[uint]bool) (models.Room, error) {
+	var room models.Room
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	if chosen != 0 {
+		err := query.First(&room, chosen).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return room, &ConflictError{Message: fmt.Sprintf("room %d does not exist", chosen)}
+		}
+		if err != nil {
+			return room, err
+		}
+		switch {
+		case room.RoomTypeID == nil || *room.RoomTypeID != reservation.RoomTypeID:
+			return room, &ConflictError{Message: fmt.Sprintf("room %d is not of the reserved room type %d", chosen, reservation.RoomTypeID)}
+		case room.Status != models.RoomStatusAvailable || taken[room.ID]:
+			return room, &ConflictError{Message: fmt.Sprintf("room %d is not available", chosen)}
+		}
+		return room, nil
+	}
+
+	query = query.Where("room_type_id = ? AND status = ?", reservation.RoomTypeID, models.RoomStatusAvailable)
+	if len(taken) > 0 {
+		ids := make([]uint, 0, len(taken))
+		for id := range taken {
+			ids = append(ids, id)
+		}
+		query = query.Where("id NOT IN ?", ids)
+	}
+	err := query.Order("floor, number, id").First(&room).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return room, &ConflictError{Message: fmt.Sprintf("no available room of room type %d to assign", reservation.RoomTypeID)}
+	}
+	return room, err
+}
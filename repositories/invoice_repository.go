@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvoiced is returned when purging a booking that has an invoice; issued invoices are kept for good
+var ErrInvoiced = errors.New("an invoice has been issued for the booking")
+
+// FolioBuilder turns a locked booking and its ledger into the folio of its invoice
+type FolioBuilder func(booking *models.Booking, ledger []models.Payment, number uint, issuedAt time.Time) (models.JSONDocument, error)
+
+type InvoiceRepository interface {
+	GetByBooking(bookingID uint) (models.Invoice, error)
+	Issue(ctx context.Context, bookingID uint, build FolioBuilder) (invoice models.Invoice, created bool, err error)
+}
+
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+func (r *invoiceRepository) GetByBooking(bookingID uint) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.Where("booking_id = ?", bookingID).First(&invoice).Error
+	return invoice, err
+}
+
+// Issue returns the booking's invoice, issuing it first if there is none. The number is taken from the
+// hotel's sequence in the same transaction, so an issue that fails leaves no gap in the numbering.
+func (r *invoiceRepository) Issue(ctx context.Context, bookingID uint, build FolioBuilder) (models.Invoice, bool, error) {
+	var invoice models.Invoice
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Booking{}, bookingID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("booking_id = ?", bookingID).First(&invoice).Error
+		if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var booking models.Booking
+		err = tx.Preload("Guest").Preload("Hotel.TaxRules").Preload("BookedRooms").Preload("Reservations.RoomType").First(&booking, bookingID).Error
+		if err != nil {
+			return err
+		}
+		var ledger []models.Payment
+		if err := tx.Where("booking_id = ?", bookingID).Order("id").Find(&ledger).Error; err != nil {
+			return err
+		}
+
+		sequence := models.InvoiceSequence{HotelID: booking.HotelID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&sequence).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sequence, "hotel_id = ?", booking.HotelID).Error; err != nil {
+			return err
+		}
+		sequence.LastNumber++
+		err = tx.Model(&models.InvoiceSequence{}).Where("hotel_id = ?", booking.HotelID).Update("last_number", sequence.LastNumber).Error
+		if err != nil {
+			return err
+		}
+
+		invoice = models.Invoice{
+			IssuedAt:  tx.NowFunc(),
+			HotelID:   booking.HotelID,
+			Number:    sequence.LastNumber,
+			BookingID: bookingID,
+		}
+		if invoice.Folio, err = build(&booking, ledger, invoice.Number, invoice.IssuedAt); err != nil {
+			return err
+		}
+		err = auditedChange[models.Invoice](tx, models.AuditActionCreate, models.AuditEntityInvoice, func() uint { return invoice.ID },
+			func(tx *gorm.DB) error { return tx.Create(&invoice).Error })
+		created = err == nil
+		return err
+	})
+	return invoice, created, err
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The hooks refuse before any SQL is built, so a dry run with no server behind it is enough
+func TestInvoiceHooksRefuseChanges(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:1)/hotels", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invoice := models.Invoice{ID: 1, HotelID: 1, Number: 1, BookingID: 1, Folio: models.JSONDocument(`{"total":240}`)}
+
+	changes := map[string]func() error{
+		"update":          func() error { return db.Model(&invoice).Update("folio", models.JSONDocument(`{"total":0}`)).Error },
+		"update by where": func() error { return db.Model(&models.Invoice{}).Where("booking_id = ?", 1).Update("number", 2).Error },
+		"save":            func() error { return db.Save(&invoice).Error },
+		"delete":          func() error { return db.Delete(&invoice).Error },
+		"delete by where": func() error { return db.Where("hotel_id = ?", 1).Delete(&models.Invoice{}).Error },
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			if err := change(); !errors.Is(err, models.ErrInvoiceImmutable) {
+				t.Errorf("err = %v, want ErrInvoiceImmutable", err)
+			}
+		})
+	}
+}
+
+func TestIssueNumbersEachHotelsInvoicesWithoutGaps(t *testing.T) {
+	dsn := os.Getenv("GO_API_TEST_DSN")
+	if dsn == "" {
+		t.Skip("GO_API_TEST_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), gormConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetDatabase(t, db)
+
+	rows := []interface{}{
+		&models.Hotel{Model: gorm.Model{ID: 1}, Name: "Carpathian Lodge"},
+		&models.Hotel{Model: gorm.Model{ID: 2}, Name: "Black Sea Resort"},
+		&models.Guest{Model: gorm.Model{ID: 1}, Name: "Olena Koval", MobileNumber: "+380501234567"},
+	}
+	// Бронювання 1-3 у першому готелі, 4 у другому
+	for id := uint(1); id <= 4; id++ {
+		hotelID := uint(1)
+		if id == 4 {
+			hotelID = 2
+		}
+		rows = append(rows, &models.Booking{Model: gorm.Model{ID: id}, GuestID: 1, HotelID: hotelID, Adults: 1,
+			Status: models.BookingStatusCheckedOut, CheckIn: models.NewDate(2026, 4, 10), CheckOut: models.NewDate(2026, 4, 12)})
+	}
+	for _, row := range rows {
+		if err := db.Omit(clause.Associations).Create(row).Error; err != nil {
+			t.Fatalf("seed %T: %v", row, err)
+		}
+	}
+
+	repo := NewInvoiceRepository(db)
+	build := func(booking *models.Booking, ledger []models.Payment, number uint, issuedAt time.Time) (models.JSONDocument, error) {
+		return json.Marshal(map[string]uint{"number": number})
+	}
+	failed := errors.New("the folio couldn't be built")
+
+	steps := []struct {
+		name        string
+		bookingID   uint
+		build       FolioBuilder
+		wantNumber  uint
+		wantCreated bool
+	}{
+		{"first of the first hotel", 1, build, 1, true},
+		{"first of the second hotel", 4, build, 1, true},
+		{"a failed issue", 2, func(*models.Booking, []models.Payment, uint, time.Time) (models.JSONDocument, error) {
+			return nil, failed
+		}, 0, false},
+		{"the number the failed issue gave back", 2, build, 2, true},
+		{"issued again", 1, build, 1, false},
+		{"next of the first hotel", 3, build, 3, true},
+	}
+	for _, step := range steps {
+		invoice, created, err := repo.Issue(context.Background(), step.bookingID, step.build)
+		if step.wantNumber == 0 {
+			if !errors.Is(err, failed) {
+				t.Fatalf("%s: err = %v, want the builder's error", step.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if invoice.Number != step.wantNumber || created != step.wantCreated {
+			t.Errorf("%s: invoice %d, created %t; want %d, created %t", step.name, invoice.Number, created, step.wantNumber, step.wantCreated)
+		}
+	}
+
+	var sequences []models.InvoiceSequence
+	if err := db.Order("hotel_id").Find(&sequences).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(sequences) != 2 || sequences[0].LastNumber != 3 || sequences[1].LastNumber != 1 {
+		t.Errorf("sequences = %+v, want hotel 1 at 3 and hotel 2 at 1", sequences)
+	}
+
+	var invoice models.Invoice
+	if err := db.Where("booking_id = ?", 1).First(&invoice).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&invoice).Update("number", 7).Error; !errors.Is(err, models.ErrInvoiceImmutable) {
+		t.Errorf("update err = %v, want ErrInvoiceImmutable", err)
+	}
+	if err := db.Delete(&invoice).Error; !errors.Is(err, models.ErrInvoiceImmutable) {
+		t.Errorf("delete err = %v, want ErrInvoiceImmutable", err)
+	}
+	var stored models.Invoice
+	if err := db.First(&stored, invoice.ID).Error; err != nil || stored.Number != 1 {
+		t.Errorf("invoice after the refused changes = %+v, %v; want it unchanged", stored, err)
+	}
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentRepository interface {
+	GetByBooking(bookingID uint) ([]models.Payment, error)
+	Record(ctx context.Context, bookingID uint, build func(ledger []models.Payment) (models.Payment, error)) (models.Payment, error)
+	Complete(ctx context.Context, id uint, status, reference string) error
+}
+
+type paymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+// GetByBooking returns the booking's ledger, oldest entry first
+func (r *paymentRepository) GetByBooking(bookingID uint) ([]models.Payment, error) {
+	var ledger []models.Payment
+	err := r.db.Where("booking_id = ?", bookingID).Order("id").Find(&ledger).Error
+	return ledger, err
+}
+
+// Record locks the booking, passes its ledger to build and adds the entry build returns.
+// Entries for one booking are recorded one at a time, so build always sees the whole ledger.
+func (r *paymentRepository) Record(ctx context.Context, bookingID uint,
+	build func(ledger []models.Payment) (models.Payment, error)) (models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Booking{}, bookingID).Error; err != nil {
+			return err
+		}
+		var ledger []models.Payment
+		if err := tx.Where("booking_id = ?", bookingID).Order("id").Find(&ledger).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if payment, err = build(ledger); err != nil {
+			return err
+		}
+		payment.ID = 0
+		payment.BookingID = bookingID
+		return auditedChange[models.Payment](tx, models.AuditActionCreate, models.AuditEntityPayment, func() uint { return payment.ID },
+			func(tx *gorm.DB) error { return tx.Create(&payment).Error })
+	})
+	return payment, err
+}
+
+// Complete records the provider's outcome of a pending entry; an entry that is no longer pending is left alone
+func (r *paymentRepository) Complete(ctx context.Context, id uint, status, reference string) error {
+	return withAudit[models.Payment](ctx, r.db, models.AuditActionUpdate, models.AuditEntityPayment, fixedID(id),
+		func(tx *gorm.DB) error {
+			result := tx.Model(&models.Payment{}).Where("id = ? AND status = ?", id, models.PaymentStatusPending).
+				Updates(map[string]interface{}{"status": status, "reference": reference})
+			if result.Error == nil && result.RowsAffected == 0 {
+				return &ConflictError{Message: fmt.Sprintf("payment %d is not pending", id)}
+			}
+			return result.Error
+		})
+}
@@ -1,16 +1,27 @@
 package repositories
 
 import (
+	"context"
+	"fmt"
+
 	"go.mod/models"
 	"gorm.io/gorm"
 )
 
 type RoomRepository interface {
-	GetAll() ([]models.Room, error)
+	GetAll(includeDeleted bool) ([]models.Room, error)
+	Stream(includeDeleted bool, fn func(room *models.Room) error) error
 	GetByID(id uint) (models.Room, error)
-	Create(room *models.Room) error
-	Update(room *models.Room) error
-	Delete(id uint) error
+	GetNumbers(hotelID uint) ([]string, error)
+	GetDeleted() ([]models.Room, error)
+	GetDeletedByID(id uint) (models.Room, error)
+	Create(ctx context.Context, room *models.Room) error
+	CreateBatch(ctx context.Context, rooms []models.Room) error
+	Update(ctx context.Context, room *models.Room) error
+	Patch(ctx context.Context, room *models.Room, fields []string) error
+	Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
 }
 
 type roomRepository struct {
@@ -21,26 +32,173 @@ func NewRoomRepository(db *gorm.DB) RoomRepository {
 	return &roomRepository{db: db}
 }
 
-func (r *roomRepository) GetAll() ([]models.Room, error) {
+func (r *roomRepository) GetAll(includeDeleted bool) ([]models.Room, error) {
 	var rooms []models.Room
-	err := r.db.Find(&rooms).Error
+	err := withDeleted(r.db, includeDeleted).Find(&rooms).Error
 	return rooms, err
 }
 
+// Stream walks all rooms in batches without loading the whole table
+func (r *roomRepository) Stream(includeDeleted bool, fn func(room *models.Room) error) error {
+	var batch []models.Room
+	return withDeleted(r.db, includeDeleted).FindInBatches(&batch, streamBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
 func (r *roomRepository) GetByID(id uint) (models.Room, error) {
 	var room models.Room
 	err := r.db.First(&room, id).Error
 	return room, err
 }
 
-func (r *roomRepository) Create(room *models.Room) error {
-	return r.db.Create(room).Error
+// GetNumbers lists the numbers of the hotel's rooms; rooms in the trash don't hold theirs
+func (r *roomRepository) GetNumbers(hotelID uint) ([]string, error) {
+	var numbers []string
+	err := r.db.Model(&models.Room{}).Where("hotel_id = ? AND number <> ''", hotelID).Pluck("number", &numbers).Error
+	return numbers, err
+}
+
+// checkRoomNumbers finds the numbers used twice in a hotel, which would stop the unique index from being created
+func checkRoomNumbers(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Room{}, "number") {
+		return nil
+	}
+	var duplicates []struct {
+		HotelID uint
+		Number  string
+		Rooms   int
+	}
+	err := db.Model(&models.Room{}).
+		Select("hotel_id, number, COUNT(*) AS rooms").
+		Where("number <> ''").
+		Group("hotel_id, number").
+		Having("COUNT(*) > 1").
+		Order("hotel_id, number").
+		Scan(&duplicates).Error
+	if err != nil || len(duplicates) == 0 {
+		return err
+	}
+	var problems []string
+	for _, d := range duplicates {
+		problems = append(problems, fmt.Sprintf("hotel %d has %d rooms numbered %q", d.HotelID, d.Rooms, d.Number))
+	}
+	return fmt.Errorf("room numbers must be unique within a hotel before the index can be added: %v", problems)
+}
+
+// GetDeleted lists the rooms in the trash
+func (r *roomRepository) GetDeleted() ([]models.Room, error) {
+	return findDeleted[models.Room](r.db)
+}
+
+func (r *roomRepository) GetDeletedByID(id uint) (models.Room, error) {
+	return findDeletedByID[models.Room](r.db, id)
+}
+
+func (r *roomRepository) Create(ctx context.Context, room *models.Room) error {
+	return withAudit[models.Room](ctx, r.db, models.AuditActionCreate, models.AuditEntityRoom, func() uint { return room.ID },
+		func(tx *gorm.DB) error { return tx.Create(room).Error })
+}
+
+// CreateBatch inserts all rooms in one transaction: either every row is saved or none.
+// The audit entries use the inserted values instead of reading every row back.
+func (r *roomRepository) CreateBatch(ctx context.Context, rooms []models.Room) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&rooms, 100).Error; err != nil {
+			return err
+		}
+		for i := range rooms {
+			if err := recordAudit[models.Room](tx, models.AuditActionCreate, models.AuditEntityRoom, rooms[i].ID, nil, &rooms[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *roomRepository) Update(ctx context.Context, room *models.Room) error {
+	return withAudit[models.Room](ctx, r.db, models.AuditActionUpdate, models.AuditEntityRoom, func() uint { return room.ID },
+		func(tx *gorm.DB) error { return tx.Save(room).Error })
+}
+
+// Patch writes only the listed fields, so columns the client didn't touch keep their values
+func (r *roomRepository) Patch(ctx context.Context, room *models.Room, fields []string) error {
+	return withAudit[models.Room](ctx, r.db, models.AuditActionUpdate, models.AuditEntityRoom, func() uint { return room.ID },
+		func(tx *gorm.DB) error { return tx.Model(room).Select(append(fields, "UpdatedAt")).Updates(room).Error })
+}
+
+// Delete applies the delete policy to future bookings of the room, then moves it to the trash.
+// It returns the bookings it cancelled.
+func (r *roomRepository) Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error) {
+	var cancelled []models.Booking
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var room models.Room
+		if err := tx.First(&room, id).Error; err != nil {
+			return err
+		}
+		var err error
+		cancelled, err = applyDeletePolicy(tx, models.AuditEntityRoom, opts,
+			"id IN (SELECT booking_id FROM booking_rooms WHERE room_id = ?)", id)
+		if err != nil {
+			return err
+		}
+		err = auditedChange[models.Room](tx, models.AuditActionDelete, models.AuditEntityRoom, fixedID(id),
+			func(tx *gorm.DB) error { return tx.Delete(&models.Room{}, id).Error })
+		if err != nil || room.RoomTypeID == nil || room.Status == models.RoomStatusOutOfService {
+			return err
+		}
+
+		// Кімната, що зникла, могла бути потрібна бронюванням її типу без призначеної кімнати
+		overbooked, err := applyCapacityPolicy(tx, *room.RoomTypeID, opts)
+		cancelled = append(cancelled, overbooked...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
 }
 
-func (r *roomRepository) Update(room *models.Room) error {
-	return r.db.Save(room).Error
+// Restore fails with ErrParentDeleted while the room's hotel or room type is in the trash
+func (r *roomRepository) Restore(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		room, err := findDeletedByID[models.Room](tx, id)
+		if err != nil {
+			return err
+		}
+		if room.HotelID != 0 {
+			live, err := isLive[models.Hotel](tx, room.HotelID)
+			if err != nil {
+				return err
+			}
+			if !live {
+				return ErrParentDeleted
+			}
+		}
+		if room.RoomTypeID != nil {
+			live, err := isLive[models.RoomType](tx, *room.RoomTypeID)
+			if err != nil {
+				return err
+			}
+			if !live {
+				return ErrParentDeleted
+			}
+		}
+		return restoreDeleted[models.Room](tx, models.AuditEntityRoom, id)
+	})
 }
 
-func (r *roomRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Room{}, id).Error
+// Purge permanently removes a room from the trash and from the bookings that reference it
+func (r *roomRepository) Purge(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := findDeletedByID[models.Room](tx, id); err != nil {
+			return err
+		}
+		return purgeRoom(tx, id)
+	})
 }
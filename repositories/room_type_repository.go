@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+)
+
+// ErrRoomTypeInUse is returned when deleting a room type that still has rooms
+var ErrRoomTypeInUse = errors.New("room type still has rooms")
+
+type RoomTypeRepository interface {
+	GetAll(includeDeleted bool) ([]models.RoomType, error)
+	GetByID(id uint) (models.RoomType, error)
+	GetByName(hotelID uint, name string) (models.RoomType, error)
+	Create(ctx context.Context, roomType *models.RoomType) error
+	Update(ctx context.Context, roomType *models.RoomType) error
+	Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error)
+	Availability(hotelID uint, checkIn, checkOut models.Date) ([]RoomTypeAvailability, error)
+}
+
+type roomTypeRepository struct {
+	db *gorm.DB
+}
+
+func NewRoomTypeRepository(db *gorm.DB) RoomTypeRepository {
+	return &roomTypeRepository{db: db}
+}
+
+func (r *roomTypeRepository) GetAll(includeDeleted bool) ([]models.RoomType, error) {
+	var roomTypes []models.RoomType
+	err := withDeleted(r.db, includeDeleted).Order("hotel_id, name").Find(&roomTypes).Error
+	return roomTypes, err
+}
+
+// GetByID returns the room type with its rooms
+func (r *roomTypeRepository) GetByID(id uint) (models.RoomType, error) {
+	var roomType models.RoomType
+	err := r.db.Preload("Units").First(&roomType, id).Error
+	return roomType, err
+}
+
+// GetByName finds a hotel's room type by name, ignoring case
+func (r *roomTypeRepository) GetByName(hotelID uint, name string) (models.RoomType, error) {
+	var roomType models.RoomType
+	err := r.db.Where("hotel_id = ? AND LOWER(name) = LOWER(?)", hotelID, name).First(&roomType).Error
+	return roomType, err
+}
+
+func (r *roomTypeRepository) Create(ctx context.Context, roomType *models.RoomType) error {
+	return withAudit[models.RoomType](ctx, r.db, models.AuditActionCreate, models.AuditEntityRoomType, func() uint { return roomType.ID },
+		func(tx *gorm.DB) error { return tx.Omit("Units").Create(roomType).Error })
+}
+
+// Update also renames the type on its rooms, which keep the name for the room_type filters
+func (r *roomTypeRepository) Update(ctx context.Context, roomType *models.RoomType) error {
+	return withAudit[models.RoomType](ctx, r.db, models.AuditActionUpdate, models.AuditEntityRoomType, func() uint { return roomType.ID },
+		func(tx *gorm.DB) error {
+			if err := tx.Omit("Units").Save(roomType).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Room{}).Where("room_type_id = ?", roomType.ID).Update("room_type", roomType.Name).Error
+		})
+}
+
+// Delete refuses while the type has rooms, applies the delete policy to future bookings
+// that reserved it and moves it to the trash. It returns the bookings it cancelled.
+func (r *roomTypeRepository) Delete(ctx context.Context, id uint, opts DeleteOptions) ([]models.Booking, error) {
+	var cancelled []models.Booking
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var units int64
+		if err := tx.Model(&models.Room{}).Where("room_type_id = ?", id).Count(&units).Error; err != nil {
+			return err
+		}
+		if units > 0 {
+			return ErrRoomTypeInUse
+		}
+
+		var err error
+		cancelled, err = applyDeletePolicy(tx, models.AuditEntityRoomType, opts,
+			"id IN (SELECT booking_id FROM reservations WHERE room_type_id = ?)", id)
+		if err != nil {
+			return err
+		}
+		return softDelete[models.RoomType](tx, models.AuditEntityRoomType, id, tx.NowFunc())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// Availability lists the hotel's room types with the number of rooms free on every night of the stay
+func (r *roomTypeRepository) Availability(hotelID uint, checkIn, checkOut models.Date) ([]RoomTypeAvailability, error) {
+	var roomTypes []models.RoomType
+	if err := r.db.Where("hotel_id = ?", hotelID).Order("base_price, name").Find(&roomTypes).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]RoomTypeAvailability, 0, len(roomTypes))
+	for _, roomType := range roomTypes {
+		free, err := availability(r.db, roomType, checkIn, checkOut, 0)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, free)
+	}
+	return result, nil
+}
+
+// backfillRoomTypes gives rooms created before room types existed a type: one per hotel and
+// room_type name, priced at the cheapest of those rooms. Rooms without a number are numbered by ID.
+func backfillRoomTypes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var groups []struct {
+			HotelID  uint
+			RoomType string
+			Price    float32
+		}
+		err := tx.Model(&models.Room{}).
+			Select("hotel_id, room_type, MIN(price) AS price").
+			Where("room_type_id IS NULL").
+			Group("hotel_id, room_type").
+			Scan(&groups).Error
+		if err != nil {
+			return err
+		}
+
+		for _, g := range groups {
+			var roomType models.RoomType
+			err := tx.Where("hotel_id = ? AND LOWER(name) = LOWER(?)", g.HotelID, g.RoomType).First(&roomType).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				roomType = models.RoomType{HotelID: g.HotelID, Name: g.RoomType, BasePrice: g.Price, MaxOccupancy: 2}
+				err = tx.Create(&roomType).Error
+			}
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&models.Room{}).
+				Where("hotel_id = ? AND room_type = ? AND room_type_id IS NULL", g.HotelID, g.RoomType).
+				Update("room_type_id", roomType.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Room{}).Where("number IS NULL OR number = ''").
+			Update("number", gorm.Expr("CAST(id AS CHAR)")).Error
+	})
+}
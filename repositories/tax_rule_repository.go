@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+)
+
+type TaxRuleRepository interface {
+	GetAll(includeDeleted bool) ([]models.TaxRule, error)
+	GetByID(id uint) (models.TaxRule, error)
+	GetByHotel(hotelID uint) ([]models.TaxRule, error)
+	Create(ctx context.Context, rule *models.TaxRule) error
+	Update(ctx context.Context, rule *models.TaxRule) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type taxRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewTaxRuleRepository(db *gorm.DB) TaxRuleRepository {
+	return &taxRuleRepository{db: db}
+}
+
+func (r *taxRuleRepository) GetAll(includeDeleted bool) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	err := withDeleted(r.db, includeDeleted).Order("hotel_id, id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *taxRuleRepository) GetByID(id uint) (models.TaxRule, error) {
+	var rule models.TaxRule
+	err := r.db.First(&rule, id).Error
+	return rule, err
+}
+
+// GetByHotel returns the rules the hotel charges now
+func (r *taxRuleRepository) GetByHotel(hotelID uint) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	err := r.db.Where("hotel_id = ?", hotelID).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *taxRuleRepository) Create(ctx context.Context, rule *models.TaxRule) error {
+	return withAudit[models.TaxRule](ctx, r.db, models.AuditActionCreate, models.AuditEntityTaxRule, func() uint { return rule.ID },
+		func(tx *gorm.DB) error { return tx.Create(rule).Error })
+}
+
+func (r *taxRuleRepository) Update(ctx context.Context, rule *models.TaxRule) error {
+	return withAudit[models.TaxRule](ctx, r.db, models.AuditActionUpdate, models.AuditEntityTaxRule, func() uint { return rule.ID },
+		func(tx *gorm.DB) error { return tx.Save(rule).Error })
+}
+
+// Delete moves the rule to the trash; invoices already issued keep the taxes they were charged
+func (r *taxRuleRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return softDelete[models.TaxRule](tx, models.AuditEntityTaxRule, id, tx.NowFunc())
+	})
+}
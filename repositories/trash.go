@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"go.mod/models"
+	"gorm.io/gorm"
+)
+
+// ErrParentDeleted is returned when restoring a record whose hotel, room type or guest is still in the trash
+var ErrParentDeleted = errors.New("parent record is deleted")
+
+// withDeleted drops the soft-delete filter when includeDeleted is set
+func withDeleted(db *gorm.DB, includeDeleted bool) *gorm.DB {
+	if includeDeleted {
+		return db.Unscoped()
+	}
+	return db
+}
+
+// findDeleted lists the soft-deleted rows, most recently deleted first
+func findDeleted[T any](db *gorm.DB) ([]T, error) {
+	var items []T
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&items).Error
+	return items, err
+}
+
+// findDeletedByID returns gorm.ErrRecordNotFound unless the row exists and is in the trash
+func findDeletedByID[T any](db *gorm.DB, id uint) (T, error) {
+	var item T
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&item, id).Error
+	return item, err
+}
+
+func fixedID(id uint) func() uint {
+	return func() uint { return id }
+}
+
+// softDelete sets deleted_at explicitly instead of using tx.Delete,
+// so a parent and its cascaded children share one timestamp
+func softDelete[T any](tx *gorm.DB, entityType string, id uint, at time.Time) error {
+	return auditedChange[T](tx, models.AuditActionDelete, entityType, fixedID(id), func(tx *gorm.DB) error {
+		return tx.Model(new(T)).Where("id = ?", id).Update("deleted_at", at).Error
+	})
+}
+
+func restoreDeleted[T any](tx *gorm.DB, entityType string, id uint) error {
+	return auditedChange[T](tx, models.AuditActionRestore, entityType, fixedID(id), func(tx *gorm.DB) error {
+		return tx.Unscoped().Model(new(T)).Where("id = ?", id).Update("deleted_at", nil).Error
+	})
+}
+
+func purgeRecord[T any](tx *gorm.DB, entityType string, id uint) error {
+	return auditedChange[T](tx, models.AuditActionPurge, entityType, fixedID(id), func(tx *gorm.DB) error {
+		return tx.Unscoped().Delete(new(T), id).Error
+	})
+}
+
+// softDeleteWhere cascades a delete to the live rows matching the condition
+func softDeleteWhere[T any](tx *gorm.DB, entityType string, at time.Time, query string, args ...interface{}) error {
+	var ids []uint
+	if err := tx.Model(new(T)).Where(query, args...).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := softDelete[T](tx, entityType, id, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreWhere brings back the children that were deleted together with their parent at deletedAt.
+// Children deleted on their own earlier stay in the trash.
+func restoreWhere[T any](tx *gorm.DB, entityType string, deletedAt time.Time, query string, args ...interface{}) error {
+	var ids []uint
+	err := tx.Unscoped().Model(new(T)).Where("deleted_at = ?", deletedAt).Where(query, args...).Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := restoreDeleted[T](tx, entityType, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeIDs lists every row matching the condition, deleted or not
+func purgeIDs[T any](tx *gorm.DB, query string, args ...interface{}) ([]uint, error) {
+	var ids []uint
+	err := tx.Unscoped().Model(new(T)).Where(query, args...).Pluck("id", &ids).Error
+	return ids, err
+}
+
+func purgeBooking(tx *gorm.DB, id uint) error {
+	var invoices int64
+	if err := tx.Model(&models.Invoice{}).Where("booking_id = ?", id).Count(&invoices).Error; err != nil {
+		return err
+	}
+	if invoices > 0 {
+		return ErrInvoiced
+	}
+	if err := tx.Exec("DELETE FROM booking_rooms WHERE booking_id = ?", id).Error; err != nil {
+		return err
+	}
+	return purgeRecord[models.Booking](tx, models.AuditEntityBooking, id)
+}
+
+func purgeRoom(tx *gorm.DB, id uint) error {
+	if err := tx.Exec("DELETE FROM booking_rooms WHERE room_id = ?", id).Error; err != nil {
+		return err
+	}
+	return purgeRecord[models.Room](tx, models.AuditEntityRoom, id)
+}
+
+func purgeBookingsWhere(tx *gorm.DB, query string, args ...interface{}) error {
+	ids, err := purgeIDs[models.Booking](tx, query, args...)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := purgeBooking(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isLive reports whether the row exists and is not soft-deleted
+func isLive[T any](tx *gorm.DB, id uint) (bool, error) {
+	var count int64
+	err := tx.Model(new(T)).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
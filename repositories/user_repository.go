@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"go.mod/models"
+	"gorm.io/gorm"
+)
+
+type UserRepository interface {
+	GetAll() ([]models.User, error)
+	GetByID(id uint) (models.User, error)
+	GetByUsername(username string) (models.User, error)
+	Create(user *models.User) error
+	Update(user *models.User) error
+	Delete(id uint) error
+}
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) GetAll() ([]models.User, error) {
+	var users []models.User
+	err := r.db.Preload("Hotels").Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) GetByID(id uint) (models.User, error) {
+	var user models.User
+	err := r.db.Preload("Hotels").First(&user, id).Error
+	return user, err
+}
+
+func (r *userRepository) GetByUsername(username string) (models.User, error) {
+	var user models.User
+	err := r.db.Preload("Hotels").Where("username = ?", username).First(&user).Error
+	return user, err
+}
+
+func (r *userRepository) Create(user *models.User) error {
+	return r.db.Omit("Hotels.*").Create(user).Error
+}
+
+// Update saves the user and replaces the hotel assignments with user.Hotels
+func (r *userRepository) Update(user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Hotels").Save(user).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).Association("Hotels").Replace(user.Hotels)
+	})
+}
+
+func (r *userRepository) Delete(id uint) error {
+	return r.db.Delete(&models.User{}, id).Error
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+
+	"go.mod/docs"
+)
+
+const (
+	defaultBodyLimit = 1 << 20
+	importBodyLimit  = 10 << 20
+)
+
+// apiHandlers are the handlers behind the routes
+type apiHandlers struct {
+	hotels, rooms, roomTypes, policies, taxRules, guests, bookings http.Handler
+	users, search, audit, trash, auth                              http.Handler
+}
+
+// routeWrappers put each kind of route behind its middleware: api routes need authentication
+// and take a body of the given size and types, the token endpoint is public, and so are the docs
+type routeWrappers struct {
+	api   func(h http.Handler, maxBody int64, contentTypes []string) http.Handler
+	token func(h http.Handler) http.Handler
+	spec  func(h http.Handler) http.Handler
+	ui    func(h http.Handler) http.Handler
+}
+
+// registerRoutes adds every route to mux and returns the patterns the OpenAPI spec has to document
+func registerRoutes(mux *http.ServeMux, h apiHandlers, wrap routeWrappers) []string {
+	jsonTypes := []string{"application/json", "application/merge-patch+json", "application/json-patch+json"}
+	importTypes := []string{"application/json", "application/merge-patch+json", "application/json-patch+json", "text/csv"}
+
+	apiRoutes := []struct {
+		pattern      string
+		handler      http.Handler
+		maxBody      int64
+		contentTypes []string
+	}{
+		{"/hotels", h.hotels, defaultBodyLimit, jsonTypes},
+		// /hotels/{id}/rooms:import приймає великі CSV-файли
+		{"/hotels/", h.hotels, importBodyLimit, importTypes},
+		{"/rooms", h.rooms, defaultBodyLimit, jsonTypes},
+		{"/rooms/", h.rooms, defaultBodyLimit, jsonTypes},
+		{"/room-types", h.roomTypes, defaultBodyLimit, []string{"application/json"}},
+		{"/room-types/", h.roomTypes, defaultBodyLimit, []string{"application/json"}},
+		{"/cancellation-policies", h.policies, defaultBodyLimit, []string{"application/json"}},
+		{"/cancellation-policies/", h.policies, defaultBodyLimit, []string{"application/json"}},
+		{"/tax-rules", h.taxRules, defaultBodyLimit, []string{"application/json"}},
+		{"/tax-rules/", h.taxRules, defaultBodyLimit, []string{"application/json"}},
+		{"/guests", h.guests, defaultBodyLimit, jsonTypes},
+		{"/guests/", h.guests, defaultBodyLimit, jsonTypes},
+		{"/bookings", h.bookings, defaultBodyLimit, jsonTypes},
+		{"/bookings/", h.bookings, defaultBodyLimit, jsonTypes},
+		{"/users", h.users, defaultBodyLimit, []string{"application/json"}},
+		{"/users/", h.users, defaultBodyLimit, []string{"application/json"}},
+		{"/search", h.search, defaultBodyLimit, nil},
+		{"/audit", h.audit, defaultBodyLimit, nil},
+		{"/trash", h.trash, defaultBodyLimit, nil},
+		{"/trash/", h.trash, defaultBodyLimit, nil},
+	}
+
+	patterns := make([]string, 0, len(apiRoutes)+1)
+	for _, rt := range apiRoutes {
+		mux.Handle(rt.pattern, wrap.api(rt.handler, rt.maxBody, rt.contentTypes))
+		patterns = append(patterns, rt.pattern)
+	}
+
+	// Видача токена не вимагає автентифікації
+	mux.Handle("/auth/token", wrap.token(h.auth))
+	patterns = append(patterns, "/auth/token")
+
+	mux.Handle("/openapi.json", wrap.spec(docs.SpecHandler()))
+	mux.Handle("/docs", wrap.ui(docs.UIHandler()))
+	return patterns
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.mod/docs"
+)
+
+func testRouter(t *testing.T) (*http.ServeMux, []string) {
+	t.Helper()
+	stub := http.NotFoundHandler()
+	same := func(h http.Handler) http.Handler { return h }
+	mux := http.NewServeMux()
+	patterns := registerRoutes(mux, apiHandlers{
+		hotels: stub, rooms: stub, roomTypes: stub, policies: stub, taxRules: stub, guests: stub, bookings: stub,
+		users: stub, search: stub, audit: stub, trash: stub, auth: stub,
+	}, routeWrappers{
+		api:   func(h http.Handler, _ int64, _ []string) http.Handler { return h },
+		token: same,
+		spec:  same,
+		ui:    same,
+	})
+	return mux, patterns
+}
+
+func TestSpecMatchesRouter(t *testing.T) {
+	mux, patterns := testRouter(t)
+	if err := docs.VerifyRoutes(mux, patterns); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpecCheckCatchesUndocumentedRoute(t *testing.T) {
+	mux, patterns := testRouter(t)
+	mux.Handle("/undocumented", http.NotFoundHandler())
+	err := docs.VerifyRoutes(mux, append(patterns, "/undocumented"))
+	if err == nil || !strings.Contains(err.Error(), "routed but not documented: /undocumented") {
+		t.Fatalf("VerifyRoutes() = %v, want the undocumented route reported", err)
+	}
+}
@@ -0,0 +1,56 @@
+package security
+
+import "context"
+
+const (
+	AuthMethodAPIKey     = "key"
+	AuthMethodClientCert = "cert"
+	AuthMethodJWT        = "jwt"
+	AuthMethodSystem     = "system"
+)
+
+// Identity is the authenticated caller of a request. HotelIDs limits hotel-scoped roles
+// (e.g. receptionist) to the listed hotels.
+type Identity struct {
+	Method   string
+	Name     string
+	Roles    []string
+	HotelIDs []uint
+}
+
+// String is the form used for rate limits, idempotency scopes and logs, e.g. "key:1a2b..." or "cert:partner-a"
+func (i Identity) String() string {
+	return i.Method + ":" + i.Name
+}
+
+func (i Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+type requestIDKey struct{}
+
+// WithRequestID stores the ID that ties log lines and audit entries to one request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,480 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	jwtClockSkew = time.Minute
+	// minRSAKeyBits is the smallest RSA modulus a JWKS may bring in
+	minRSAKeyBits = 2048
+)
+
+var (
+	ErrTokenMalformed = errors.New("token is malformed")
+	ErrTokenSignature = errors.New("token signature is invalid")
+	ErrTokenExpired   = errors.New("token has expired")
+	ErrTokenClaims    = errors.New("token claims are invalid")
+)
+
+// JWTKey is a verification (and optionally signing) key. Key is []byte for HS256,
+// *rsa.PublicKey / *rsa.PrivateKey for RS256, ed25519.PublicKey / ed25519.PrivateKey for EdDSA.
+type JWTKey struct {
+	ID  string
+	Alg string
+	Key interface{}
+}
+
+// Claims are the registered claims we use plus the caller's roles and assigned hotels
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	Roles     []string `json:"-"`
+	HotelIDs  []uint   `json:"hotels,omitempty"`
+}
+
+// audience accepts both "aud": "x" and "aud": ["x", "y"]
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// SignJWT encodes claims (with roles under rolesClaim) and signs them with key
+func SignJWT(claims Claims, rolesClaim string, key JWTKey) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: key.Alg, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	if rolesClaim != "" && len(claims.Roles) > 0 {
+		var m map[string]interface{}
+		if err := json.Unmarshal(payload, &m); err != nil {
+			return "", err
+		}
+		m[rolesClaim] = claims.Roles
+		if payload, err = json.Marshal(m); err != nil {
+			return "", err
+		}
+	}
+
+	signingInput := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	signature, err := sign(key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64.EncodeToString(signature), nil
+}
+
+func sign(key JWTKey, input []byte) ([]byte, error) {
+	switch key.Alg {
+	case AlgHS256:
+		secret, ok := key.Key.([]byte)
+		if !ok {
+			return nil, errors.New("HS256 needs a []byte secret")
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case AlgRS256:
+		private, ok := key.Key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 signing needs an RSA private key")
+		}
+		digest := sha256.Sum256(input)
+		return rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest[:])
+	case AlgEdDSA:
+		private, ok := key.Key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("EdDSA signing needs an Ed25519 private key")
+		}
+		return ed25519.Sign(private, input), nil
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", key.Alg)
+	}
+}
+
+func verify(key JWTKey, input, signature []byte) bool {
+	switch key.Alg {
+	case AlgHS256:
+		secret, ok := key.Key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case AlgRS256:
+		var public *rsa.PublicKey
+		switch k := key.Key.(type) {
+		case *rsa.PublicKey:
+			public = k
+		case *rsa.PrivateKey:
+			public = &k.PublicKey
+		default:
+			return false
+		}
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+	case AlgEdDSA:
+		var public ed25519.PublicKey
+		switch k := key.Key.(type) {
+		case ed25519.PublicKey:
+			public = k
+		case ed25519.PrivateKey:
+			public = k.Public().(ed25519.PublicKey)
+		default:
+			return false
+		}
+		return len(public) == ed25519.PublicKeySize && ed25519.Verify(public, input, signature)
+	default:
+		return false
+	}
+}
+
+// JWTVerifier checks signature, expiry, issuer and audience of incoming tokens
+type JWTVerifier struct {
+	Keys       []JWTKey
+	Issuer     string
+	Audience   string
+	RolesClaim string
+}
+
+func (v *JWTVerifier) Verify(token string, now time.Time) (Claims, error) {
+	var claims Claims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrTokenMalformed
+	}
+	headerJSON, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return claims, ErrTokenMalformed
+	}
+	payloadJSON, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return claims, ErrTokenMalformed
+	}
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return claims, ErrTokenMalformed
+	}
+
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return claims, ErrTokenMalformed
+	}
+
+	// Алгоритм береться з ключа, а не з токена, тому "none" чи підміна RS256→HS256 не пройдуть
+	signingInput := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range v.Keys {
+		if key.Alg != header.Alg || (header.Kid != "" && key.ID != "" && key.ID != header.Kid) {
+			continue
+		}
+		if verify(key, signingInput, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return claims, ErrTokenSignature
+	}
+
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return claims, ErrTokenMalformed
+	}
+	if v.RolesClaim != "" {
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(payloadJSON, &raw); err != nil {
+			return claims, ErrTokenMalformed
+		}
+		if rolesJSON, ok := raw[v.RolesClaim]; ok {
+			if err := json.Unmarshal(rolesJSON, &claims.Roles); err != nil {
+				return claims, fmt.Errorf("%w: %s must be an array of strings", ErrTokenClaims, v.RolesClaim)
+			}
+		}
+	}
+
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtClockSkew)) {
+		return claims, ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(jwtClockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return claims, fmt.Errorf("%w: not valid yet", ErrTokenClaims)
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return claims, fmt.Errorf("%w: unexpected issuer", ErrTokenClaims)
+	}
+	if v.Audience != "" {
+		found := false
+		for _, aud := range claims.Audience {
+			if aud == v.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return claims, fmt.Errorf("%w: unexpected audience", ErrTokenClaims)
+		}
+	}
+	if claims.Subject == "" {
+		return claims, fmt.Errorf("%w: sub is required", ErrTokenClaims)
+	}
+	return claims, nil
+}
+
+// jwk is the subset of RFC 7517 we understand: RSA, Ed25519 (OKP) and symmetric (oct) keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	K   string `json:"k"`
+}
+
+// LoadJWKS reads verification keys from a local JWKS file
+func LoadJWKS(path string) ([]JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var keys []JWTKey
+	for i, k := range set.Keys {
+		// Ключі для шифрування та алгоритмів, яких ми не знаємо, пропускаємо
+		if (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != AlgHS256 && k.Alg != AlgRS256 && k.Alg != AlgEdDSA) {
+			continue
+		}
+		key, err := parseJWK(k)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %d: %w", path, i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseJWK picks the algorithm from kty; a key that names another alg is refused, since a token
+// must be signed with the algorithm its key was published for
+func parseJWK(k jwk) (JWTKey, error) {
+	key, err := parseJWKKey(k)
+	if err != nil {
+		return JWTKey{}, err
+	}
+	if k.Alg != "" && k.Alg != key.Alg {
+		return JWTKey{}, fmt.Errorf("alg %q doesn't fit a %s key", k.Alg, k.Kty)
+	}
+	return key, nil
+}
+
+func parseJWKKey(k jwk) (JWTKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return JWTKey{}, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil {
+			return JWTKey{}, err
+		}
+		modulus, exponent := new(big.Int).SetBytes(n), new(big.Int).SetBytes(e)
+		if modulus.BitLen() < minRSAKeyBits {
+			return JWTKey{}, fmt.Errorf("RSA keys must be at least %d bits, got %d", minRSAKeyBits, modulus.BitLen())
+		}
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return JWTKey{}, errors.New("invalid RSA exponent")
+		}
+		public := &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}
+		return JWTKey{ID: k.Kid, Alg: AlgRS256, Key: public}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return JWTKey{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := b64.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return JWTKey{}, errors.New("invalid Ed25519 key")
+		}
+		return JWTKey{ID: k.Kid, Alg: AlgEdDSA, Key: ed25519.PublicKey(x)}, nil
+	case "oct":
+		secret, err := b64.DecodeString(k.K)
+		if err != nil || len(secret) < 32 {
+			return JWTKey{}, errors.New("HS256 keys must be at least 32 bytes")
+		}
+		return JWTKey{ID: k.Kid, Alg: AlgHS256, Key: secret}, nil
+	default:
+		return JWTKey{}, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
+
+// LoadSigningKey reads a PEM private key (PKCS#8 or PKCS#1) and picks RS256 or EdDSA from its type
+func LoadSigningKey(path, kid string) (JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return JWTKey{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return JWTKey{}, fmt.Errorf("%s contains no PEM block", path)
+	}
+
+	var parsed interface{}
+	if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return JWTKey{}, fmt.Errorf("%s: unsupported private key", path)
+		}
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return JWTKey{ID: kid, Alg: AlgRS256, Key: k}, nil
+	case ed25519.PrivateKey:
+		return JWTKey{ID: kid, Alg: AlgEdDSA, Key: k}, nil
+	default:
+		return JWTKey{}, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", path)
+	}
+}
+
+// NewTokenID returns a random jti
+func NewTokenID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return b64.EncodeToString(buf)
+}
+
+// JWTConfig is read from GO_API_JWT_* environment variables
+type JWTConfig struct {
+	Verifier   *JWTVerifier
+	SigningKey *JWTKey
+	TTL        time.Duration
+}
+
+// LoadJWTConfig returns nil when neither GO_API_JWT_SECRET nor GO_API_JWT_JWKS is set.
+// Tokens are issued with GO_API_JWT_SIGNING_KEY (RS256/EdDSA PEM) if present, otherwise with the HS256 secret.
+func LoadJWTConfig() (*JWTConfig, error) {
+	secret := os.Getenv("GO_API_JWT_SECRET")
+	jwksPath := os.Getenv("GO_API_JWT_JWKS")
+	signingKeyPath := os.Getenv("GO_API_JWT_SIGNING_KEY")
+	if secret == "" && jwksPath == "" && signingKeyPath == "" {
+		return nil, nil
+	}
+
+	config := &JWTConfig{
+		Verifier: &JWTVerifier{
+			Issuer:     envOr("GO_API_JWT_ISSUER", "go-hotel-api"),
+			Audience:   envOr("GO_API_JWT_AUDIENCE", "go-hotel-api"),
+			RolesClaim: envOr("GO_API_JWT_ROLES_CLAIM", "roles"),
+		},
+		TTL: 15 * time.Minute,
+	}
+
+	if v := os.Getenv("GO_API_JWT_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("GO_API_JWT_TTL must be a positive duration, got %q", v)
+		}
+		config.TTL = ttl
+	}
+
+	if secret != "" {
+		if len(secret) < 32 {
+			return nil, errors.New("GO_API_JWT_SECRET must be at least 32 characters")
+		}
+		key := JWTKey{Alg: AlgHS256, Key: []byte(secret)}
+		config.Verifier.Keys = append(config.Verifier.Keys, key)
+		config.SigningKey = &key
+	}
+
+	if jwksPath != "" {
+		keys, err := LoadJWKS(jwksPath)
+		if err != nil {
+			return nil, err
+		}
+		config.Verifier.Keys = append(config.Verifier.Keys, keys...)
+	}
+
+	if signingKeyPath != "" {
+		key, err := LoadSigningKey(signingKeyPath, os.Getenv("GO_API_JWT_SIGNING_KID"))
+		if err != nil {
+			return nil, err
+		}
+		// Власні токени мають перевірятися навіть без JWKS
+		config.Verifier.Keys = append(config.Verifier.Keys, key)
+		config.SigningKey = &key
+	}
+
+	if len(config.Verifier.Keys) == 0 {
+		return nil, errors.New("JWKS file has no signature keys")
+	}
+	return config, nil
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
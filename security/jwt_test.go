@@ -0,0 +1,260 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var jwtTestNow = time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+
+// rawToken signs header and claims as given, so a test can put anything into the header
+func rawToken(t *testing.T, header map[string]string, claims Claims, signer func(input []byte) []byte) string {
+	t.Helper()
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := b64.EncodeToString(headerJSON) + "." + b64.EncodeToString(payload)
+	return input + "." + b64.EncodeToString(signer([]byte(input)))
+}
+
+func hmacSigner(secret []byte) func(input []byte) []byte {
+	return func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+}
+
+func validClaims() Claims {
+	return Claims{
+		Issuer:    "go-hotel-api",
+		Subject:   "olena",
+		Audience:  audience{"go-hotel-api"},
+		ExpiresAt: jwtTestNow.Add(time.Hour).Unix(),
+		IssuedAt:  jwtTestNow.Unix(),
+	}
+}
+
+func TestJWTVerify(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hsKey := JWTKey{ID: "hs", Alg: AlgHS256, Key: secret}
+	rsKey := JWTKey{ID: "rs", Alg: AlgRS256, Key: &rsaKey.PublicKey}
+	signRS := func(input []byte) []byte {
+		digest := sha256.Sum256(input)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+	signEd := func(input []byte) []byte { return ed25519.Sign(edKey, input) }
+	withClaims := func(change func(c *Claims)) Claims {
+		claims := validClaims()
+		change(&claims)
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		keys  []JWTKey
+		token string
+		want  error
+	}{
+		{"HS256", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256", "kid": "hs"}, validClaims(), hmacSigner(secret)), nil},
+		{"RS256 header against an HS256 key", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "RS256", "kid": "hs"}, validClaims(), signRS), ErrTokenSignature},
+		{"EdDSA header against an HS256 key", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "EdDSA"}, validClaims(), signEd), ErrTokenSignature},
+		{"HS256 signed with the RSA public key", []JWTKey{rsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, validClaims(), hmacSigner(rsaPublicDER)), ErrTokenSignature},
+		{"alg none", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "none"}, validClaims(), func([]byte) []byte { return nil }), ErrTokenSignature},
+		{"unknown kid", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256", "kid": "other"}, validClaims(), hmacSigner(secret)), ErrTokenSignature},
+		{"kid picks among keys", []JWTKey{hsKey, rsKey},
+			rawToken(t, map[string]string{"alg": "RS256", "kid": "rs"}, validClaims(), signRS), nil},
+		{"expired within the clock skew", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) {
+				c.ExpiresAt = jwtTestNow.Add(-jwtClockSkew + time.Second).Unix()
+			}), hmacSigner(secret)), nil},
+		{"expired beyond the clock skew", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) {
+				c.ExpiresAt = jwtTestNow.Add(-jwtClockSkew - time.Second).Unix()
+			}), hmacSigner(secret)), ErrTokenExpired},
+		{"without exp", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) { c.ExpiresAt = 0 }), hmacSigner(secret)), ErrTokenExpired},
+		{"nbf within the clock skew", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) {
+				c.NotBefore = jwtTestNow.Add(jwtClockSkew - time.Second).Unix()
+			}), hmacSigner(secret)), nil},
+		{"nbf beyond the clock skew", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) {
+				c.NotBefore = jwtTestNow.Add(jwtClockSkew + time.Second).Unix()
+			}), hmacSigner(secret)), ErrTokenClaims},
+		{"audience array", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) {
+				c.Audience = audience{"billing", "go-hotel-api"}
+			}), hmacSigner(secret)), nil},
+		{"audience array without ours", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) {
+				c.Audience = audience{"billing", "reports"}
+			}), hmacSigner(secret)), ErrTokenClaims},
+		{"another issuer", []JWTKey{hsKey},
+			rawToken(t, map[string]string{"alg": "HS256"}, withClaims(func(c *Claims) { c.Issuer = "someone-else" }), hmacSigner(secret)), ErrTokenClaims},
+		{"two parts", []JWTKey{hsKey}, "eyJhbGciOiJIUzI1NiJ9.e30", ErrTokenMalformed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier := &JWTVerifier{Keys: tc.keys, Issuer: "go-hotel-api", Audience: "go-hotel-api"}
+			_, err := verifier.Verify(tc.token, jwtTestNow)
+			if tc.want == nil && err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Fatalf("Verify gave %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func rsaJWK(key *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": "rs", "n": b64.EncodeToString(key.N.Bytes()),
+		"e": b64.EncodeToString(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func TestLoadJWKSRejectsMalformedKeys(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withAlg := func(key map[string]string, alg string) map[string]string {
+		key["alg"] = alg
+		return key
+	}
+
+	tests := []struct {
+		name string
+		keys []map[string]string
+	}{
+		{"RSA key under 2048 bits", []map[string]string{rsaJWK(&small.PublicKey)}},
+		{"RSA modulus that isn't base64url", []map[string]string{{"kty": "RSA", "n": "not base64!", "e": "AQAB"}}},
+		{"RSA exponent 1", []map[string]string{{"kty": "RSA", "n": rsaJWK(&large.PublicKey)["n"], "e": "AQ"}}},
+		{"short Ed25519 key", []map[string]string{{"kty": "OKP", "crv": "Ed25519", "x": b64.EncodeToString(edPublic[:16])}}},
+		{"another curve", []map[string]string{{"kty": "OKP", "crv": "X25519", "x": b64.EncodeToString(edPublic)}}},
+		{"short HS256 secret", []map[string]string{{"kty": "oct", "k": b64.EncodeToString([]byte("short"))}}},
+		{"unknown kty", []map[string]string{{"kty": "EC", "crv": "P-256"}}},
+		{"alg that doesn't fit the key", []map[string]string{withAlg(map[string]string{"kty": "OKP", "crv": "Ed25519",
+			"x": b64.EncodeToString(edPublic)}, AlgRS256)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if keys, err := LoadJWKS(writeJWKS(t, tc.keys...)); err == nil {
+				t.Fatalf("LoadJWKS accepted %v", keys)
+			}
+		})
+	}
+
+	t.Run("not JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(path, []byte(`{"keys": [`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadJWKS(path); err == nil {
+			t.Fatal("LoadJWKS accepted a truncated file")
+		}
+	})
+}
+
+func TestLoadJWKSKeepsTheKeyAlg(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := map[string]string{"kty": "OKP", "crv": "Ed25519", "kid": "ed", "alg": AlgEdDSA, "x": b64.EncodeToString(edPublic)}
+	encryption := rsaJWK(&rsaKey.PublicKey)
+	encryption["use"] = "enc"
+	rs384 := rsaJWK(&rsaKey.PublicKey)
+	rs384["alg"] = "RS384"
+
+	keys, err := LoadJWKS(writeJWKS(t, ed, encryption, rs384))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != "ed" || keys[0].Alg != AlgEdDSA {
+		t.Fatalf("keys = %+v, want only the EdDSA signing key", keys)
+	}
+
+	verifier := &JWTVerifier{Keys: keys}
+	signed, err := SignJWT(validClaims(), "", JWTKey{ID: "ed", Alg: AlgEdDSA, Key: edKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(signed, jwtTestNow); err != nil {
+		t.Fatalf("EdDSA token: %v", err)
+	}
+	// Той самий kid, але інший alg у заголовку
+	mismatched := rawToken(t, map[string]string{"alg": AlgRS256, "kid": "ed"}, validClaims(), func(input []byte) []byte {
+		digest := sha256.Sum256(input)
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		return signature
+	})
+	if _, err := verifier.Verify(mismatched, jwtTestNow); !errors.Is(err, ErrTokenSignature) {
+		t.Fatalf("token with alg RS256 for an EdDSA key gave %v, want ErrTokenSignature", err)
+	}
+}
@@ -1,6 +1,8 @@
 package security
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/sha512"
 	"crypto/subtle"
 	"encoding/hex"
@@ -25,13 +27,32 @@ func CompareHash(apiKey string, storedHashHex string, saltHex string) bool {
 		return false
 	}
 
+	comparisonHash := deriveHash(apiKey, salt)
+
+	return subtle.ConstantTimeCompare(storedHash, comparisonHash) == 1
+}
+
+// HashSecret hashes a new API key or password with a fresh random salt, in the form CompareHash expects
+func HashSecret(secret string) (hashHex string, saltHex string, err error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(deriveHash(secret, salt)), hex.EncodeToString(salt), nil
+}
+
+func deriveHash(secret string, salt []byte) []byte {
 	// Фаза 1: хешування sha512
 	sha512Hasher := sha512.New()
-	sha512Hasher.Write([]byte(apiKey))
+	sha512Hasher.Write([]byte(secret))
 	sha512Hash := sha512Hasher.Sum(nil)
 
 	// Фаза 2: хешування хешу Argon2
-	comparisonHash := argon2.IDKey(sha512Hash, salt, iterations, memory, parallelism, keyLength)
+	return argon2.IDKey(sha512Hash, salt, iterations, memory, parallelism, keyLength)
+}
 
-	return subtle.ConstantTimeCompare(storedHash, comparisonHash) == 1
+// KeyID is a short, non-reversible identifier of an API key, safe to log and to use in config
+func KeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
 }
@@ -0,0 +1,203 @@
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const certReloadInterval = 30 * time.Second
+
+// TLSConfig is read from GO_API_TLS_* environment variables
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	// RequireClientCert rejects handshakes without a valid client certificate;
+	// otherwise the certificate is optional and API keys still work
+	RequireClientCert bool
+	// ClientIdentities maps a certificate subject (e.g. "CN=partner-a,O=Acme") to what it authenticates as
+	ClientIdentities map[string]ClientCertMapping
+}
+
+// ClientCertMapping is the identity a client certificate stands for; roles and hotel scope work
+// as for staff accounts, e.g. {"name": "partner-a", "roles": ["receptionist"], "hotel_ids": [3]}
+type ClientCertMapping struct {
+	Name     string   `json:"name"`
+	Roles    []string `json:"roles"`
+	HotelIDs []uint   `json:"hotel_ids"`
+}
+
+// LoadTLSConfig returns nil when GO_API_TLS_CERT is not set, i.e. the server stays on plain HTTP
+func LoadTLSConfig() (*TLSConfig, error) {
+	certFile := os.Getenv("GO_API_TLS_CERT")
+	keyFile := os.Getenv("GO_API_TLS_KEY")
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("GO_API_TLS_CERT and GO_API_TLS_KEY must be set together")
+	}
+
+	config := &TLSConfig{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		ClientCAFile:      os.Getenv("GO_API_TLS_CLIENT_CA"),
+		RequireClientCert: os.Getenv("GO_API_TLS_CLIENT_AUTH") == "require",
+	}
+
+	if path := os.Getenv("GO_API_CLIENT_CERTS"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &config.ClientIdentities); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for subject, mapping := range config.ClientIdentities {
+			if mapping.Name == "" || len(mapping.Roles) == 0 {
+				return nil, fmt.Errorf("%s: %q needs a name and at least one role", path, subject)
+			}
+		}
+	}
+	if config.RequireClientCert && config.ClientCAFile == "" {
+		return nil, errors.New("GO_API_TLS_CLIENT_AUTH=require needs GO_API_TLS_CLIENT_CA")
+	}
+	return config, nil
+}
+
+// CertReloader serves the current certificate and reloads it when the files change on disk
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *CertReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch polls the files and swaps the certificate when they change.
+// A broken pair (e.g. cert written, key not yet) keeps the old certificate until the next poll.
+func (r *CertReloader) Watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(certReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloaded, err := r.reloadIfChanged()
+			if err != nil {
+				log.Printf("TLS certificate reload failed, keeping the old one: %v", err)
+			} else if reloaded {
+				log.Println("TLS certificate reloaded.")
+			}
+		}
+	}
+}
+
+// reloadIfChanged loads the pair again when either file is newer than the loaded certificate
+func (r *CertReloader) reloadIfChanged() (bool, error) {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	changed := modTime.After(r.modTime)
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	return true, r.reload()
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// ServerTLSConfig builds the tls.Config for http.Server, with client certificate checks when a CA is set
+func (c *TLSConfig) ServerTLSConfig(reloader *CertReloader) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if c.ClientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s contains no PEM certificates", c.ClientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if c.RequireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientCertIdentity returns the identity mapped to the verified client certificate, if any
+func (c *TLSConfig) ClientCertIdentity(state *tls.ConnectionState) (Identity, bool) {
+	if c == nil || state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+	mapping, ok := c.ClientIdentities[state.VerifiedChains[0][0].Subject.String()]
+	if !ok {
+		return Identity{}, false
+	}
+	return Identity{Method: AuthMethodClientCert, Name: mapping.Name, Roles: mapping.Roles, HotelIDs: mapping.HotelIDs}, true
+}
@@ -0,0 +1,294 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testCA signs the server and client certificates of a test
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var testSerial int64
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a leaf certificate and key in PEM; a server certificate is valid for 127.0.0.1
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, server bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) clientCert(t *testing.T, subject pkix.Name) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, subject, false)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var partner = pkix.Name{CommonName: "partner-a", Organization: []string{"Acme"}}
+
+// startTLSServer serves the identity mapped to the client certificate, or 401 without one
+func startTLSServer(t *testing.T, ca *testCA, requireClientCert bool) (string, *TLSConfig, *CertReloader) {
+	t.Helper()
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "api"}, true)
+	config := &TLSConfig{
+		CertFile:          filepath.Join(dir, "server.crt"),
+		KeyFile:           filepath.Join(dir, "server.key"),
+		ClientCAFile:      filepath.Join(dir, "clients.crt"),
+		RequireClientCert: requireClientCert,
+		ClientIdentities: map[string]ClientCertMapping{
+			"CN=partner-a,O=Acme": {Name: "partner-a", Roles: []string{"receptionist"}, HotelIDs: []uint{3}},
+		},
+	}
+	now := time.Now()
+	writeFile(t, config.CertFile, certPEM, now)
+	writeFile(t, config.KeyFile, keyPEM, now)
+	writeFile(t, config.ClientCAFile, ca.pem, now)
+
+	reloader, err := NewCertReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverTLS, err := config.ServerTLSConfig(reloader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := config.ClientCertIdentity(r.TLS)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Identity", identity.Name)
+		w.Header().Set("X-Method", identity.Method)
+		if !slices.Equal(identity.Roles, []string{"receptionist"}) || !slices.Equal(identity.HotelIDs, []uint{3}) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	// StartTLS would put its own certificate first, so the listener serves the reloader's
+	server.Listener = tls.NewListener(server.Listener, serverTLS)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Start()
+	t.Cleanup(server.Close)
+	return "https://" + server.Listener.Addr().String(), config, reloader
+}
+
+// clientFor trusts the CA's server certificates and presents the given client certificate
+// even when the server does not list its issuer, so the server is the one to refuse it
+func clientFor(ca *testCA, certs ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: roots}
+	if len(certs) > 0 {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &certs[0], nil
+		}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+func TestClientCertificates(t *testing.T) {
+	ca := newTestCA(t, "clients")
+	other := newTestCA(t, "someone else")
+
+	tests := []struct {
+		name       string
+		require    bool
+		cert       []tls.Certificate
+		wantStatus int
+		wantErr    bool
+	}{
+		{"mapped certificate", true, []tls.Certificate{ca.clientCert(t, partner)}, http.StatusOK, false},
+		{"unmapped certificate", true, []tls.Certificate{ca.clientCert(t, pkix.Name{CommonName: "partner-b"})}, http.StatusUnauthorized, false},
+		{"certificate of another CA", true, []tls.Certificate{other.clientCert(t, partner)}, 0, true},
+		{"certificate of another CA when optional", false, []tls.Certificate{other.clientCert(t, partner)}, 0, true},
+		{"no certificate when required", true, nil, 0, true},
+		{"no certificate when optional", false, nil, http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, _, _ := startTLSServer(t, ca, tt.require)
+			resp, err := clientFor(ca, tt.cert...).Get(url)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("request succeeded with status %d, want a failed handshake", resp.StatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got := resp.Header.Get("X-Identity"); got != "partner-a" {
+					t.Errorf("identity = %q, want partner-a", got)
+				}
+				if got := resp.Header.Get("X-Method"); got != AuthMethodClientCert {
+					t.Errorf("method = %q, want %q", got, AuthMethodClientCert)
+				}
+			}
+		})
+	}
+}
+
+func TestCertReloaderSwapsCertificate(t *testing.T) {
+	ca := newTestCA(t, "clients")
+	url, config, reloader := startTLSServer(t, ca, false)
+
+	served := func() string {
+		t.Helper()
+		resp, err := clientFor(ca).Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	if got := served(); got != "api" {
+		t.Fatalf("served %q before the reload, want api", got)
+	}
+
+	if reloaded, err := reloader.reloadIfChanged(); reloaded || err != nil {
+		t.Fatalf("reloadIfChanged on unchanged files = %v, %v", reloaded, err)
+	}
+
+	// Сертифікат уже записано, а ключ ще ні: лишається старий
+	later := time.Now().Add(time.Minute)
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "api-renewed"}, true)
+	writeFile(t, config.CertFile, certPEM, later)
+	if _, err := reloader.reloadIfChanged(); err == nil {
+		t.Fatal("reloadIfChanged accepted a certificate without its key")
+	}
+	if got := served(); got != "api" {
+		t.Fatalf("served %q after a broken reload, want api", got)
+	}
+
+	writeFile(t, config.KeyFile, keyPEM, later)
+	if reloaded, err := reloader.reloadIfChanged(); !reloaded || err != nil {
+		t.Fatalf("reloadIfChanged = %v, %v, want a reload", reloaded, err)
+	}
+	if got := served(); got != "api-renewed" {
+		t.Fatalf("served %q after the reload, want api-renewed", got)
+	}
+}
+
+func TestLoadTLSConfigRequiresRolesInMapping(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GO_API_TLS_CERT", filepath.Join(dir, "server.crt"))
+	t.Setenv("GO_API_TLS_KEY", filepath.Join(dir, "server.key"))
+
+	tests := []struct {
+		name    string
+		mapping string
+		wantErr bool
+	}{
+		{"name and roles", `{"CN=partner-a,O=Acme": {"name": "partner-a", "roles": ["receptionist"], "hotel_ids": [3]}}`, false},
+		{"no roles", `{"CN=partner-a,O=Acme": {"name": "partner-a"}}`, true},
+		{"plain name", `{"CN=partner-a,O=Acme": "partner-a"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "clients.json")
+			writeFile(t, path, []byte(tt.mapping), time.Now())
+			t.Setenv("GO_API_CLIENT_CERTS", path)
+
+			config, err := LoadTLSConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadTLSConfig error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(config.ClientIdentities["CN=partner-a,O=Acme"].HotelIDs, []uint{3}) {
+				t.Errorf("mapping = %+v", config.ClientIdentities)
+			}
+		})
+	}
+}
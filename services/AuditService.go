@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditEntities are the entity types accepted by ?entity=
+var auditEntities = map[string]bool{
+	models.AuditEntityHotel:    true,
+	models.AuditEntityRoom:     true,
+	models.AuditEntityRoomType: true,
+	models.AuditEntityGuest:    true,
+	models.AuditEntityBooking:  true,
+	models.AuditEntityPolicy:   true,
+	models.AuditEntityTaxRule:  true,
+	models.AuditEntityPayment:  true,
+	models.AuditEntityInvoice:  true,
+}
+
+type AuditService interface {
+	Find(ctx context.Context, filter repositories.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type auditServiceImpl struct {
+	repo repositories.AuditRepository
+}
+
+func NewAuditService(repo repositories.AuditRepository) AuditService {
+	return &auditServiceImpl{repo: repo}
+}
+
+func (s *auditServiceImpl) Find(ctx context.Context, filter repositories.AuditFilter) ([]models.AuditEntry, error) {
+	if err := authorize(ctx, actionViewAudit); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	if filter.EntityType != "" && !auditEntities[filter.EntityType] {
+		problems = append(problems, "entity must be one of hotel, room, room_type, guest, booking, cancellation_policy, tax_rule, payment, invoice")
+	}
+	if filter.EntityID != 0 && filter.EntityType == "" {
+		problems = append(problems, "id requires entity")
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuditLimit {
+		problems = append(problems, "limit must be between 1 and 1000")
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	return s.repo.Find(filter)
+}
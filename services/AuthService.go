@@ -0,0 +1,140 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mod/repositories"
+	"go.mod/security"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrTokensDisabled     = errors.New("token issuing is not configured")
+)
+
+// StaffAccount is a staff login; the password is stored as security.HashSecret output
+type StaffAccount struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"password_hash"`
+	PasswordSalt string   `json:"password_salt"`
+	Roles        []string `json:"roles"`
+	HotelIDs     []uint   `json:"hotel_ids"`
+}
+
+type StaffStore interface {
+	FindByUsername(username string) (StaffAccount, bool, error)
+}
+
+type fileStaffStore struct {
+	accounts map[string]StaffAccount
+}
+
+// LoadStaffFile reads a JSON array of StaffAccount
+func LoadStaffFile(path string) (StaffStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []StaffAccount
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	store := &fileStaffStore{accounts: make(map[string]StaffAccount, len(accounts))}
+	for _, account := range accounts {
+		store.accounts[account.Username] = account
+	}
+	return store, nil
+}
+
+func (s *fileStaffStore) FindByUsername(username string) (StaffAccount, bool, error) {
+	account, ok := s.accounts[username]
+	return account, ok, nil
+}
+
+type userStaffStore struct {
+	repo repositories.UserRepository
+}
+
+// NewUserStaffStore looks staff up in the users table
+func NewUserStaffStore(repo repositories.UserRepository) StaffStore {
+	return &userStaffStore{repo: repo}
+}
+
+func (s *userStaffStore) FindByUsername(username string) (StaffAccount, bool, error) {
+	user, err := s.repo.GetByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return StaffAccount{}, false, nil
+	}
+	if err != nil {
+		return StaffAccount{}, false, err
+	}
+
+	account := StaffAccount{
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		PasswordSalt: user.PasswordSalt,
+		Roles:        []string{user.Role},
+	}
+	for _, hotel := range user.Hotels {
+		account.HotelIDs = append(account.HotelIDs, hotel.ID)
+	}
+	return account, true, nil
+}
+
+type AuthService interface {
+	IssueToken(username, password string) (token string, expiresIn time.Duration, err error)
+}
+
+type authServiceImpl struct {
+	staff  StaffStore
+	config *security.JWTConfig
+}
+
+func NewAuthService(staff StaffStore, config *security.JWTConfig) AuthService {
+	return &authServiceImpl{staff: staff, config: config}
+}
+
+// dummyHash/dummySalt keep the response time the same whether or not the username exists
+var dummyHash, dummySalt, _ = security.HashSecret("dummy password")
+
+func (s *authServiceImpl) IssueToken(username, password string) (string, time.Duration, error) {
+	if s.config == nil || s.config.SigningKey == nil || s.staff == nil {
+		return "", 0, ErrTokensDisabled
+	}
+
+	account, found, err := s.staff.FindByUsername(username)
+	if err != nil {
+		return "", 0, err
+	}
+	if !found {
+		security.CompareHash(password, dummyHash, dummySalt)
+		return "", 0, ErrInvalidCredentials
+	}
+	if !security.CompareHash(password, account.PasswordHash, account.PasswordSalt) {
+		return "", 0, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	claims := security.Claims{
+		Issuer:    s.config.Verifier.Issuer,
+		Subject:   account.Username,
+		Audience:  []string{s.config.Verifier.Audience},
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(s.config.TTL).Unix(),
+		ID:        security.NewTokenID(),
+		Roles:     account.Roles,
+		HotelIDs:  account.HotelIDs,
+	}
+	token, err := security.SignJWT(claims, s.config.Verifier.RolesClaim, *s.config.SigningKey)
+	if err != nil {
+		return "", 0, err
+	}
+	return token, s.config.TTL, nil
+}
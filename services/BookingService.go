@@ -1,42 +1,439 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
 	"go.mod/models"
 	"go.mod/repositories"
+	"gorm.io/gorm"
 )
 
 type BookingService interface {
-	GetAll() ([]models.Booking, error)
-	GetByID(id uint) (models.Booking, error)
-	Create(booking *models.Booking) error
-	Update(booking *models.Booking) error
-	Delete(id uint) error
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.Booking, error)
+	Stream(ctx context.Context, includeDeleted bool, fn func(booking *models.Booking) error) error
+	GetByID(ctx context.Context, id uint) (models.Booking, error)
+	Create(ctx context.Context, booking *models.Booking) error
+	Update(ctx context.Context, booking *models.Booking) error
+	Patch(ctx context.Context, booking *models.Booking, fields []string) error
+	Delete(ctx context.Context, id uint) error
+	GetDeleted(ctx context.Context) ([]models.Booking, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
+	CheckIn(ctx context.Context, id uint, assignments map[uint]uint) (models.Booking, error)
+	Cancel(ctx context.Context, id uint, reason string) (models.Booking, error)
+	CheckOut(ctx context.Context, id uint, override bool) (models.Booking, error)
 }
 
 type bookingServiceImpl struct {
-	repo repositories.BookingRepository
+	repo     repositories.BookingRepository
+	policies repositories.CancellationPolicyRepository
+	invoices InvoiceService
+}
+
+func NewBookingService(repo repositories.BookingRepository, policies repositories.CancellationPolicyRepository,
+	invoices InvoiceService) BookingService {
+	return &bookingServiceImpl{repo: repo, policies: policies, invoices: invoices}
+}
+
+func (s *bookingServiceImpl) GetAll(ctx context.Context, includeDeleted bool) ([]models.Booking, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	bookings, err := s.repo.GetAll(includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return redactBookingGuests(ctx, filterByHotel(ctx, bookings, func(booking *models.Booking) uint { return booking.HotelID })), nil
+}
+
+func (s *bookingServiceImpl) Stream(ctx context.Context, includeDeleted bool, fn func(booking *models.Booking) error) error {
+	if err := authorize(ctx, actionRead); err != nil {
+		return err
+	}
+	return s.repo.Stream(includeDeleted, func(booking *models.Booking) error {
+		if !canAccessHotel(ctx, booking.HotelID) {
+			return nil
+		}
+		redactGuest(ctx, &booking.Guest)
+		return fn(booking)
+	})
+}
+
+func (s *bookingServiceImpl) GetByID(ctx context.Context, id uint) (models.Booking, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return models.Booking{}, err
+	}
+	booking, err := s.repo.GetByID(id)
+	if err != nil {
+		return booking, err
+	}
+	if !canAccessHotel(ctx, booking.HotelID) {
+		return models.Booking{}, ErrForbidden
+	}
+	redactGuest(ctx, &booking.Guest)
+	return booking, nil
+}
+
+// authorizeChange checks write access to the stored booking and to the hotel it is being saved under,
+// so a hotel-scoped user can't move it to a hotel they don't work at. It returns the stored booking.
+func (s *bookingServiceImpl) authorizeChange(ctx context.Context, booking *models.Booking) (models.Booking, error) {
+	existing, err := s.repo.GetByID(booking.ID)
+	if err != nil {
+		return existing, err
+	}
+	if err := authorizeHotel(ctx, actionWrite, existing.HotelID); err != nil {
+		return existing, err
+	}
+	return existing, authorizeHotel(ctx, actionWrite, booking.HotelID)
+}
+
+// keepLifecycle carries the stored status and cancellation over to a booking being saved. Only
+// CheckIn, Cancel, CheckOut and the no-show job move a booking along, charging what they must;
+// a save that asks for another status is refused rather than skipping their checks.
+func keepLifecycle(booking, stored *models.Booking) error {
+	if booking.Status != "" && booking.Status != stored.Status {
+		return &ValidationError{Problems: []string{fmt.Sprintf(
+			"status can't be changed from %s to %s by saving the booking; check it in, cancel it or check it out instead",
+			stored.Status, booking.Status)}}
+	}
+	booking.Status = stored.Status
+	booking.CancelledAt = stored.CancelledAt
+	booking.CancellationReason = stored.CancellationReason
+	booking.CancellationPenalty = stored.CancellationPenalty
+	return nil
+}
+
+const (
+	// maxChildAge is the oldest age that still counts as a child
+	maxChildAge           = 17
+	maxCancellationReason = 500
+)
+
+// checkCapacity refuses a party that doesn't fit the rooms the booking holds
+func (s *bookingServiceImpl) checkCapacity(booking *models.Booking) error {
+	if booking.Status == models.BookingStatusCancelled || booking.Status == models.BookingStatusNoShow {
+		return nil
+	}
+	capacity, hasRooms, err := s.repo.Capacity(booking)
+	if err != nil {
+		return err
+	}
+	if party := booking.Adults + booking.Children; hasRooms && party > capacity {
+		return &ValidationError{Problems: []string{
+			fmt.Sprintf("a party of %d doesn't fit the booked rooms, which sleep %d", party, capacity),
+		}}
+	}
+	return nil
+}
+
+// validate fills the party defaults for clients that don't send it, then checks the booking and its capacity
+func (s *bookingServiceImpl) validate(booking *models.Booking) error {
+	if booking.Adults == 0 && booking.Children == 0 {
+		booking.Adults = 1
+	}
+	if problems := ValidateBooking(booking); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if err := checkPolicy(s.policies, booking.CancellationPolicyID, booking.HotelID); err != nil {
+		return err
+	}
+	return s.checkCapacity(booking)
+}
+
+// bookingPolicy is the cancellation policy the booking was made under, or else the hotel's default; nil if neither has one
+func bookingPolicy(policies repositories.CancellationPolicyRepository, booking *models.Booking) (*models.CancellationPolicy, error) {
+	policyID := booking.CancellationPolicyID
+	if policyID == nil {
+		policyID = booking.Hotel.CancellationPolicyID
+	}
+	if policyID == nil {
+		return nil, nil
+	}
+	// Видалена політика все одно діє для бронювань, зроблених за нею
+	policy, err := policies.GetAnyByID(*policyID)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// checkPolicy refuses a cancellation policy that doesn't exist or belongs to another hotel
+func checkPolicy(policies repositories.CancellationPolicyRepository, policyID *uint, hotelID uint) error {
+	if policyID == nil {
+		return nil
+	}
+	policy, err := policies.GetByID(*policyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && policy.HotelID != hotelID) {
+		return &ValidationError{Problems: []string{
+			fmt.Sprintf("cancellation policy %d doesn't exist at hotel %d", *policyID, hotelID),
+		}}
+	}
+	return err
+}
+
+func (s *bookingServiceImpl) Create(ctx context.Context, booking *models.Booking) error {
+	if err := authorizeHotel(ctx, actionWrite, booking.HotelID); err != nil {
+		return err
+	}
+	if err := keepLifecycle(booking, &models.Booking{Status: models.BookingStatusConfirmed}); err != nil {
+		return err
+	}
+	if err := s.validate(booking); err != nil {
+		return err
+	}
+	// Кімнати призначаються лише під час заселення
+	for i := range booking.Reservations {
+		booking.Reservations[i].ID = 0
+		booking.Reservations[i].RoomID = nil
+	}
+	return s.repo.Create(ctx, booking)
+}
+
+func (s *bookingServiceImpl) Update(ctx context.Context, booking *models.Booking) error {
+	existing, err := s.authorizeChange(ctx, booking)
+	if err != nil {
+		return err
+	}
+	if err := keepLifecycle(booking, &existing); err != nil {
+		return err
+	}
+	if err := s.validate(booking); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, booking)
+}
+
+func (s *bookingServiceImpl) Patch(ctx context.Context, booking *models.Booking, fields []string) error {
+	existing, err := s.authorizeChange(ctx, booking)
+	if err != nil {
+		return err
+	}
+	if err := keepLifecycle(booking, &existing); err != nil {
+		return err
+	}
+	if err := s.validate(booking); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.repo.Patch(ctx, booking, fields)
+}
+
+func (s *bookingServiceImpl) Delete(ctx context.Context, id uint) error {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionWrite, existing.HotelID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// CheckIn assigns rooms to the booking's reservations and marks it checked in. assignments maps
+// reservation IDs to the rooms picked at the desk; the other reservations get a free room of their type.
+func (s *bookingServiceImpl) CheckIn(ctx context.Context, id uint, assignments map[uint]uint) (models.Booking, error) {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	if err := authorizeHotel(ctx, actionWrite, existing.HotelID); err != nil {
+		return models.Booking{}, err
+	}
+
+	reservations := make(map[uint]bool, len(existing.Reservations))
+	for _, reservation := range existing.Reservations {
+		reservations[reservation.ID] = true
+	}
+	var problems []string
+	for reservationID := range assignments {
+		if !reservations[reservationID] {
+			problems = append(problems, fmt.Sprintf("reservation %d is not part of booking %d", reservationID, id))
+		}
+	}
+	if len(problems) > 0 {
+		return models.Booking{}, &ValidationError{Problems: problems}
+	}
+
+	if !existing.CheckIn.IsZero() {
+		now := time.Now()
+		if opens, cutoff := checkInWindow(&existing); now.Before(opens) || !now.Before(cutoff) {
+			location := hotelLocation(&existing.Hotel)
+			return models.Booking{}, &repositories.ConflictError{
+				Message: fmt.Sprintf("booking %d can only be checked in from %s until %s hotel time", id,
+					opens.In(location).Format("2006-01-02 15:04"), cutoff.In(location).Format("2006-01-02 15:04")),
+			}
+		}
+	}
+
+	if err := s.repo.CheckIn(ctx, id, assignments); err != nil {
+		return models.Booking{}, err
+	}
+	return s.reload(ctx, id)
+}
+
+// Cancel cancels a confirmed booking and charges the penalty of its cancellation policy, or of the
+// hotel's default one. The booking is kept with the reason and the penalty for the history.
+func (s *bookingServiceImpl) Cancel(ctx context.Context, id uint, reason string) (models.Booking, error) {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	if err := authorizeHotel(ctx, actionWrite, existing.HotelID); err != nil {
+		return models.Booking{}, err
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return models.Booking{}, &ValidationError{Problems: []string{"reason is required"}}
+	}
+	if len(reason) > maxCancellationReason {
+		return models.Booking{}, &ValidationError{Problems: []string{
+			fmt.Sprintf("reason can't be longer than %d characters", maxCancellationReason),
+		}}
+	}
+	if existing.Status != models.BookingStatusConfirmed {
+		return models.Booking{}, &repositories.ConflictError{
+			Message: fmt.Sprintf("booking %d is %s and can't be cancelled", id, existing.Status),
+		}
+	}
+
+	policy, err := bookingPolicy(s.policies, &existing)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	penalty := CancellationPenalty(policy, &existing, hotelToday(&existing.Hotel, time.Now()))
+	if err := s.repo.Cancel(ctx, id, reason, penalty); err != nil {
+		return models.Booking{}, err
+	}
+	return s.reload(ctx, id)
+}
+
+// CheckOut ends the stay and issues the invoice. It is refused while the guest still owes money,
+// unless a manager overrides it, e.g. for a company that pays by invoice.
+func (s *bookingServiceImpl) CheckOut(ctx context.Context, id uint, override bool) (models.Booking, error) {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	action := actionWrite
+	if override {
+		action = actionManageHotels
+	}
+	if err := authorizeHotel(ctx, action, existing.HotelID); err != nil {
+		return models.Booking{}, err
+	}
+
+	err = s.repo.CheckOut(ctx, id, func(booking *models.Booking, ledger []models.Payment) error {
+		balance := BookingBalance(booking, ledger)
+		if balance.Outstanding > 0 && !override {
+			return &repositories.ConflictError{
+				Message: fmt.Sprintf("booking %d still has a balance of %.2f to pay before check-out", id, balance.Outstanding),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Booking{}, err
+	}
+	// Гість уже виїхав, тож невдалий рахунок не скасовує виїзд; його можна виписати повторно
+	if _, _, err := s.invoices.Issue(ctx, id); err != nil {
+		log.Printf("Booking %d checked out, but its invoice wasn't issued: %v", id, err)
+	}
+	return s.reload(ctx, id)
 }
 
-func NewBookingService(repo repositories.BookingRepository) BookingService {
-	return &bookingServiceImpl{repo: repo}
+// GetDeleted lists the bookings in the trash that the caller may see
+func (s *bookingServiceImpl) GetDeleted(ctx context.Context) ([]models.Booking, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	bookings, err := s.repo.GetDeleted()
+	if err != nil {
+		return nil, err
+	}
+	return redactBookingGuests(ctx, filterByHotel(ctx, bookings, func(booking *models.Booking) uint { return booking.HotelID })), nil
 }
 
-func (s *bookingServiceImpl) GetAll() ([]models.Booking, error) {
-	return s.repo.GetAll()
+// reload reads the booking back after a change, with its guest as the caller may see them
+func (s *bookingServiceImpl) reload(ctx context.Context, id uint) (models.Booking, error) {
+	booking, err := s.repo.GetByID(id)
+	redactGuest(ctx, &booking.Guest)
+	return booking, err
 }
 
-func (s *bookingServiceImpl) GetByID(id uint) (models.Booking, error) {
-	return s.repo.GetByID(id)
+func redactBookingGuests(ctx context.Context, bookings []models.Booking) []models.Booking {
+	for i := range bookings {
+		redactGuest(ctx, &bookings[i].Guest)
+	}
+	return bookings
 }
 
-func (s *bookingServiceImpl) Create(booking *models.Booking) error {
-	return s.repo.Create(booking)
+func (s *bookingServiceImpl) Restore(ctx context.Context, id uint) error {
+	booking, err := s.repo.GetDeletedByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionWrite, booking.HotelID); err != nil {
+		return err
+	}
+	return s.repo.Restore(ctx, id)
 }
 
-func (s *bookingServiceImpl) Update(booking *models.Booking) error {
-	return s.repo.Update(booking)
+// Purge is permanent, so only admins may do it
+func (s *bookingServiceImpl) Purge(ctx context.Context, id uint) error {
+	if err := authorize(ctx, actionPurge); err != nil {
+		return err
+	}
+	return s.repo.Purge(ctx, id)
 }
 
-func (s *bookingServiceImpl) Delete(id uint) error {
-	return s.repo.Delete(id)
+// ValidateBooking returns a list of problems with the booking, empty if it is valid
+func ValidateBooking(booking *models.Booking) []string {
+	var problems []string
+	if booking.GuestID == 0 {
+		problems = append(problems, "guest_id is required")
+	}
+	if booking.HotelID == 0 {
+		problems = append(problems, "hotel_id is required")
+	}
+	if booking.CheckIn.IsZero() != booking.CheckOut.IsZero() {
+		problems = append(problems, "check_in and check_out must be given together")
+	} else if !booking.CheckIn.IsZero() && !booking.CheckOut.After(booking.CheckIn.Time) {
+		problems = append(problems, "check_out must be after check_in")
+	}
+	switch booking.Status {
+	case "", models.BookingStatusConfirmed, models.BookingStatusCheckedIn, models.BookingStatusCheckedOut,
+		models.BookingStatusCancelled, models.BookingStatusNoShow:
+	default:
+		problems = append(problems, "status must be confirmed, checked_in, checked_out, cancelled or no_show")
+	}
+	if booking.Adults < 1 {
+		problems = append(problems, "adults must be at least 1")
+	}
+	if booking.Children < 0 {
+		problems = append(problems, "children can't be negative")
+	}
+	if len(booking.ChildAges) != booking.Children {
+		problems = append(problems, fmt.Sprintf("child_ages must list the age of each of the %d children", booking.Children))
+	}
+	for i, age := range booking.ChildAges {
+		if age < 0 || age > maxChildAge {
+			problems = append(problems, fmt.Sprintf("child #%d: age must be between 0 and %d", i+1, maxChildAge))
+		}
+	}
+	if len(booking.Reservations) > 0 && booking.CheckIn.IsZero() {
+		problems = append(problems, "reserving a room type requires check_in and check_out")
+	}
+	for i, reservation := range booking.Reservations {
+		if reservation.RoomTypeID == 0 {
+			problems = append(problems, fmt.Sprintf("reservation #%d needs a room_type_id", i+1))
+		}
+	}
+	return problems
 }
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mod/models"
+)
+
+// penaltyBooking arrives on 10 June for three nights in room 101 at 100 with a Suite at 250 still unassigned
+func penaltyBooking() *models.Booking {
+	return &models.Booking{
+		CheckIn:      models.NewDate(2026, 6, 10),
+		CheckOut:     models.NewDate(2026, 6, 13),
+		BookedRooms:  []models.Room{{Number: "101", RoomType: "Double", Price: 100}},
+		Reservations: []models.Reservation{{RoomType: &models.RoomType{Name: "Suite", BasePrice: 250}}},
+	}
+}
+
+func TestCancellationPenalty(t *testing.T) {
+	firstNight := &models.CancellationPolicy{FreeUntilDays: 3, PenaltyType: models.PenaltyTypeFirstNight}
+	half := &models.CancellationPolicy{FreeUntilDays: 3, PenaltyType: models.PenaltyTypePercent, PenaltyPercent: 50}
+	noFreePeriod := &models.CancellationPolicy{PenaltyType: models.PenaltyTypeFirstNight}
+
+	tests := []struct {
+		name   string
+		policy *models.CancellationPolicy
+		today  models.Date
+		want   float32
+	}{
+		{"no policy", nil, models.NewDate(2026, 6, 10), 0},
+		{"well before the deadline", firstNight, models.NewDate(2026, 6, 1), 0},
+		{"on the last free day", firstNight, models.NewDate(2026, 6, 7), 0},
+		{"the day after the deadline", firstNight, models.NewDate(2026, 6, 8), 350},
+		{"on arrival day", firstNight, models.NewDate(2026, 6, 10), 350},
+		{"percent on the last free day", half, models.NewDate(2026, 6, 7), 0},
+		// 50% від (100 + 250) × 3 ночі
+		{"percent after the deadline", half, models.NewDate(2026, 6, 8), 525},
+		{"no free period", noFreePeriod, models.NewDate(2026, 5, 1), 350},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CancellationPenalty(tc.policy, penaltyBooking(), tc.today); got != tc.want {
+				t.Errorf("penalty = %.2f, want %.2f", got, tc.want)
+			}
+		})
+	}
+
+	undated := &models.Booking{BookedRooms: []models.Room{{Price: 100}}}
+	if got := CancellationPenalty(noFreePeriod, undated, models.NewDate(2026, 6, 10)); got != 0 {
+		t.Errorf("penalty of a booking without dates = %.2f, want 0", got)
+	}
+	rounded := &models.CancellationPolicy{PenaltyType: models.PenaltyTypePercent, PenaltyPercent: 33.3}
+	if got := CancellationPenalty(rounded, penaltyBooking(), models.NewDate(2026, 6, 10)); got != 349.65 {
+		t.Errorf("33.3%% penalty = %.4f, want it rounded to 349.65", got)
+	}
+}
+
+// The last free day is 7 June by the hotel's calendar: in Kyiv it ends at 21:00 UTC, in New York four hours
+// after midnight UTC on the 8th
+func TestCancellationDeadlineFollowsTheHotelsCalendar(t *testing.T) {
+	policy := &models.CancellationPolicy{FreeUntilDays: 3, PenaltyType: models.PenaltyTypeFirstNight}
+	kyivHotel := &models.Hotel{TimeZone: "Europe/Kyiv"}
+	newYork := &models.Hotel{TimeZone: "America/New_York"}
+
+	tests := []struct {
+		name  string
+		hotel *models.Hotel
+		at    time.Time
+		want  float32
+	}{
+		{"Kyiv, a second before midnight", kyivHotel, time.Date(2026, 6, 7, 20, 59, 59, 0, time.UTC), 0},
+		{"Kyiv, at midnight", kyivHotel, time.Date(2026, 6, 7, 21, 0, 0, 0, time.UTC), 350},
+		{"New York, when Kyiv is past the deadline", newYork, time.Date(2026, 6, 7, 21, 0, 0, 0, time.UTC), 0},
+		{"New York, a second before midnight", newYork, time.Date(2026, 6, 8, 3, 59, 59, 0, time.UTC), 0},
+		{"New York, at midnight", newYork, time.Date(2026, 6, 8, 4, 0, 0, 0, time.UTC), 350},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CancellationPenalty(policy, penaltyBooking(), hotelToday(tc.hotel, tc.at)); got != tc.want {
+				t.Errorf("penalty = %.2f, want %.2f", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCancelChargesThePolicyOfTheBookingOrHotel(t *testing.T) {
+	hotelPolicyID, bookingPolicyID := uint(1), uint(2)
+	policies := &fakePolicyRepository{policies: map[uint]models.CancellationPolicy{
+		hotelPolicyID:   {FreeUntilDays: 7, PenaltyType: models.PenaltyTypeFirstNight},
+		bookingPolicyID: {PenaltyType: models.PenaltyTypePercent, PenaltyPercent: 100},
+	}}
+	today := models.DateOf(time.Now().UTC())
+	booking := func(id uint, daysAhead int, policyID *uint) models.Booking {
+		b := models.Booking{HotelID: 1, Status: models.BookingStatusConfirmed, CancellationPolicyID: policyID,
+			CheckIn: models.Date{Time: today.AddDate(0, 0, daysAhead)}, CheckOut: models.Date{Time: today.AddDate(0, 0, daysAhead+2)},
+			BookedRooms: []models.Room{{Price: 100}}}
+		b.ID = id
+		b.Hotel.CancellationPolicyID = &hotelPolicyID
+		return b
+	}
+	bookings := newFakeBookingRepository(booking(1, 30, nil), booking(2, 2, nil), booking(3, 30, &bookingPolicyID))
+	service := NewBookingService(bookings, policies, &fakeInvoiceService{})
+
+	for id, want := range map[uint]float32{1: 0, 2: 100, 3: 200} {
+		cancelled, err := service.Cancel(SystemContext(context.Background()), id, "Plans changed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cancelled.Status != models.BookingStatusCancelled || cancelled.CancellationPenalty != want {
+			t.Errorf("booking %d: %s with a penalty of %.2f, want cancelled with %.2f", id, cancelled.Status, cancelled.CancellationPenalty, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+type CancellationPolicyService interface {
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.CancellationPolicy, error)
+	GetByID(ctx context.Context, id uint) (models.CancellationPolicy, error)
+	Create(ctx context.Context, policy *models.CancellationPolicy) error
+	Update(ctx context.Context, policy *models.CancellationPolicy) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type cancellationPolicyServiceImpl struct {
+	repo repositories.CancellationPolicyRepository
+}
+
+func NewCancellationPolicyService(repo repositories.CancellationPolicyRepository) CancellationPolicyService {
+	return &cancellationPolicyServiceImpl{repo: repo}
+}
+
+func (s *cancellationPolicyServiceImpl) GetAll(ctx context.Context, includeDeleted bool) ([]models.CancellationPolicy, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	policies, err := s.repo.GetAll(includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return filterByHotel(ctx, policies, func(policy *models.CancellationPolicy) uint { return policy.HotelID }), nil
+}
+
+func (s *cancellationPolicyServiceImpl) GetByID(ctx context.Context, id uint) (models.CancellationPolicy, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return models.CancellationPolicy{}, err
+	}
+	policy, err := s.repo.GetByID(id)
+	if err != nil {
+		return policy, err
+	}
+	if !canAccessHotel(ctx, policy.HotelID) {
+		return models.CancellationPolicy{}, ErrForbidden
+	}
+	return policy, nil
+}
+
+func (s *cancellationPolicyServiceImpl) Create(ctx context.Context, policy *models.CancellationPolicy) error {
+	if err := authorizeHotel(ctx, actionManageHotels, policy.HotelID); err != nil {
+		return err
+	}
+	if policy.PenaltyType == "" {
+		policy.PenaltyType = models.PenaltyTypeFirstNight
+	}
+	if problems := ValidateCancellationPolicy(policy); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	policy.ID = 0
+	return s.repo.Create(ctx, policy)
+}
+
+func (s *cancellationPolicyServiceImpl) Update(ctx context.Context, policy *models.CancellationPolicy) error {
+	existing, err := s.repo.GetByID(policy.ID)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionManageHotels, existing.HotelID); err != nil {
+		return err
+	}
+	if policy.HotelID != existing.HotelID {
+		return &ValidationError{Problems: []string{"a cancellation policy can't be moved to another hotel"}}
+	}
+	if problems := ValidateCancellationPolicy(policy); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	policy.CreatedAt = existing.CreatedAt
+	return s.repo.Update(ctx, policy)
+}
+
+func (s *cancellationPolicyServiceImpl) Delete(ctx context.Context, id uint) error {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionManageHotels, existing.HotelID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// ValidateCancellationPolicy returns a list of problems with the policy, empty if it is valid
+func ValidateCancellationPolicy(policy *models.CancellationPolicy) []string {
+	var problems []string
+	if policy.HotelID == 0 {
+		problems = append(problems, "hotel_id is required")
+	}
+	if strings.TrimSpace(policy.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if policy.FreeUntilDays < 0 {
+		problems = append(problems, "free_until_days can't be negative")
+	}
+	switch policy.PenaltyType {
+	case models.PenaltyTypeFirstNight:
+	case models.PenaltyTypePercent:
+		if policy.PenaltyPercent <= 0 || policy.PenaltyPercent > 100 || math.IsNaN(float64(policy.PenaltyPercent)) {
+			problems = append(problems, "penalty_percent must be above 0 and at most 100")
+		}
+	default:
+		problems = append(problems, "penalty_type must be percent or first_night")
+	}
+	return problems
+}
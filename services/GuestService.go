@@ -1,42 +1,332 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
 	"go.mod/models"
 	"go.mod/repositories"
 )
 
 type GuestService interface {
-	GetAll() ([]models.Guest, error)
-	GetByID(id uint) (models.Guest, error)
-	Create(guest *models.Guest) error
-	Update(guest *models.Guest) error
-	Delete(id uint) error
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.Guest, error)
+	Stream(ctx context.Context, includeDeleted bool, fn func(guest *models.Guest) error) error
+	GetByID(ctx context.Context, id uint) (models.Guest, error)
+	Create(ctx context.Context, guest *models.Guest) error
+	Update(ctx context.Context, guest *models.Guest) error
+	Patch(ctx context.Context, guest *models.Guest, fields []string) error
+	Delete(ctx context.Context, id uint, cascade bool) error
+	GetDeleted(ctx context.Context) ([]models.Guest, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
 }
 
 type guestServiceImpl struct {
-	repo repositories.GuestRepository
+	repo     repositories.GuestRepository
+	notifier Notifier
+}
+
+func NewGuestService(repo repositories.GuestRepository, notifier Notifier) GuestService {
+	return &guestServiceImpl{repo: repo, notifier: notifier}
+}
+
+func (s *guestServiceImpl) GetAll(ctx context.Context, includeDeleted bool) ([]models.Guest, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	guests, err := s.repo.GetAll(includeDeleted)
+	for i := range guests {
+		redactGuest(ctx, &guests[i])
+	}
+	return guests, err
+}
+
+func (s *guestServiceImpl) Stream(ctx context.Context, includeDeleted bool, fn func(guest *models.Guest) error) error {
+	if err := authorize(ctx, actionRead); err != nil {
+		return err
+	}
+	return s.repo.Stream(includeDeleted, func(guest *models.Guest) error {
+		redactGuest(ctx, guest)
+		return fn(guest)
+	})
+}
+
+func (s *guestServiceImpl) GetByID(ctx context.Context, id uint) (models.Guest, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return models.Guest{}, err
+	}
+	guest, err := s.repo.GetByID(id)
+	redactGuest(ctx, &guest)
+	return guest, err
+}
+
+func (s *guestServiceImpl) Create(ctx context.Context, guest *models.Guest) error {
+	if err := authorize(ctx, actionWrite); err != nil {
+		return err
+	}
+	guest.ConsentUpdatedAt = nil
+	if guest.MarketingEmailConsent || guest.MarketingSMSConsent {
+		now := time.Now()
+		guest.ConsentUpdatedAt = &now
+	}
+	if problems := append(ValidateGuest(guest), checkGuestDocument(guest, nil)...); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if err := s.repo.Create(ctx, guest); err != nil {
+		return err
+	}
+	redactGuest(ctx, guest)
+	return nil
+}
+
+func (s *guestServiceImpl) Update(ctx context.Context, guest *models.Guest) error {
+	if err := s.authorizeGuest(ctx, guest.ID, false, false); err != nil {
+		return err
+	}
+	stored, err := s.repo.GetByID(guest.ID)
+	if err != nil {
+		return err
+	}
+	keepHiddenGuestFields(ctx, guest, &stored)
+	stampConsent(guest, &stored)
+	if problems := append(ValidateGuest(guest), checkGuestDocument(guest, &stored)...); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if err := s.repo.Update(ctx, guest); err != nil {
+		return err
+	}
+	redactGuest(ctx, guest)
+	return nil
+}
+
+// Patch expects guest to be the stored guest as the caller saw it, with the patch applied
+func (s *guestServiceImpl) Patch(ctx context.Context, guest *models.Guest, fields []string) error {
+	if err := s.authorizeGuest(ctx, guest.ID, false, false); err != nil {
+		return err
+	}
+	stored, err := s.repo.GetByID(guest.ID)
+	if err != nil {
+		return err
+	}
+	keepHiddenGuestFields(ctx, guest, &stored)
+	if stampConsent(guest, &stored) {
+		fields = append(fields, "ConsentUpdatedAt")
+	}
+	if problems := append(ValidateGuest(guest), checkGuestDocument(guest, &stored)...); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if len(fields) == 0 {
+		redactGuest(ctx, guest)
+		return nil
+	}
+	if err := s.repo.Patch(ctx, guest, fields); err != nil {
+		return err
+	}
+	redactGuest(ctx, guest)
+	return nil
 }
 
-func NewGuestService(repo repositories.GuestRepository) GuestService {
-	return &guestServiceImpl{repo: repo}
+// Delete takes the guest's bookings to the trash as well, so the caller needs every hotel they are at
+func (s *guestServiceImpl) Delete(ctx context.Context, id uint, cascade bool) error {
+	if err := s.authorizeGuest(ctx, id, false, true); err != nil {
+		return err
+	}
+	reason := "the guest record was removed"
+	cancelled, err := s.repo.Delete(ctx, id, deleteOptions(cascade, reason))
+	if err != nil {
+		return err
+	}
+	notifyCancelled(ctx, s.notifier, cancelled, reason)
+	return nil
+}
+
+// GetDeleted lists the guests in the trash
+func (s *guestServiceImpl) GetDeleted(ctx context.Context) ([]models.Guest, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	guests, err := s.repo.GetDeleted()
+	for i := range guests {
+		redactGuest(ctx, &guests[i])
+	}
+	return guests, err
+}
+
+func (s *guestServiceImpl) Restore(ctx context.Context, id uint) error {
+	if err := s.authorizeGuest(ctx, id, true, false); err != nil {
+		return err
+	}
+	return s.repo.Restore(ctx, id)
+}
+
+// Purge is permanent, so only admins may do it
+func (s *guestServiceImpl) Purge(ctx context.Context, id uint) error {
+	if err := authorize(ctx, actionPurge); err != nil {
+		return err
+	}
+	return s.repo.Purge(ctx, id)
+}
+
+// authorizeGuest checks a write against the hotels the guest has bookings at: with every set the caller
+// needs all of them, otherwise one is enough. A guest without bookings belongs to no hotel yet.
+func (s *guestServiceImpl) authorizeGuest(ctx context.Context, id uint, includeDeleted, every bool) error {
+	if err := authorize(ctx, actionWrite); err != nil {
+		return err
+	}
+	if _, all := hotelScope(ctx); all {
+		return nil
+	}
+	hotelIDs, err := s.repo.HotelIDs(id, includeDeleted)
+	if err != nil || len(hotelIDs) == 0 {
+		return err
+	}
+	for _, hotelID := range hotelIDs {
+		allowed := canAccessHotel(ctx, hotelID)
+		if allowed && !every {
+			return nil
+		}
+		if !allowed && every {
+			return ErrForbidden
+		}
+	}
+	if every {
+		return nil
+	}
+	return ErrForbidden
+}
+
+const (
+	maxGuestAddress        = 500
+	maxDocumentNumber      = 50
+	visibleDocumentSuffix  = 4
+	oldestGuestDateOfBirth = 1900
+)
+
+var documentTypes = map[string]bool{
+	models.DocumentTypePassport:        true,
+	models.DocumentTypeIDCard:          true,
+	models.DocumentTypeDrivingLicence:  true,
+	models.DocumentTypeResidencePermit: true,
+}
+
+// ValidateGuest returns a list of problems with the guest, empty if it is valid
+func ValidateGuest(guest *models.Guest) []string {
+	var problems []string
+	if strings.TrimSpace(guest.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if strings.TrimSpace(guest.MobileNumber) == "" {
+		problems = append(problems, "mobile_number is required")
+	} else if strings.Trim(guest.MobileNumber, "+0123456789") != "" {
+		problems = append(problems, "mobile_number may contain only digits and a leading +")
+	}
+	if guest.Email != "" {
+		if address, err := mail.ParseAddress(guest.Email); err != nil || address.Address != guest.Email || len(guest.Email) > 254 {
+			problems = append(problems, "email must be a plain e-mail address")
+		}
+	}
+	if !guest.DateOfBirth.IsZero() {
+		if guest.DateOfBirth.Year() < oldestGuestDateOfBirth || guest.DateOfBirth.After(time.Now()) {
+			problems = append(problems, "date_of_birth must be a past date")
+		}
+	}
+	if guest.Nationality != "" && (len(guest.Nationality) != 2 || strings.Trim(guest.Nationality, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		problems = append(problems, "nationality must be an ISO 3166-1 alpha-2 code such as UA")
+	}
+	if len(guest.Address) > maxGuestAddress {
+		problems = append(problems, fmt.Sprintf("address can't be longer than %d characters", maxGuestAddress))
+	}
+
+	hasDocument := guest.DocumentType != "" || guest.DocumentNumber != "" || !guest.DocumentExpiry.IsZero()
+	if hasDocument && !documentTypes[guest.DocumentType] {
+		problems = append(problems, "document_type must be passport, id_card, driving_licence or residence_permit")
+	}
+	if hasDocument && strings.TrimSpace(guest.DocumentNumber) == "" {
+		problems = append(problems, "document_number is required with a document")
+	} else if len(guest.DocumentNumber) > maxDocumentNumber {
+		problems = append(problems, fmt.Sprintf("document_number can't be longer than %d characters", maxDocumentNumber))
+	} else if strings.Trim(strings.ToUpper(guest.DocumentNumber), "ABCDEFGHIJKLMNOPQRSTUVWXYZАБВГҐДЕЄЖЗИІЇЙКЛМНОПРСТУФХЦЧШЩЬЮЯ0123456789 -") != "" {
+		problems = append(problems, "document_number may contain only letters, digits, spaces and hyphens")
+	}
+	return problems
 }
 
-func (s *guestServiceImpl) GetAll() ([]models.Guest, error) {
-	return s.repo.GetAll()
+// checkGuestDocument refuses an expired document when it is recorded or changed. A stored document
+// that expires later doesn't keep the guest's other details from being edited.
+func checkGuestDocument(guest, stored *models.Guest) []string {
+	if guest.DocumentExpiry.IsZero() {
+		return nil
+	}
+	if stored != nil && guest.DocumentType == stored.DocumentType && guest.DocumentNumber == stored.DocumentNumber &&
+		guest.DocumentExpiry.Equal(stored.DocumentExpiry.Time) {
+		return nil
+	}
+	if guest.DocumentExpiry.Before(models.DateOf(time.Now().UTC()).Time) {
+		return []string{fmt.Sprintf("document_expiry %s has passed; the document is no longer valid", guest.DocumentExpiry)}
+	}
+	return nil
 }
 
-func (s *guestServiceImpl) GetByID(id uint) (models.Guest, error) {
-	return s.repo.GetByID(id)
+// stampConsent records when a marketing consent was given or withdrawn; it reports whether it did
+func stampConsent(guest, stored *models.Guest) bool {
+	guest.ConsentUpdatedAt = stored.ConsentUpdatedAt
+	if guest.MarketingEmailConsent == stored.MarketingEmailConsent && guest.MarketingSMSConsent == stored.MarketingSMSConsent {
+		return false
+	}
+	now := time.Now()
+	guest.ConsentUpdatedAt = &now
+	return true
 }
 
-func (s *guestServiceImpl) Create(guest *models.Guest) error {
-	return s.repo.Create(guest)
+// redactGuest hides what the caller's role may not see: without actionViewGuestDetails the identity
+// details are left out, without actionViewGuestDocuments only the end of the document number is shown
+func redactGuest(ctx context.Context, guest *models.Guest) {
+	if authorize(ctx, actionViewGuestDetails) != nil {
+		guest.DateOfBirth = models.Date{}
+		guest.Nationality = ""
+		guest.Address = ""
+		guest.DocumentType = ""
+		guest.DocumentNumber = ""
+		guest.DocumentExpiry = models.Date{}
+		return
+	}
+	if authorize(ctx, actionViewGuestDocuments) != nil {
+		guest.DocumentNumber = maskDocumentNumber(guest.DocumentNumber)
+	}
 }
 
-func (s *guestServiceImpl) Update(guest *models.Guest) error {
-	return s.repo.Update(guest)
+func maskDocumentNumber(number string) string {
+	runes := []rune(number)
+	hidden := max(len(runes)-visibleDocumentSuffix, len(runes)/2)
+	return strings.Repeat("*", hidden) + string(runes[hidden:])
 }
 
-func (s *guestServiceImpl) Delete(id uint) error {
-	return s.repo.Delete(id)
+// keepHiddenGuestFields puts back the stored values of the fields the caller saw redacted and sent back
+// unchanged or empty, so that saving a guest neither overwrites a document number with its mask nor wipes it
+func keepHiddenGuestFields(ctx context.Context, guest, stored *models.Guest) {
+	shown := *stored
+	redactGuest(ctx, &shown)
+	if guest.DateOfBirth.Equal(shown.DateOfBirth.Time) {
+		guest.DateOfBirth = stored.DateOfBirth
+	}
+	if guest.Nationality == shown.Nationality {
+		guest.Nationality = stored.Nationality
+	}
+	if guest.Address == shown.Address {
+		guest.Address = stored.Address
+	}
+	if guest.DocumentType == shown.DocumentType {
+		guest.DocumentType = stored.DocumentType
+	}
+	// Замаскований номер не можна стерти, лише замінити новим
+	masked := shown.DocumentNumber != stored.DocumentNumber
+	if guest.DocumentNumber == shown.DocumentNumber || masked && guest.DocumentNumber == "" {
+		guest.DocumentNumber = stored.DocumentNumber
+	}
+	if guest.DocumentExpiry.Equal(shown.DocumentExpiry.Time) {
+		guest.DocumentExpiry = stored.DocumentExpiry
+	}
 }
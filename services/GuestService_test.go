@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mod/models"
+	"go.mod/security"
+)
+
+func receptionistAt(hotelIDs ...uint) context.Context {
+	return security.WithIdentity(context.Background(), security.Identity{
+		Method: security.AuthMethodAPIKey, Name: "front-desk", Roles: []string{models.RoleReceptionist}, HotelIDs: hotelIDs,
+	})
+}
+
+// guestScopeFixture has guest 1 booked at hotels 1 and 2, guest 2 only at hotel 2, guest 3 nowhere,
+// and guest 4 in the trash with a booking at hotel 2
+func guestScopeFixture() (GuestService, *fakeGuestRepository) {
+	var guests []models.Guest
+	for id := uint(1); id <= 3; id++ {
+		guest := models.Guest{Name: "Olena Kovalenko", MobileNumber: "+380501234567"}
+		guest.ID = id
+		guests = append(guests, guest)
+	}
+	repo := newFakeGuestRepository(guests...)
+	repo.hotels[1] = []uint{1, 2}
+	repo.hotels[2] = []uint{2}
+	repo.deletedHotels[4] = []uint{2}
+	return NewGuestService(repo, LogNotifier{}), repo
+}
+
+func TestGuestWritesNeedOneOfTheirHotels(t *testing.T) {
+	service, _ := guestScopeFixture()
+	ctx := receptionistAt(1)
+	tests := []struct {
+		name    string
+		guestID uint
+		allowed bool
+	}{
+		{"booked at the caller's hotel and another", 1, true},
+		{"booked only elsewhere", 2, false},
+		{"without bookings", 3, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			guest, err := service.GetByID(ctx, tc.guestID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			guest.Address = "вул. Хрещатик, 1"
+			err = service.Update(ctx, &guest)
+			if tc.allowed != (err == nil) || (err != nil && !errors.Is(err, ErrForbidden)) {
+				t.Errorf("Update gave %v, allowed = %v", err, tc.allowed)
+			}
+			err = service.Patch(ctx, &guest, []string{"Address"})
+			if tc.allowed != (err == nil) || (err != nil && !errors.Is(err, ErrForbidden)) {
+				t.Errorf("Patch gave %v, allowed = %v", err, tc.allowed)
+			}
+		})
+	}
+}
+
+func TestGuestDeleteNeedsEveryHotel(t *testing.T) {
+	service, repo := guestScopeFixture()
+
+	for _, cascade := range []bool{false, true} {
+		if err := service.Delete(receptionistAt(1), 1, cascade); !errors.Is(err, ErrForbidden) {
+			t.Errorf("delete (cascade %v) of a guest also booked at hotel 2 gave %v, want ErrForbidden", cascade, err)
+		}
+	}
+	if len(repo.deleted) != 0 {
+		t.Fatalf("refused deletes reached the repository: %v", repo.deleted)
+	}
+	if err := service.Delete(receptionistAt(1, 2), 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Delete(SystemContext(context.Background()), 2, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.deleted) != 2 {
+		t.Errorf("deleted = %v, want guests 1 and 2", repo.deleted)
+	}
+}
+
+func TestGuestRestoreLooksAtTrashedBookings(t *testing.T) {
+	service, repo := guestScopeFixture()
+
+	if err := service.Restore(receptionistAt(1), 4); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("restore of a guest booked at hotel 2 gave %v, want ErrForbidden", err)
+	}
+	if err := service.Restore(receptionistAt(2), 4); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.restored) != 1 {
+		t.Errorf("restored = %v, want guest 4", repo.restored)
+	}
+}
+
+func actingAs(role string) context.Context {
+	return security.WithIdentity(context.Background(), security.Identity{
+		Method: security.AuthMethodAPIKey, Name: role, Roles: []string{role}, HotelIDs: []uint{1},
+	})
+}
+
+// documentedGuest has every detail the roles see differently filled in
+func documentedGuest() models.Guest {
+	guest := models.Guest{
+		Name:           "Olena Kovalenko",
+		MobileNumber:   "+380501234567",
+		DateOfBirth:    models.NewDate(1990, 5, 17),
+		Nationality:    "UA",
+		Address:        "вул. Хрещатик, 1, Київ",
+		DocumentType:   models.DocumentTypePassport,
+		DocumentNumber: "FE123456",
+		DocumentExpiry: models.NewDate(2031, 1, 1),
+	}
+	guest.ID = 1
+	return guest
+}
+
+func TestGuestReadsHideWhatTheRoleMayNotSee(t *testing.T) {
+	stored := documentedGuest()
+	tests := []struct {
+		role           string
+		wantDetails    bool
+		wantDocumentNo string
+	}{
+		{models.RoleAdmin, true, "FE123456"},
+		{models.RoleManager, true, "FE123456"},
+		{models.RoleReceptionist, true, "****3456"},
+		{models.RoleReadOnly, false, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.role, func(t *testing.T) {
+			ctx := actingAs(tc.role)
+			guests := NewGuestService(newFakeGuestRepository(stored), LogNotifier{})
+			booking := models.Booking{HotelID: 1, Guest: stored}
+			booking.ID = 10
+			bookings := NewBookingService(newFakeBookingRepository(booking), &fakePolicyRepository{}, &fakeInvoiceService{})
+
+			got, err := guests.GetByID(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			all, err := guests.GetAll(ctx, false)
+			if err != nil || len(all) != 1 {
+				t.Fatalf("GetAll = %v, %v", all, err)
+			}
+			fromBooking, err := bookings.GetByID(ctx, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for source, guest := range map[string]models.Guest{"GetByID": got, "GetAll": all[0], "booking": fromBooking.Guest} {
+				if guest.DocumentNumber != tc.wantDocumentNo {
+					t.Errorf("%s shows document number %q, want %q", source, guest.DocumentNumber, tc.wantDocumentNo)
+				}
+				details := !guest.DateOfBirth.IsZero() && guest.Nationality != "" && guest.Address != "" &&
+					guest.DocumentType != "" && !guest.DocumentExpiry.IsZero()
+				hidden := guest.DateOfBirth.IsZero() && guest.Nationality == "" && guest.Address == "" &&
+					guest.DocumentType == "" && guest.DocumentExpiry.IsZero()
+				if tc.wantDetails && !details || !tc.wantDetails && !hidden {
+					t.Errorf("%s shows %+v, want the details shown = %v", source, guest, tc.wantDetails)
+				}
+				if guest.Name != stored.Name || guest.MobileNumber != stored.MobileNumber {
+					t.Errorf("%s hides the name or mobile number: %+v", source, guest)
+				}
+			}
+		})
+	}
+}
+
+func TestGuestWritesKeepWhatTheRoleMayNotSee(t *testing.T) {
+	ctx := actingAs(models.RoleReceptionist)
+	tests := []struct {
+		name   string
+		change func(guest *models.Guest) []string
+		// wantNumber is the stored document number after the write
+		wantNumber string
+	}{
+		{"PUT the guest as read", func(guest *models.Guest) []string {
+			guest.Address = "вул. Січових Стрільців, 5, Львів"
+			return nil
+		}, "FE123456"},
+		{"PUT without the document number", func(guest *models.Guest) []string {
+			guest.DocumentNumber = ""
+			return nil
+		}, "FE123456"},
+		{"PUT a new document number", func(guest *models.Guest) []string {
+			guest.DocumentNumber = "FK654321"
+			return nil
+		}, "FK654321"},
+		{"PATCH another field", func(guest *models.Guest) []string {
+			guest.Address = "вул. Січових Стрільців, 5, Львів"
+			return []string{"Address"}
+		}, "FE123456"},
+		{"PATCH the document number away", func(guest *models.Guest) []string {
+			guest.DocumentNumber = ""
+			return []string{"DocumentNumber"}
+		}, "FE123456"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newFakeGuestRepository(documentedGuest())
+			service := NewGuestService(repo, LogNotifier{})
+			guest, err := service.GetByID(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields := tc.change(&guest)
+			if fields == nil {
+				err = service.Update(ctx, &guest)
+			} else {
+				err = service.Patch(ctx, &guest, fields)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored := repo.guests[1]
+			if stored.DocumentNumber != tc.wantNumber {
+				t.Errorf("stored document number = %q, want %q", stored.DocumentNumber, tc.wantNumber)
+			}
+			want := documentedGuest()
+			if stored.DocumentType != want.DocumentType || !stored.DocumentExpiry.Equal(want.DocumentExpiry.Time) ||
+				!stored.DateOfBirth.Equal(want.DateOfBirth.Time) || stored.Nationality != want.Nationality {
+				t.Errorf("stored guest = %+v, want its document and details kept", stored)
+			}
+			if guest.DocumentNumber == stored.DocumentNumber {
+				t.Errorf("the write returned the unmasked document number %q", guest.DocumentNumber)
+			}
+		})
+	}
+}
@@ -1,42 +1,298 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
 	"go.mod/models"
 	"go.mod/repositories"
 )
 
 type HotelService interface {
-	GetAll() ([]models.Hotel, error)
-	GetByID(id uint) (models.Hotel, error)
-	Create(hotel *models.Hotel) error
-	Update(hotel *models.Hotel) error
-	Delete(id uint) error
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.Hotel, error)
+	Stream(ctx context.Context, includeDeleted bool, fn func(hotel *models.Hotel) error) error
+	GetByID(ctx context.Context, id uint) (models.Hotel, error)
+	Create(ctx context.Context, hotel *models.Hotel) error
+	Update(ctx context.Context, hotel *models.Hotel) error
+	Patch(ctx context.Context, hotel *models.Hotel, fields []string) error
+	Delete(ctx context.Context, id uint, cascade bool) error
+	GetDeleted(ctx context.Context) ([]models.Hotel, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
+	Near(ctx context.Context, query GeoQuery, includeDeleted bool) ([]HotelDistance, error)
+}
+
+const (
+	defaultCheckInTime  = "15:00"
+	defaultCheckOutTime = "11:00"
+	defaultRadiusKm     = 25
+	// maxRadiusKm is half the Earth's circumference, which reaches every point on it
+	maxRadiusKm   = 20038
+	earthRadiusKm = 6371.0
+)
+
+// GeoQuery looks for hotels within RadiusKm of a point
+type GeoQuery struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+}
+
+// HotelDistance is a hotel found by a geo search with its distance from the searched point
+type HotelDistance struct {
+	models.Hotel
+	DistanceKm float64 `json:"distance_km"`
 }
 
 type hotelServiceImpl struct {
-	repo repositories.HotelRepository
+	repo     repositories.HotelRepository
+	policies repositories.CancellationPolicyRepository
+	notifier Notifier
 }
 
-func NewHotelService(repo repositories.HotelRepository) HotelService {
-	return &hotelServiceImpl{repo: repo}
+func NewHotelService(repo repositories.HotelRepository, policies repositories.CancellationPolicyRepository, notifier Notifier) HotelService {
+	return &hotelServiceImpl{repo: repo, policies: policies, notifier: notifier}
 }
 
-func (s *hotelServiceImpl) GetAll() ([]models.Hotel, error) {
-	return s.repo.GetAll()
+func (s *hotelServiceImpl) GetAll(ctx context.Context, includeDeleted bool) ([]models.Hotel, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	hotels, err := s.repo.GetAll(includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return filterByHotel(ctx, hotels, func(hotel *models.Hotel) uint { return hotel.ID }), nil
 }
 
-func (s *hotelServiceImpl) GetByID(id uint) (models.Hotel, error) {
+func (s *hotelServiceImpl) Stream(ctx context.Context, includeDeleted bool, fn func(hotel *models.Hotel) error) error {
+	if err := authorize(ctx, actionRead); err != nil {
+		return err
+	}
+	return s.repo.Stream(includeDeleted, func(hotel *models.Hotel) error {
+		if !canAccessHotel(ctx, hotel.ID) {
+			return nil
+		}
+		return fn(hotel)
+	})
+}
+
+func (s *hotelServiceImpl) GetByID(ctx context.Context, id uint) (models.Hotel, error) {
+	if err := authorizeHotel(ctx, actionRead, id); err != nil {
+		return models.Hotel{}, err
+	}
 	return s.repo.GetByID(id)
 }
 
-func (s *hotelServiceImpl) Create(hotel *models.Hotel) error {
-	return s.repo.Create(hotel)
+func (s *hotelServiceImpl) Create(ctx context.Context, hotel *models.Hotel) error {
+	if err := authorize(ctx, actionManageHotels); err != nil {
+		return err
+	}
+	// Політики створюються для вже існуючого готелю
+	if hotel.CancellationPolicyID != nil {
+		return &ValidationError{Problems: []string{"a new hotel can't have a cancellation policy yet"}}
+	}
+	if hotel.CheckInTime == "" {
+		hotel.CheckInTime = defaultCheckInTime
+	}
+	if hotel.CheckOutTime == "" {
+		hotel.CheckOutTime = defaultCheckOutTime
+	}
+	if problems := ValidateHotel(hotel); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return s.repo.Create(ctx, hotel)
+}
+
+func (s *hotelServiceImpl) Update(ctx context.Context, hotel *models.Hotel) error {
+	if err := authorizeHotel(ctx, actionManageHotels, hotel.ID); err != nil {
+		return err
+	}
+	if hotel.CheckInTime == "" {
+		hotel.CheckInTime = defaultCheckInTime
+	}
+	if hotel.CheckOutTime == "" {
+		hotel.CheckOutTime = defaultCheckOutTime
+	}
+	if problems := ValidateHotel(hotel); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if err := checkPolicy(s.policies, hotel.CancellationPolicyID, hotel.ID); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, hotel)
+}
+
+func (s *hotelServiceImpl) Patch(ctx context.Context, hotel *models.Hotel, fields []string) error {
+	if err := authorizeHotel(ctx, actionManageHotels, hotel.ID); err != nil {
+		return err
+	}
+	if problems := ValidateHotel(hotel); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	if err := checkPolicy(s.policies, hotel.CancellationPolicyID, hotel.ID); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return s.repo.Patch(ctx, hotel, fields)
+}
+
+func (s *hotelServiceImpl) Delete(ctx context.Context, id uint, cascade bool) error {
+	if err := authorizeHotel(ctx, actionManageHotels, id); err != nil {
+		return err
+	}
+	reason := "the hotel was removed"
+	cancelled, err := s.repo.Delete(ctx, id, deleteOptions(cascade, reason))
+	if err != nil {
+		return err
+	}
+	notifyCancelled(ctx, s.notifier, cancelled, reason)
+	return nil
+}
+
+// GetDeleted lists the hotels in the trash that the caller may see
+func (s *hotelServiceImpl) GetDeleted(ctx context.Context) ([]models.Hotel, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	hotels, err := s.repo.GetDeleted()
+	if err != nil {
+		return nil, err
+	}
+	return filterByHotel(ctx, hotels, func(hotel *models.Hotel) uint { return hotel.ID }), nil
+}
+
+func (s *hotelServiceImpl) Restore(ctx context.Context, id uint) error {
+	hotel, err := s.repo.GetDeletedByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionManageHotels, hotel.ID); err != nil {
+		return err
+	}
+	return s.repo.Restore(ctx, id)
+}
+
+// Purge is permanent, so only admins may do it
+func (s *hotelServiceImpl) Purge(ctx context.Context, id uint) error {
+	if err := authorize(ctx, actionPurge); err != nil {
+		return err
+	}
+	return s.repo.Purge(ctx, id)
+}
+
+// Near returns the hotels within the radius that the caller may see, nearest first.
+// Hotels without coordinates are never found.
+func (s *hotelServiceImpl) Near(ctx context.Context, query GeoQuery, includeDeleted bool) ([]HotelDistance, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	if query.RadiusKm == 0 {
+		query.RadiusKm = defaultRadiusKm
+	}
+	var problems []string
+	problems = append(problems, validateCoordinates(&query.Latitude, &query.Longitude)...)
+	if !(query.RadiusKm > 0 && query.RadiusKm <= maxRadiusKm) {
+		problems = append(problems, fmt.Sprintf("radius_km must be above 0 and at most %d", maxRadiusKm))
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	hotels, err := s.repo.GetNear(query.Latitude, query.Longitude, query.RadiusKm, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	hotels = filterByHotel(ctx, hotels, func(hotel *models.Hotel) uint { return hotel.ID })
+
+	found := make([]HotelDistance, 0, len(hotels))
+	for _, hotel := range hotels {
+		// Репозиторій відбирає готелі за прямокутником, точну відстань рахуємо тут
+		distance := distanceKm(query.Latitude, query.Longitude, *hotel.Latitude, *hotel.Longitude)
+		if distance <= query.RadiusKm {
+			found = append(found, HotelDistance{Hotel: hotel, DistanceKm: math.Round(distance*100) / 100})
+		}
+	}
+	sort.SliceStable(found, func(a, b int) bool { return found[a].DistanceKm < found[b].DistanceKm })
+	return found, nil
+}
+
+// distanceKm is the great-circle distance between two points by the haversine formula
+func distanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLng := toRad(lat2-lat1), toRad(lng2-lng1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// isTimeOfDay accepts 24-hour times written as HH:MM
+func isTimeOfDay(value string) bool {
+	_, err := time.Parse("15:04", value)
+	return err == nil && len(value) == 5
 }
 
-func (s *hotelServiceImpl) Update(hotel *models.Hotel) error {
-	return s.repo.Update(hotel)
+func validateCoordinates(latitude, longitude *float64) []string {
+	var problems []string
+	if (latitude == nil) != (longitude == nil) {
+		return []string{"latitude and longitude must be set together"}
+	}
+	if latitude != nil && !(*latitude >= -90 && *latitude <= 90) {
+		problems = append(problems, "latitude must be between -90 and 90")
+	}
+	if longitude != nil && !(*longitude >= -180 && *longitude <= 180) {
+		problems = append(problems, "longitude must be between -180 and 180")
+	}
+	return problems
 }
 
-func (s *hotelServiceImpl) Delete(id uint) error {
-	return s.repo.Delete(id)
+// ValidateHotel returns a list of problems with the hotel, empty if it is valid
+func ValidateHotel(hotel *models.Hotel) []string {
+	var problems []string
+	if strings.TrimSpace(hotel.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if len(hotel.Address) > 500 {
+		problems = append(problems, "address can't be longer than 500 characters")
+	}
+	problems = append(problems, validateCoordinates(hotel.Latitude, hotel.Longitude)...)
+	if hotel.TimeZone != "" {
+		if _, err := time.LoadLocation(hotel.TimeZone); err != nil || hotel.TimeZone == "Local" {
+			problems = append(problems, fmt.Sprintf("time_zone %q is not an IANA time zone such as Europe/Kyiv", hotel.TimeZone))
+		}
+	}
+	if hotel.StarRating < 0 || hotel.StarRating > 5 {
+		problems = append(problems, "star_rating must be 1 to 5, or 0 for an unrated hotel")
+	}
+	if !isTimeOfDay(hotel.CheckInTime) {
+		problems = append(problems, "check_in_time must be a time of day such as 15:00")
+	}
+	if !isTimeOfDay(hotel.CheckOutTime) {
+		problems = append(problems, "check_out_time must be a time of day such as 11:00")
+	}
+	if len(hotel.Phone) > 30 {
+		problems = append(problems, "phone can't be longer than 30 characters")
+	}
+	if hotel.Email != "" {
+		if address, err := mail.ParseAddress(hotel.Email); err != nil || address.Address != hotel.Email {
+			problems = append(problems, "email must be a plain e-mail address")
+		}
+	}
+	if len(hotel.Website) > 255 {
+		problems = append(problems, "website can't be longer than 255 characters")
+	} else if hotel.Website != "" && !strings.HasPrefix(hotel.Website, "https://") && !strings.HasPrefix(hotel.Website, "http://") {
+		problems = append(problems, "website must be an http or https URL")
+	}
+	for i, amenity := range hotel.Amenities {
+		if strings.TrimSpace(amenity) == "" {
+			problems = append(problems, fmt.Sprintf("amenity #%d is empty", i+1))
+		}
+	}
+	return problems
 }
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"go.mod/models"
+)
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"Kyiv to Lviv", 50.4501, 30.5234, 49.8397, 24.0297, 468},
+		{"London to Paris", 51.5074, -0.1278, 48.8566, 2.3522, 344},
+		{"New York to Los Angeles", 40.7128, -74.0060, 34.0522, -118.2437, 3936},
+		{"Suva to Apia across the antimeridian", -18.1416, 178.4419, -13.8333, -171.75, 1152},
+		{"either side of the antimeridian", -17.7, 179.9, -17.7, -179.9, 21.2},
+		{"over the north pole", 89.9, 0, 89.9, 180, 22.2},
+		{"pole to pole", 90, 0, -90, 0, 20015},
+		{"antipodes on the equator", 0, 0, 0, 180, 20015},
+		{"same point", 48.8566, 2.3522, 48.8566, 2.3522, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := distanceKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > tt.want*0.005 {
+				t.Errorf("distance = %.2f km, want %g km within 0.5%%", got, tt.want)
+			}
+			if back := distanceKm(tt.lat2, tt.lng2, tt.lat1, tt.lng1); math.Abs(back-got) > 1e-9 {
+				t.Errorf("distance back = %.6f km, want %.6f", back, got)
+			}
+		})
+	}
+}
+
+func geoHotel(id uint, name string, latitude, longitude float64) models.Hotel {
+	hotel := models.Hotel{Name: name, Latitude: &latitude, Longitude: &longitude}
+	hotel.ID = id
+	return hotel
+}
+
+// nearFixture has hotels around Kyiv listed out of order, one far away in Lviv, and one without coordinates
+func nearFixture() HotelService {
+	repo := &fakeHotelRepository{hotels: []models.Hotel{
+		geoHotel(1, "Darnytsia Park Hotel", 50.4000, 30.7500),
+		geoHotel(2, "Lviv Old Town", 49.8397, 24.0297),
+		geoHotel(3, "Maidan Inn", 50.4501, 30.5234),
+		geoHotel(4, "Podil Riverside", 50.4650, 30.5150),
+		{Name: "Unmapped Lodge"},
+	}}
+	return NewHotelService(repo, nil, nil)
+}
+
+func foundNames(found []HotelDistance) []string {
+	var names []string
+	for _, hotel := range found {
+		names = append(names, hotel.Name)
+	}
+	return names
+}
+
+func TestNearSortsNearestFirst(t *testing.T) {
+	service := nearFixture()
+	ctx := SystemContext(context.Background())
+
+	found, err := service.Near(ctx, GeoQuery{Latitude: 50.4547, Longitude: 30.5238}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Maidan Inn", "Podil Riverside", "Darnytsia Park Hotel"}; !slices.Equal(foundNames(found), want) {
+		t.Errorf("found %q within the default radius, want %q", foundNames(found), want)
+	}
+	for i := 1; i < len(found); i++ {
+		if found[i].DistanceKm < found[i-1].DistanceKm {
+			t.Errorf("%s at %.2f km is listed after %s at %.2f km", found[i].Name, found[i].DistanceKm, found[i-1].Name, found[i-1].DistanceKm)
+		}
+	}
+
+	found, err = service.Near(ctx, GeoQuery{Latitude: 50.4547, Longitude: 30.5238, RadiusKm: 1000}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 4 || found[3].Name != "Lviv Old Town" {
+		t.Errorf("found %q within 1000 km, want Lviv last", foundNames(found))
+	}
+
+	found, err = service.Near(receptionistAt(1, 2), GeoQuery{Latitude: 50.4547, Longitude: 30.5238}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Darnytsia Park Hotel"}; !slices.Equal(foundNames(found), want) {
+		t.Errorf("a receptionist at hotels 1 and 2 found %q, want %q", foundNames(found), want)
+	}
+}
+
+func TestNearValidatesTheQuery(t *testing.T) {
+	service := nearFixture()
+	ctx := SystemContext(context.Background())
+
+	tests := []struct {
+		name  string
+		query GeoQuery
+		valid bool
+	}{
+		{"default radius", GeoQuery{Latitude: 50.45, Longitude: 30.52}, true},
+		{"largest radius", GeoQuery{Latitude: 50.45, Longitude: 30.52, RadiusKm: maxRadiusKm}, true},
+		{"negative radius", GeoQuery{Latitude: 50.45, Longitude: 30.52, RadiusKm: -5}, false},
+		{"radius beyond the antipode", GeoQuery{Latitude: 50.45, Longitude: 30.52, RadiusKm: maxRadiusKm + 1}, false},
+		{"radius not a number", GeoQuery{Latitude: 50.45, Longitude: 30.52, RadiusKm: math.NaN()}, false},
+		{"infinite radius", GeoQuery{Latitude: 50.45, Longitude: 30.52, RadiusKm: math.Inf(1)}, false},
+		{"latitude beyond the pole", GeoQuery{Latitude: 90.5, Longitude: 30.52}, false},
+		{"longitude beyond the antimeridian", GeoQuery{Latitude: 50.45, Longitude: -180.5}, false},
+		{"on the pole and the antimeridian", GeoQuery{Latitude: -90, Longitude: 180}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Near(ctx, tt.query, false)
+			var validation *ValidationError
+			if tt.valid && err != nil {
+				t.Errorf("err = %v, want none", err)
+			}
+			if !tt.valid && !errors.As(err, &validation) {
+				t.Errorf("err = %v, want a ValidationError", err)
+			}
+		})
+	}
+}
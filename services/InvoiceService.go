@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"gorm.io/gorm"
+)
+
+type InvoiceService interface {
+	Get(ctx context.Context, bookingID uint) (Folio, error)
+	Issue(ctx context.Context, bookingID uint) (folio Folio, created bool, err error)
+}
+
+// Folio is everything a booking was charged and paid, as shown on its invoice.
+// A draft is the folio as it stands now; it has no number and can still change.
+type Folio struct {
+	Number    uint        `json:"number,omitempty"`
+	Draft     bool        `json:"draft"`
+	IssuedAt  *time.Time  `json:"issued_at,omitempty"`
+	Hotel     FolioHotel  `json:"hotel"`
+	Guest     FolioGuest  `json:"guest"`
+	BookingID uint        `json:"booking_id"`
+	CheckIn   models.Date `json:"check_in"`
+	CheckOut  models.Date `json:"check_out"`
+	Nights    int         `json:"nights"`
+	Quote
+	Payments   []FolioPayment `json:"payments"`
+	Paid       float32        `json:"paid"`
+	BalanceDue float32        `json:"balance_due"`
+}
+
+type FolioHotel struct {
+	ID      uint   `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+type FolioGuest struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	MobileNumber string `json:"mobile_number"`
+}
+
+// FolioPayment is a payment or deposit; refunds have a negative amount
+type FolioPayment struct {
+	Date      time.Time `json:"date"`
+	Kind      string    `json:"kind"`
+	Method    string    `json:"method,omitempty"`
+	Reference string    `json:"reference,omitempty"`
+	Amount    float32   `json:"amount"`
+}
+
+type invoiceServiceImpl struct {
+	repo     repositories.InvoiceRepository
+	bookings repositories.BookingRepository
+	payments repositories.PaymentRepository
+}
+
+func NewInvoiceService(repo repositories.InvoiceRepository, bookings repositories.BookingRepository, payments repositories.PaymentRepository) InvoiceService {
+	return &invoiceServiceImpl{repo: repo, bookings: bookings, payments: payments}
+}
+
+// Get returns the booking's invoice as it was issued, or a draft of its folio while none is issued
+func (s *invoiceServiceImpl) Get(ctx context.Context, bookingID uint) (Folio, error) {
+	booking, err := s.bookings.GetByID(bookingID)
+	if err != nil {
+		return Folio{}, err
+	}
+	if err := authorizeHotel(ctx, actionRead, booking.HotelID); err != nil {
+		return Folio{}, err
+	}
+
+	invoice, err := s.repo.GetByBooking(bookingID)
+	if err == nil {
+		return decodeFolio(invoice)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Folio{}, err
+	}
+	ledger, err := s.payments.GetByBooking(bookingID)
+	if err != nil {
+		return Folio{}, err
+	}
+	folio := buildFolio(&booking, ledger)
+	folio.Draft = true
+	return folio, nil
+}
+
+// Issue numbers and freezes the folio of a checked-out, cancelled or no-show booking. A booking gets one invoice;
+// issuing it again returns the one already issued.
+func (s *invoiceServiceImpl) Issue(ctx context.Context, bookingID uint) (Folio, bool, error) {
+	booking, err := s.bookings.GetByID(bookingID)
+	if err != nil {
+		return Folio{}, false, err
+	}
+	if err := authorizeHotel(ctx, actionWrite, booking.HotelID); err != nil {
+		return Folio{}, false, err
+	}
+
+	invoice, created, err := s.repo.Issue(ctx, bookingID,
+		func(booking *models.Booking, ledger []models.Payment, number uint, issuedAt time.Time) (models.JSONDocument, error) {
+			switch booking.Status {
+			case models.BookingStatusCheckedOut, models.BookingStatusCancelled, models.BookingStatusNoShow:
+			default:
+				return nil, &repositories.ConflictError{
+					Message: fmt.Sprintf("booking %d is %s; invoices are issued at check-out, cancellation or no-show", bookingID, booking.Status),
+				}
+			}
+			folio := buildFolio(booking, ledger)
+			folio.Number = number
+			folio.IssuedAt = &issuedAt
+			return json.Marshal(folio)
+		})
+	if err != nil {
+		return Folio{}, false, err
+	}
+	folio, err := decodeFolio(invoice)
+	return folio, created, err
+}
+
+func decodeFolio(invoice models.Invoice) (Folio, error) {
+	var folio Folio
+	err := json.Unmarshal(invoice.Folio, &folio)
+	return folio, err
+}
+
+// buildFolio lists the room nights, or the cancellation fee, the extra charges and the taxes, then the money paid
+func buildFolio(booking *models.Booking, ledger []models.Payment) Folio {
+	folio := Folio{
+		Hotel:     FolioHotel{ID: booking.Hotel.ID, Name: booking.Hotel.Name, Address: booking.Hotel.Address},
+		Guest:     FolioGuest{ID: booking.Guest.ID, Name: booking.Guest.Name, MobileNumber: booking.Guest.MobileNumber},
+		BookingID: booking.ID,
+		CheckIn:   booking.CheckIn,
+		CheckOut:  booking.CheckOut,
+		Nights:    stayNights(booking),
+		Quote:     BookingQuote(booking, ledger),
+		Payments:  []FolioPayment{},
+	}
+
+	var paid float64
+	for _, entry := range ledger {
+		if !entry.Settled() {
+			continue
+		}
+		switch entry.Kind {
+		case models.PaymentKindPayment, models.PaymentKindDeposit, models.PaymentKindRefund:
+			amount := entry.Amount
+			if entry.Kind == models.PaymentKindRefund {
+				amount = -amount
+			}
+			paid += float64(amount)
+			folio.Payments = append(folio.Payments, FolioPayment{
+				Date:      entry.CreatedAt,
+				Kind:      entry.Kind,
+				Method:    entry.Method,
+				Reference: entry.Reference,
+				Amount:    amount,
+			})
+		}
+	}
+
+	folio.Paid = roundMoney(paid)
+	folio.BalanceDue = roundMoney(float64(folio.Total) - float64(folio.Paid))
+	return folio
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+// invoiceBooking is two nights at 120 for two adults, with 20% VAT included in the prices and a city tax
+// of 1.50 per person and night on top
+func invoiceBooking(id uint, status string) models.Booking {
+	booking := models.Booking{HotelID: 1, Status: status, Adults: 2,
+		CheckIn: models.NewDate(2026, 4, 10), CheckOut: models.NewDate(2026, 4, 12),
+		BookedRooms: []models.Room{{Number: "101", RoomType: "Double", Price: 120}}}
+	booking.ID = id
+	booking.Hotel.ID = 1
+	booking.Hotel.Name = "Carpathian Lodge"
+	booking.Hotel.TaxRules = []models.TaxRule{
+		{Name: "VAT", Kind: models.TaxKindPercent, Rate: 20, Inclusive: true},
+		{Name: "City tax", Kind: models.TaxKindPerPersonNight, Rate: 1.5},
+	}
+	return booking
+}
+
+// invoiceLedger has a settled entry of every kind, and a pending charge and a declined payment that must not count
+func invoiceLedger(bookingID uint) []models.Payment {
+	return []models.Payment{
+		{ID: 1, BookingID: bookingID, Kind: models.PaymentKindDeposit, Amount: 100, Method: models.PaymentMethodCash},
+		{ID: 2, BookingID: bookingID, Kind: models.PaymentKindCharge, Amount: 30, Note: "Minibar"},
+		{ID: 3, BookingID: bookingID, Kind: models.PaymentKindCharge, Amount: 50, Note: "Spa", Status: models.PaymentStatusPending},
+		{ID: 4, BookingID: bookingID, Kind: models.PaymentKindPayment, Amount: 500, Method: models.PaymentMethodCard, Status: models.PaymentStatusFailed},
+		{ID: 5, BookingID: bookingID, Kind: models.PaymentKindPayment, Amount: 200, Method: models.PaymentMethodCard, Reference: "ch_1"},
+		{ID: 6, BookingID: bookingID, Kind: models.PaymentKindRefund, Amount: 20, Method: models.PaymentMethodCard, RefundOf: refundOf(5)},
+	}
+}
+
+var (
+	pdfTextPattern   = regexp.MustCompile(`\(((?:[^()\\]|\\.)*)\) Tj`)
+	pdfEscapePattern = regexp.MustCompile(`\\(.)`)
+)
+
+// pdfTexts lists the strings the pages show, unescaped, in the order they were laid out
+func pdfTexts(pdf []byte) []string {
+	var texts []string
+	for _, match := range pdfTextPattern.FindAllSubmatch(pdf, -1) {
+		texts = append(texts, string(pdfEscapePattern.ReplaceAll(match[1], []byte("$1"))))
+	}
+	return texts
+}
+
+// pdfAmount returns the text laid out right after the label, i.e. the amount on the label's line
+func pdfAmount(t *testing.T, texts []string, label string) string {
+	t.Helper()
+	for i, text := range texts {
+		if text == label && i+1 < len(texts) {
+			return texts[i+1]
+		}
+	}
+	t.Fatalf("the PDF has no %q line", label)
+	return ""
+}
+
+func TestFolioAndPDFMatchTheLedger(t *testing.T) {
+	booking := invoiceBooking(10, models.BookingStatusCheckedOut)
+	ledger := invoiceLedger(10)
+	folio := buildFolio(&booking, ledger)
+	folio.Number = 7
+
+	// 240 за номер і 30 за мінібар; ПДВ уже в ціні, туристичний збір 1.5 × 2 × 2 зверху
+	if folio.Subtotal != 270 || folio.Total != 276 {
+		t.Errorf("subtotal %.2f, total %.2f; want 270 and 276", folio.Subtotal, folio.Total)
+	}
+	if len(folio.Payments) != 3 || folio.Paid != 280 || folio.BalanceDue != -4 {
+		t.Errorf("payments %+v, paid %.2f, balance due %.2f; want the deposit, the captured payment and the refund, 280 and -4",
+			folio.Payments, folio.Paid, folio.BalanceDue)
+	}
+	balance := BookingBalance(&booking, ledger)
+	if balance.Outstanding != folio.BalanceDue || balance.Paid-balance.Refunded != folio.Paid {
+		t.Errorf("folio (paid %.2f, due %.2f) disagrees with the ledger balance %+v", folio.Paid, folio.BalanceDue, balance)
+	}
+
+	texts := pdfTexts(RenderInvoicePDF(&folio))
+	want := map[string]string{
+		"Invoice 7":          "Carpathian Lodge",
+		"Subtotal":           "270.00",
+		"VAT 20% (included)": "45.00",
+		"City tax":           "6.00",
+		"Total":              "276.00",
+		"Paid":               "280.00",
+		"Balance due":        "-4.00",
+		"Room 101, Double":   "2",
+		"payment, card":      "200.00",
+		"refund, card":       "-20.00",
+		"deposit, cash":      "100.00",
+		"Minibar":            "1",
+	}
+	for label, amount := range want {
+		if got := pdfAmount(t, texts, label); got != amount {
+			t.Errorf("PDF shows %q next to %q, want %q", got, label, amount)
+		}
+	}
+	for _, text := range texts {
+		if text == "Spa" || text == "500.00" {
+			t.Errorf("PDF shows %q from an entry that isn't settled", text)
+		}
+	}
+}
+
+func TestIssueFreezesTheFolio(t *testing.T) {
+	bookings := newFakeBookingRepository(invoiceBooking(10, models.BookingStatusCheckedOut), invoiceBooking(11, models.BookingStatusCheckedIn),
+		invoiceBooking(12, models.BookingStatusCancelled))
+	payments := newFakePaymentRepository()
+	payments.ledgers[10] = invoiceLedger(10)
+	service := NewInvoiceService(newFakeInvoiceRepository(bookings, payments), bookings, payments)
+	ctx := SystemContext(context.Background())
+
+	var conflict *repositories.ConflictError
+	if _, _, err := service.Issue(ctx, 11); !errors.As(err, &conflict) {
+		t.Fatalf("issuing a checked-in booking: err = %v, want a conflict", err)
+	}
+	issued, created, err := service.Issue(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || issued.Draft || issued.Number != 1 || issued.Total != 276 {
+		t.Fatalf("issued %+v, created %t; want invoice 1 for 276", issued, created)
+	}
+
+	// Запис у рахунку після виставлення інвойсу не змінює його
+	payments.ledgers[10] = append(payments.ledgers[10], models.Payment{ID: 7, BookingID: 10, Kind: models.PaymentKindCharge, Amount: 15, Note: "Late check-out"})
+	got, err := service.Get(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Draft || got.Number != 1 || got.Total != issued.Total || len(got.Lines) != len(issued.Lines) {
+		t.Errorf("invoice read back = %+v, want the folio as issued", got)
+	}
+	again, created, err := service.Issue(ctx, 10)
+	if err != nil || created || again.Number != 1 || again.Total != issued.Total {
+		t.Errorf("issuing again = invoice %d for %.2f, created %t, %v; want invoice 1 as issued", again.Number, again.Total, created, err)
+	}
+
+	next, _, err := service.Issue(ctx, 12)
+	if err != nil || next.Number != 2 {
+		t.Errorf("next invoice = %d, %v; want 2, right after the refused issue", next.Number, err)
+	}
+}
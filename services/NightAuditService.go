@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+const (
+	// nightAuditTime is when, on each hotel's own clock, the previous business day is closed
+	nightAuditTime  = "03:00"
+	jobPollInterval = time.Minute
+)
+
+// NightAuditService runs the jobs that follow each hotel's own clock: marking the guests who didn't
+// arrive by the check-in cutoff as no-shows, and the night audit that closes the business day.
+type NightAuditService interface {
+	// Run does the due work once a minute until stop is closed
+	Run(stop <-chan struct{})
+	// RunDue does the work that is due at the instant now in every hotel
+	RunDue(ctx context.Context, now time.Time) error
+}
+
+// NightAuditReport sums up one business day of a hotel. Overstays are the bookings still checked in
+// although they were due to leave on the day.
+type NightAuditReport struct {
+	HotelID     uint
+	Date        models.Date
+	Arrivals    int
+	Departures  int
+	InHouse     int
+	RoomRevenue float32
+	Overstays   []uint
+}
+
+type nightAuditServiceImpl struct {
+	hotels   repositories.HotelRepository
+	bookings repositories.BookingRepository
+	policies repositories.CancellationPolicyRepository
+
+	mu sync.Mutex
+	// audited is the last business day audited per hotel; after a restart the last day is audited again
+	audited map[uint]models.Date
+}
+
+func NewNightAuditService(hotels repositories.HotelRepository, bookings repositories.BookingRepository,
+	policies repositories.CancellationPolicyRepository) NightAuditService {
+	return &nightAuditServiceImpl{hotels: hotels, bookings: bookings, policies: policies, audited: map[uint]models.Date{}}
+}
+
+func (s *nightAuditServiceImpl) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunDue(SystemContext(context.Background()), time.Now()); err != nil {
+			log.Printf("Hotel jobs failed: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue goes on with the other hotels when one fails and returns all the errors
+func (s *nightAuditServiceImpl) RunDue(ctx context.Context, now time.Time) error {
+	if err := authorize(ctx, actionManageHotels); err != nil {
+		return err
+	}
+	hotels, err := s.hotels.GetAllBrief()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range hotels {
+		hotel := &hotels[i]
+		if err := s.markNoShows(ctx, hotel, now); err != nil {
+			errs = append(errs, fmt.Errorf("hotel %d: no-shows: %w", hotel.ID, err))
+		}
+
+		today := hotelToday(hotel, now)
+		businessDay := models.Date{Time: today.AddDate(0, 0, -1)}
+		s.mu.Lock()
+		due := s.audited[hotel.ID] != businessDay && !now.Before(hotelInstant(hotel, today, nightAuditTime))
+		s.mu.Unlock()
+		if !due {
+			continue
+		}
+		report, err := s.audit(hotel, businessDay)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hotel %d: night audit: %w", hotel.ID, err))
+			continue
+		}
+		log.Printf("Night audit of hotel %d for %s: %d arrivals, %d departures, %d in house, room revenue %.2f, overstays %v",
+			report.HotelID, report.Date, report.Arrivals, report.Departures, report.InHouse, report.RoomRevenue, report.Overstays)
+		s.mu.Lock()
+		s.audited[hotel.ID] = businessDay
+		s.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// markNoShows closes the bookings whose check-in cutoff has passed and charges the penalty of their
+// cancellation policy, as for a cancellation on the day
+func (s *nightAuditServiceImpl) markNoShows(ctx context.Context, hotel *models.Hotel, now time.Time) error {
+	yesterday := models.Date{Time: hotelToday(hotel, now).AddDate(0, 0, -1)}
+	bookings, err := s.bookings.GetUnarrived(hotel.ID, yesterday)
+	if err != nil {
+		return err
+	}
+
+	for i := range bookings {
+		booking := &bookings[i]
+		if _, cutoff := checkInWindow(booking); now.Before(cutoff) {
+			continue
+		}
+		policy, err := bookingPolicy(s.policies, booking)
+		if err != nil {
+			return err
+		}
+		penalty := CancellationPenalty(policy, booking, hotelToday(hotel, now))
+		if err := s.bookings.NoShow(ctx, booking.ID, penalty); err != nil {
+			// Гість міг заселитися, поки ми рахували штраф
+			var conflict *repositories.ConflictError
+			if errors.As(err, &conflict) {
+				continue
+			}
+			return err
+		}
+		log.Printf("Booking %d at hotel %d marked as a no-show with a penalty of %.2f", booking.ID, hotel.ID, penalty)
+	}
+	return nil
+}
+
+// audit counts the business day's arrivals, departures and the rooms occupied for its night
+func (s *nightAuditServiceImpl) audit(hotel *models.Hotel, day models.Date) (NightAuditReport, error) {
+	report := NightAuditReport{HotelID: hotel.ID, Date: day, Overstays: []uint{}}
+	bookings, err := s.bookings.GetOnDate(hotel.ID, day)
+	if err != nil {
+		return report, err
+	}
+
+	var revenue float64
+	for i := range bookings {
+		booking := &bookings[i]
+		arrived := booking.Status == models.BookingStatusCheckedIn || booking.Status == models.BookingStatusCheckedOut
+		if arrived && booking.CheckIn.Equal(day.Time) {
+			report.Arrivals++
+		}
+		if booking.Status == models.BookingStatusCheckedOut && booking.CheckOut.Equal(day.Time) {
+			report.Departures++
+		}
+		if booking.Status != models.BookingStatusCheckedIn {
+			continue
+		}
+		if booking.CheckOut.After(day.Time) {
+			report.InHouse++
+			revenue += float64(nightlyRate(booking))
+		} else {
+			report.Overstays = append(report.Overstays, booking.ID)
+		}
+	}
+	report.RoomRevenue = roundMoney(revenue)
+	return report, nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"go.mod/models"
+)
+
+func nightAuditFixture() (*nightAuditServiceImpl, *fakeBookingRepository) {
+	policyID := uint(7)
+	hotel := models.Hotel{TimeZone: "Europe/Kyiv", CheckOutTime: "11:00", CancellationPolicyID: &policyID}
+	hotel.ID = 1
+	room := []models.Room{{Price: 100}}
+
+	unarrived := models.Booking{HotelID: 1, Hotel: hotel, Status: models.BookingStatusConfirmed, BookedRooms: room,
+		CheckIn: models.NewDate(2026, 3, 28), CheckOut: models.NewDate(2026, 3, 30)}
+	unarrived.ID = 10
+	arrivingToday := models.Booking{HotelID: 1, Hotel: hotel, Status: models.BookingStatusConfirmed, BookedRooms: room,
+		CheckIn: models.NewDate(2026, 3, 29), CheckOut: models.NewDate(2026, 3, 30)}
+	arrivingToday.ID = 11
+	bookings := newFakeBookingRepository(unarrived, arrivingToday)
+
+	policies := &fakePolicyRepository{policies: map[uint]models.CancellationPolicy{
+		7: {HotelID: 1, PenaltyType: models.PenaltyTypeFirstNight},
+	}}
+	service := NewNightAuditService(&fakeHotelRepository{hotels: []models.Hotel{hotel}}, bookings, policies)
+	return service.(*nightAuditServiceImpl), bookings
+}
+
+// On 29 March 2026 Kyiv skips 03:00-04:00, so the 03:00 night audit runs at 04:00 local time, 01:00 UTC,
+// and the no-show cutoff for the 28th is 11:00 local time, 08:00 UTC
+func TestRunDueOnSpringForwardDay(t *testing.T) {
+	service, bookings := nightAuditFixture()
+	ctx := SystemContext(context.Background())
+
+	if err := service.RunDue(ctx, utc("2026-03-29T00:59:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings.onDate) != 0 {
+		t.Fatalf("night audit ran at 03:59 local time on %v, before the clocks reached 03:00", bookings.onDate)
+	}
+
+	if err := service.RunDue(ctx, utc("2026-03-29T01:00:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings.onDate) != 1 || bookings.onDate[0] != models.NewDate(2026, 3, 28) {
+		t.Fatalf("night audit days = %v, want [2026-03-28]", bookings.onDate)
+	}
+
+	if err := service.RunDue(ctx, utc("2026-03-29T07:59:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings.onDate) != 1 {
+		t.Errorf("night audit ran %d times for one business day", len(bookings.onDate))
+	}
+	if status := bookings.bookings[10].Status; status != models.BookingStatusConfirmed {
+		t.Fatalf("booking 10 is %s a minute before the cutoff, want confirmed", status)
+	}
+
+	if err := service.RunDue(ctx, utc("2026-03-29T08:00:00Z")); err != nil {
+		t.Fatal(err)
+	}
+	if booking := bookings.bookings[10]; booking.Status != models.BookingStatusNoShow || booking.CancellationPenalty != 100 {
+		t.Errorf("booking 10 is %s with a penalty of %.2f, want no_show with the first night, 100", booking.Status, booking.CancellationPenalty)
+	}
+	if status := bookings.bookings[11].Status; status != models.BookingStatusConfirmed {
+		t.Errorf("booking 11 arriving today is %s, want confirmed", status)
+	}
+}
+
+func TestRunDueSkipsBookingsCheckedInMeanwhile(t *testing.T) {
+	service, bookings := nightAuditFixture()
+	// NoShow finds the booking already checked in and answers with a conflict
+	bookings.bookings[10].Status = models.BookingStatusCheckedIn
+	service.bookings = &checkInRace{fakeBookingRepository: bookings}
+
+	if err := service.RunDue(SystemContext(context.Background()), utc("2026-03-29T08:00:00Z")); err != nil {
+		t.Fatalf("RunDue() = %v, want the conflict skipped", err)
+	}
+	if status := bookings.bookings[10].Status; status != models.BookingStatusCheckedIn {
+		t.Errorf("booking 10 is %s, want checked_in", status)
+	}
+}
+
+// checkInRace lists a booking as unarrived although it has just been checked in
+type checkInRace struct {
+	*fakeBookingRepository
+}
+
+func (r *checkInRace) GetUnarrived(hotelID uint, arrivingBy models.Date) ([]models.Booking, error) {
+	return []models.Booking{*r.bookings[10]}, nil
+}
+
+func TestRunDueRequiresManager(t *testing.T) {
+	service, _ := nightAuditFixture()
+	if err := service.RunDue(context.Background(), utc("2026-03-29T08:00:00Z")); err != ErrForbidden {
+		t.Errorf("RunDue() without an identity = %v, want ErrForbidden", err)
+	}
+}
+
+func TestNightAuditReport(t *testing.T) {
+	day := models.NewDate(2026, 3, 28)
+	room := []models.Room{{Price: 80}}
+	booking := func(id uint, status string, checkIn, checkOut models.Date) models.Booking {
+		b := models.Booking{HotelID: 1, Status: status, CheckIn: checkIn, CheckOut: checkOut, BookedRooms: room}
+		b.ID = id
+		return b
+	}
+	bookings := newFakeBookingRepository(
+		booking(1, models.BookingStatusCheckedIn, day, models.NewDate(2026, 3, 30)),
+		booking(2, models.BookingStatusCheckedOut, models.NewDate(2026, 3, 26), day),
+		booking(3, models.BookingStatusCheckedIn, models.NewDate(2026, 3, 25), day),
+		booking(4, models.BookingStatusCancelled, day, models.NewDate(2026, 3, 29)),
+	)
+	service := &nightAuditServiceImpl{bookings: bookings}
+	hotel := &models.Hotel{}
+	hotel.ID = 1
+
+	report, err := service.audit(hotel, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Arrivals != 1 || report.Departures != 1 || report.InHouse != 1 || report.RoomRevenue != 80 {
+		t.Errorf("report = %+v, want 1 arrival, 1 departure, 1 in house and 80 revenue", report)
+	}
+	if len(report.Overstays) != 1 || report.Overstays[0] != 3 {
+		t.Errorf("overstays = %v, want [3]", report.Overstays)
+	}
+}
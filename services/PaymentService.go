@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+type PaymentService interface {
+	Ledger(ctx context.Context, bookingID uint) (Ledger, error)
+	Record(ctx context.Context, bookingID uint, input PaymentInput) (models.Payment, error)
+}
+
+// Ledger is a booking's ledger with its balance
+type Ledger struct {
+	Entries []models.Payment `json:"entries"`
+	Balance Balance          `json:"balance"`
+}
+
+// PaymentInput is a new ledger entry. Card payments and deposits are charged to CardToken;
+// a refund of a card payment names it in RefundOf and goes back to the same card.
+type PaymentInput struct {
+	Kind      string  `json:"kind"`
+	Amount    float32 `json:"amount"`
+	Method    string  `json:"method"`
+	CardToken string  `json:"card_token"`
+	RefundOf  *uint   `json:"refund_of"`
+	Note      string  `json:"note"`
+}
+
+type paymentServiceImpl struct {
+	repo     repositories.PaymentRepository
+	bookings repositories.BookingRepository
+	provider PaymentProvider
+}
+
+func NewPaymentService(repo repositories.PaymentRepository, bookings repositories.BookingRepository, provider PaymentProvider) PaymentService {
+	return &paymentServiceImpl{repo: repo, bookings: bookings, provider: provider}
+}
+
+func (s *paymentServiceImpl) Ledger(ctx context.Context, bookingID uint) (Ledger, error) {
+	booking, err := s.bookings.GetByID(bookingID)
+	if err != nil {
+		return Ledger{}, err
+	}
+	if err := authorizeHotel(ctx, actionRead, booking.HotelID); err != nil {
+		return Ledger{}, err
+	}
+	entries, err := s.repo.GetByBooking(bookingID)
+	if err != nil {
+		return Ledger{}, err
+	}
+	if entries == nil {
+		entries = []models.Payment{}
+	}
+	return Ledger{Entries: entries, Balance: BookingBalance(&booking, entries)}, nil
+}
+
+// Record adds an entry to the booking's ledger. A card entry is written as pending first and the money
+// moves through the provider only after that transaction, so the booking isn't locked while the provider
+// answers; the entry is then marked captured or failed. Refunds can't give back more than was paid,
+// in total or of the payment they name.
+func (s *paymentServiceImpl) Record(ctx context.Context, bookingID uint, input PaymentInput) (models.Payment, error) {
+	booking, err := s.bookings.GetByID(bookingID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if err := authorizeHotel(ctx, actionWrite, booking.HotelID); err != nil {
+		return models.Payment{}, err
+	}
+	input.Amount = roundMoney(float64(input.Amount))
+	input.Note = strings.TrimSpace(input.Note)
+	if problems := ValidatePaymentInput(&input); len(problems) > 0 {
+		return models.Payment{}, &ValidationError{Problems: problems}
+	}
+
+	// refundReference is the provider's transaction that a card refund goes back to
+	var refundReference string
+	payment, err := s.repo.Record(ctx, bookingID, func(ledger []models.Payment) (models.Payment, error) {
+		payment := models.Payment{
+			Kind:     input.Kind,
+			Amount:   input.Amount,
+			Method:   input.Method,
+			Status:   models.PaymentStatusCaptured,
+			RefundOf: input.RefundOf,
+			Note:     input.Note,
+		}
+
+		switch input.Kind {
+		case models.PaymentKindPayment, models.PaymentKindDeposit:
+			if input.Method == models.PaymentMethodCard {
+				payment.Status = models.PaymentStatusPending
+			}
+		case models.PaymentKindRefund:
+			original, err := checkRefund(ledger, &input)
+			if err != nil {
+				return models.Payment{}, err
+			}
+			if original != nil {
+				payment.Method = original.Method
+				if original.Method == models.PaymentMethodCard {
+					payment.Status = models.PaymentStatusPending
+					refundReference = original.Reference
+				}
+			}
+		}
+		return payment, nil
+	})
+	if err != nil || payment.Status != models.PaymentStatusPending {
+		return payment, err
+	}
+	return s.capture(ctx, payment, input.CardToken, refundReference)
+}
+
+// capture moves the money of a pending card entry and records the outcome. The outcome is saved even if
+// the request is cancelled meanwhile; if it still can't be saved after the card was charged, the charge
+// is refunded, so the guest never pays for an entry the ledger doesn't count.
+func (s *paymentServiceImpl) capture(ctx context.Context, payment models.Payment, cardToken, refundReference string) (models.Payment, error) {
+	var reference string
+	var err error
+	if payment.Kind == models.PaymentKindRefund {
+		reference, err = s.provider.Refund(ctx, refundReference, payment.Amount)
+	} else {
+		reference, err = s.provider.Charge(ctx, payment.Amount, cardToken)
+	}
+
+	saveCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		if failErr := s.repo.Complete(saveCtx, payment.ID, models.PaymentStatusFailed, ""); failErr != nil {
+			log.Printf("Booking %d: entry %d stays pending, it could not be marked failed: %v", payment.BookingID, payment.ID, failErr)
+		}
+		return models.Payment{}, err
+	}
+	// Гроші вже переказано; якщо запис не збережеться, транзакцію знайдуть у журналі
+	log.Printf("Booking %d: %s of %.2f processed by the payment provider as %s", payment.BookingID, payment.Kind, payment.Amount, reference)
+
+	if err := s.repo.Complete(saveCtx, payment.ID, models.PaymentStatusCaptured, reference); err != nil {
+		if payment.Kind != models.PaymentKindRefund {
+			if refund, refundErr := s.provider.Refund(saveCtx, reference, payment.Amount); refundErr != nil {
+				log.Printf("Booking %d: charge %s could not be recorded or refunded: %v", payment.BookingID, reference, refundErr)
+			} else {
+				log.Printf("Booking %d: charge %s could not be recorded and was refunded as %s", payment.BookingID, reference, refund)
+			}
+		}
+		return models.Payment{}, err
+	}
+	payment.Status = models.PaymentStatusCaptured
+	payment.Reference = reference
+	return payment, nil
+}
+
+// checkRefund compares the refund with what was paid and returns the entry it refunds, if it names one.
+// Pending and failed payments were never paid; a pending refund already counts as given back.
+func checkRefund(ledger []models.Payment, input *PaymentInput) (*models.Payment, error) {
+	var paid, refunded float64
+	var original *models.Payment
+	var refundedOfOriginal float64
+	for i, entry := range ledger {
+		// Повернення, яке ще обробляється, вже тримає свою суму
+		if entry.Status == models.PaymentStatusFailed || (entry.Status == models.PaymentStatusPending && entry.Kind != models.PaymentKindRefund) {
+			continue
+		}
+		switch entry.Kind {
+		case models.PaymentKindPayment, models.PaymentKindDeposit:
+			paid += float64(entry.Amount)
+			if input.RefundOf != nil && entry.ID == *input.RefundOf {
+				original = &ledger[i]
+			}
+		case models.PaymentKindRefund:
+			refunded += float64(entry.Amount)
+			if input.RefundOf != nil && entry.RefundOf != nil && *entry.RefundOf == *input.RefundOf {
+				refundedOfOriginal += float64(entry.Amount)
+			}
+		}
+	}
+
+	if input.RefundOf != nil && original == nil {
+		return nil, &ValidationError{Problems: []string{
+			fmt.Sprintf("refund_of must be a payment or deposit of this booking; %d isn't", *input.RefundOf),
+		}}
+	}
+	if left := roundMoney(paid - refunded); input.Amount > left {
+		return nil, &ValidationError{Problems: []string{fmt.Sprintf("only %.2f has been paid and can be refunded", left)}}
+	}
+	if original != nil {
+		if left := roundMoney(float64(original.Amount) - refundedOfOriginal); input.Amount > left {
+			return nil, &ValidationError{Problems: []string{
+				fmt.Sprintf("only %.2f of entry %d is left to refund", left, original.ID),
+			}}
+		}
+	}
+	return original, nil
+}
+
+// ValidatePaymentInput returns a list of problems with the entry, empty if it is valid
+func ValidatePaymentInput(input *PaymentInput) []string {
+	var problems []string
+	if input.Amount <= 0 || math.IsInf(float64(input.Amount), 0) || math.IsNaN(float64(input.Amount)) {
+		problems = append(problems, "amount must be a positive number")
+	}
+	if len(input.Note) > 500 {
+		problems = append(problems, "note can't be longer than 500 characters")
+	}
+
+	switch input.Kind {
+	case models.PaymentKindCharge:
+		if input.Method != "" || input.CardToken != "" || input.RefundOf != nil {
+			problems = append(problems, "a charge takes no method, card_token or refund_of")
+		}
+		if input.Note == "" {
+			problems = append(problems, "a charge needs a note saying what it is for")
+		}
+	case models.PaymentKindPayment, models.PaymentKindDeposit:
+		switch input.Method {
+		case models.PaymentMethodCash:
+			if input.CardToken != "" {
+				problems = append(problems, "card_token is only used with the card method")
+			}
+		case models.PaymentMethodCard:
+			if input.CardToken == "" {
+				problems = append(problems, "card_token is required for card payments")
+			}
+		default:
+			problems = append(problems, "method must be cash or card")
+		}
+		if input.RefundOf != nil {
+			problems = append(problems, "refund_of is only used with refunds")
+		}
+	case models.PaymentKindRefund:
+		if input.CardToken != "" {
+			problems = append(problems, "a refund goes back to the card of the payment in refund_of, not to card_token")
+		}
+		// Повернення на картку можливе лише для конкретного платежу
+		if input.RefundOf == nil && input.Method != models.PaymentMethodCash {
+			problems = append(problems, "a refund without refund_of must use the cash method")
+		}
+		if input.RefundOf != nil && input.Method != "" {
+			problems = append(problems, "a refund with refund_of takes the method of that payment")
+		}
+	default:
+		problems = append(problems, "kind must be charge, payment, deposit or refund")
+	}
+	return problems
+}
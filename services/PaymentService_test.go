@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"go.mod/security"
+)
+
+// paymentFixture is a checked-in booking of two nights at 100, so 200 is owed
+func paymentFixture() (PaymentService, *fakePaymentRepository, *fakeBookingRepository) {
+	booking := models.Booking{HotelID: 1, Status: models.BookingStatusCheckedIn, BookedRooms: []models.Room{{Price: 100}},
+		CheckIn: models.NewDate(2026, 5, 4), CheckOut: models.NewDate(2026, 5, 6)}
+	booking.ID = 10
+	booking.Hotel.ID = 1
+	bookings := newFakeBookingRepository(booking)
+	payments := newFakePaymentRepository()
+	bookings.payments = payments
+	return NewPaymentService(payments, bookings, NewFakePaymentProvider()), payments, bookings
+}
+
+func refundOf(id uint) *uint {
+	return &id
+}
+
+func outstanding(t *testing.T, service PaymentService, ctx context.Context) float32 {
+	t.Helper()
+	ledger, err := service.Ledger(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ledger.Balance.Outstanding
+}
+
+func TestRecordPaymentAndDeposit(t *testing.T) {
+	service, _, _ := paymentFixture()
+	ctx := SystemContext(context.Background())
+
+	deposit, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindDeposit, Amount: 50, Method: models.PaymentMethodCash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposit.Reference != "" {
+		t.Errorf("cash deposit has provider reference %q", deposit.Reference)
+	}
+	payment, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindPayment, Amount: 100.004,
+		Method: models.PaymentMethodCard, CardToken: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Reference == "" || payment.Amount != 100 {
+		t.Errorf("card payment = %+v, want a provider reference and the amount rounded to 100", payment)
+	}
+	if got := outstanding(t, service, ctx); got != 50 {
+		t.Errorf("outstanding = %.2f, want 50", got)
+	}
+}
+
+func TestRecordDeclinedCard(t *testing.T) {
+	service, payments, _ := paymentFixture()
+	ctx := SystemContext(context.Background())
+
+	_, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindPayment, Amount: 200,
+		Method: models.PaymentMethodCard, CardToken: FakeDeclinedCard})
+	if !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("err = %v, want ErrPaymentDeclined", err)
+	}
+	if ledger := payments.ledgers[10]; len(ledger) != 1 || ledger[0].Status != models.PaymentStatusFailed || ledger[0].Reference != "" {
+		t.Errorf("ledger after a declined card = %+v, want one failed entry", ledger)
+	}
+	if got := outstanding(t, service, ctx); got != 200 {
+		t.Errorf("outstanding = %.2f, want 200", got)
+	}
+}
+
+// recordingProvider notes the status of the booking's newest ledger entry whenever money moves
+type recordingProvider struct {
+	*FakePaymentProvider
+	payments *fakePaymentRepository
+	statuses []string
+	refunds  []string
+}
+
+func (p *recordingProvider) note() {
+	ledger := p.payments.ledgers[10]
+	p.statuses = append(p.statuses, ledger[len(ledger)-1].Status)
+}
+
+func (p *recordingProvider) Charge(ctx context.Context, amount float32, cardToken string) (string, error) {
+	p.note()
+	return p.FakePaymentProvider.Charge(ctx, amount, cardToken)
+}
+
+func (p *recordingProvider) Refund(ctx context.Context, transactionID string, amount float32) (string, error) {
+	p.note()
+	p.refunds = append(p.refunds, transactionID)
+	return p.FakePaymentProvider.Refund(ctx, transactionID, amount)
+}
+
+func TestRecordChargesAfterThePendingEntry(t *testing.T) {
+	_, payments, bookings := paymentFixture()
+	provider := &recordingProvider{FakePaymentProvider: NewFakePaymentProvider(), payments: payments}
+	service := NewPaymentService(payments, bookings, provider)
+	ctx := SystemContext(context.Background())
+
+	payment, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindDeposit, Amount: 80,
+		Method: models.PaymentMethodCard, CardToken: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.statuses) != 1 || provider.statuses[0] != models.PaymentStatusPending {
+		t.Fatalf("entry statuses at charge time = %v, want pending", provider.statuses)
+	}
+	if stored := payments.ledgers[10][0]; payment.Status != models.PaymentStatusCaptured || stored.Status != models.PaymentStatusCaptured ||
+		stored.Reference != payment.Reference {
+		t.Errorf("payment = %+v, stored %+v, want both captured with the provider reference", payment, stored)
+	}
+
+	// Поки повернення обробляється, його сума вже недоступна для іншого
+	payments.ledgers[10] = append(payments.ledgers[10], models.Payment{ID: 50, BookingID: 10, Kind: models.PaymentKindRefund,
+		Amount: 60, Method: models.PaymentMethodCard, Status: models.PaymentStatusPending, RefundOf: refundOf(payment.ID)})
+	_, err = service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindRefund, Amount: 30, RefundOf: refundOf(payment.ID)})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("refund beyond a pending one gave %v, want a ValidationError", err)
+	}
+	if got := outstanding(t, service, ctx); got != 120 {
+		t.Errorf("outstanding = %.2f, want 120 while the refund is pending", got)
+	}
+}
+
+func TestRecordRefundsAChargeItCantSave(t *testing.T) {
+	_, payments, bookings := paymentFixture()
+	provider := &recordingProvider{FakePaymentProvider: NewFakePaymentProvider(), payments: payments}
+	service := NewPaymentService(payments, bookings, provider)
+	ctx := SystemContext(context.Background())
+	payments.completeErr = errors.New("connection lost")
+
+	_, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindPayment, Amount: 200,
+		Method: models.PaymentMethodCard, CardToken: "tok_visa"})
+	if err == nil {
+		t.Fatal("Record succeeded without saving the outcome")
+	}
+	if len(provider.refunds) != 1 || provider.refunds[0] != "fake_ch_1" {
+		t.Errorf("refunds = %v, want the charge fake_ch_1 given back", provider.refunds)
+	}
+	payments.completeErr = nil
+	if got := outstanding(t, service, ctx); got != 200 {
+		t.Errorf("outstanding = %.2f, want 200 with the entry still pending", got)
+	}
+}
+
+func TestRecordRefund(t *testing.T) {
+	service, _, _ := paymentFixture()
+	ctx := SystemContext(context.Background())
+
+	card, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindPayment, Amount: 120,
+		Method: models.PaymentMethodCard, CardToken: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindPayment, Amount: 80, Method: models.PaymentMethodCash}); err != nil {
+		t.Fatal(err)
+	}
+
+	refund, err := service.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindRefund, Amount: 70, RefundOf: refundOf(card.ID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Method != models.PaymentMethodCard || refund.Reference == "" || refund.Reference == card.Reference {
+		t.Errorf("refund = %+v, want it back to the card with a reference of its own", refund)
+	}
+	if got := outstanding(t, service, ctx); got != 70 {
+		t.Errorf("outstanding = %.2f, want 70", got)
+	}
+
+	rejected := []struct {
+		name  string
+		input PaymentInput
+	}{
+		{"more than is left of the payment", PaymentInput{Kind: models.PaymentKindRefund, Amount: 60, RefundOf: refundOf(card.ID)}},
+		{"more than was paid", PaymentInput{Kind: models.PaymentKindRefund, Amount: 140, Method: models.PaymentMethodCash}},
+		{"an entry of another booking", PaymentInput{Kind: models.PaymentKindRefund, Amount: 10, RefundOf: refundOf(99)}},
+		{"to a card without refund_of", PaymentInput{Kind: models.PaymentKindRefund, Amount: 10, Method: models.PaymentMethodCard}},
+	}
+	for _, tc := range rejected {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.Record(ctx, 10, tc.input)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+		})
+	}
+	if got := outstanding(t, service, ctx); got != 70 {
+		t.Errorf("outstanding after rejected refunds = %.2f, want 70", got)
+	}
+}
+
+func TestCheckOutNeedsSettledBalance(t *testing.T) {
+	payments, _, bookings := paymentFixture()
+	invoices := &fakeInvoiceService{}
+	service := NewBookingService(bookings, &fakePolicyRepository{}, invoices)
+	ctx := SystemContext(context.Background())
+
+	if _, err := payments.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindPayment, Amount: 150, Method: models.PaymentMethodCash}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := service.CheckOut(ctx, 10, false)
+	var conflict *repositories.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("check-out owing 50 gave %v, want a ConflictError", err)
+	}
+	if bookings.bookings[10].Status != models.BookingStatusCheckedIn || len(invoices.issued) != 0 {
+		t.Fatalf("refused check-out left status %s and issued %v", bookings.bookings[10].Status, invoices.issued)
+	}
+
+	if _, err := payments.Record(ctx, 10, PaymentInput{Kind: models.PaymentKindPayment, Amount: 50,
+		Method: models.PaymentMethodCard, CardToken: "tok_visa"}); err != nil {
+		t.Fatal(err)
+	}
+	booking, err := service.CheckOut(ctx, 10, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != models.BookingStatusCheckedOut || len(invoices.issued) != 1 {
+		t.Errorf("settled check-out gave status %s and issued %v", booking.Status, invoices.issued)
+	}
+}
+
+func TestCheckOutOverride(t *testing.T) {
+	_, _, bookings := paymentFixture()
+	invoices := &fakeInvoiceService{}
+	service := NewBookingService(bookings, &fakePolicyRepository{}, invoices)
+
+	receptionist := security.WithIdentity(context.Background(), security.Identity{
+		Method: security.AuthMethodAPIKey, Name: "front-desk", Roles: []string{models.RoleReceptionist}, HotelIDs: []uint{1},
+	})
+	if _, err := service.CheckOut(receptionist, 10, true); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("receptionist override gave %v, want ErrForbidden", err)
+	}
+
+	manager := security.WithIdentity(context.Background(), security.Identity{
+		Method: security.AuthMethodAPIKey, Name: "manager", Roles: []string{models.RoleManager},
+	})
+	booking, err := service.CheckOut(manager, 10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != models.BookingStatusCheckedOut || len(invoices.issued) != 1 {
+		t.Errorf("override check-out gave status %s and issued %v", booking.Status, invoices.issued)
+	}
+}
@@ -1,42 +1,371 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
 	"go.mod/models"
 	"go.mod/repositories"
+	"gorm.io/gorm"
 )
 
 type RoomService interface {
-	GetAll() ([]models.Room, error)
-	GetByID(id uint) (models.Room, error)
-	Create(room *models.Room) error
-	Update(room *models.Room) error
-	Delete(id uint) error
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.Room, error)
+	Stream(ctx context.Context, includeDeleted bool, fn func(room *models.Room) error) error
+	GetByID(ctx context.Context, id uint) (models.Room, error)
+	Create(ctx context.Context, room *models.Room) error
+	Import(ctx context.Context, hotelID uint, rows []RoomImportRow, dryRun bool) (RoomImportResult, error)
+	Update(ctx context.Context, room *models.Room) error
+	Patch(ctx context.Context, room *models.Room, fields []string) error
+	Delete(ctx context.Context, id uint, cascade bool) error
+	GetDeleted(ctx context.Context) ([]models.Room, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
+}
+
+// RoomImportRow is one parsed row of a bulk import; ParseError is set when the row could not be read at all
+type RoomImportRow struct {
+	Line       int
+	Room       models.Room
+	ParseError string
 }
 
+type RoomImportRowResult struct {
+	Line   int      `json:"line"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type RoomImportResult struct {
+	DryRun  bool                  `json:"dry_run"`
+	Created int                   `json:"created"`
+	Skipped int                   `json:"skipped"`
+	Failed  int                   `json:"failed"`
+	Rows    []RoomImportRowResult `json:"rows"`
+}
+
+const (
+	ImportStatusCreated = "created"
+	ImportStatusValid   = "valid"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+)
+
 type roomServiceImpl struct {
-	repo repositories.RoomRepository
+	repo     repositories.RoomRepository
+	types    repositories.RoomTypeRepository
+	notifier Notifier
 }
 
-func NewRoomService(repo repositories.RoomRepository) RoomService {
-	return &roomServiceImpl{repo: repo}
+func NewRoomService(repo repositories.RoomRepository, types repositories.RoomTypeRepository, notifier Notifier) RoomService {
+	return &roomServiceImpl{repo: repo, types: types, notifier: notifier}
 }
 
-func (s *roomServiceImpl) GetAll() ([]models.Room, error) {
-	return s.repo.GetAll()
+func (s *roomServiceImpl) GetAll(ctx context.Context, includeDeleted bool) ([]models.Room, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	rooms, err := s.repo.GetAll(includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return filterByHotel(ctx, rooms, func(room *models.Room) uint { return room.HotelID }), nil
 }
 
-func (s *roomServiceImpl) GetByID(id uint) (models.Room, error) {
-	return s.repo.GetByID(id)
+func (s *roomServiceImpl) Stream(ctx context.Context, includeDeleted bool, fn func(room *models.Room) error) error {
+	if err := authorize(ctx, actionRead); err != nil {
+		return err
+	}
+	return s.repo.Stream(includeDeleted, func(room *models.Room) error {
+		if !canAccessHotel(ctx, room.HotelID) {
+			return nil
+		}
+		return fn(room)
+	})
 }
 
-func (s *roomServiceImpl) Create(room *models.Room) error {
-	return s.repo.Create(room)
+func (s *roomServiceImpl) GetByID(ctx context.Context, id uint) (models.Room, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return models.Room{}, err
+	}
+	room, err := s.repo.GetByID(id)
+	if err != nil {
+		return room, err
+	}
+	if !canAccessHotel(ctx, room.HotelID) {
+		return models.Room{}, ErrForbidden
+	}
+	return room, nil
 }
 
-func (s *roomServiceImpl) Update(room *models.Room) error {
-	return s.repo.Update(room)
+// authorizeChange checks write access to the stored room and to the hotel it is being saved under,
+// so a hotel-scoped user can't move it to a hotel they don't work at
+func (s *roomServiceImpl) authorizeChange(ctx context.Context, room *models.Room) error {
+	existing, err := s.repo.GetByID(room.ID)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionWrite, existing.HotelID); err != nil {
+		return err
+	}
+	return authorizeHotel(ctx, actionWrite, room.HotelID)
 }
 
-func (s *roomServiceImpl) Delete(id uint) error {
-	return s.repo.Delete(id)
+// linkRoomType ties the room to its type, by room_type_id or else by the room_type name.
+// The room takes the type's name, and the base price and occupancy when it has none of its own.
+// Rooms whose room_type name matches no type stay untyped, as rooms created before room types were.
+func (s *roomServiceImpl) linkRoomType(room *models.Room) ([]string, error) {
+	var roomType models.RoomType
+	var err error
+	switch {
+	case room.RoomTypeID != nil:
+		roomType, err = s.types.GetByID(*room.RoomTypeID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []string{fmt.Sprintf("room type %d does not exist", *room.RoomTypeID)}, nil
+		}
+	case strings.TrimSpace(room.RoomType) != "":
+		roomType, err = s.types.GetByName(room.HotelID, room.RoomType)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if roomType.HotelID != room.HotelID {
+		return []string{fmt.Sprintf("room type %d belongs to another hotel", roomType.ID)}, nil
+	}
+
+	room.RoomTypeID = &roomType.ID
+	room.RoomType = roomType.Name
+	if room.Price == 0 {
+		room.Price = roomType.BasePrice
+	}
+	if room.MaxOccupancy == 0 {
+		room.MaxOccupancy = roomType.MaxOccupancy
+	}
+	return nil, nil
+}
+
+// prepare links the room type, fills the default status and occupancy and validates the result
+func (s *roomServiceImpl) prepare(room *models.Room) error {
+	problems, err := s.linkRoomType(room)
+	if err != nil {
+		return err
+	}
+	if room.Status == "" {
+		room.Status = models.RoomStatusAvailable
+	}
+	if room.MaxOccupancy == 0 {
+		room.MaxOccupancy = defaultMaxOccupancy
+	}
+	problems = append(problems, ValidateRoom(room)...)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (s *roomServiceImpl) Create(ctx context.Context, room *models.Room) error {
+	if err := authorizeHotel(ctx, actionWrite, room.HotelID); err != nil {
+		return err
+	}
+	if err := s.prepare(room); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, room)
+}
+
+func (s *roomServiceImpl) Update(ctx context.Context, room *models.Room) error {
+	if err := s.authorizeChange(ctx, room); err != nil {
+		return err
+	}
+	if err := s.prepare(room); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, room)
+}
+
+func (s *roomServiceImpl) Patch(ctx context.Context, room *models.Room, fields []string) error {
+	if err := s.authorizeChange(ctx, room); err != nil {
+		return err
+	}
+	if err := s.prepare(room); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	// Прив'язка до типу могла змінити назву, ціну та місткість
+	return s.repo.Patch(ctx, room, append(fields, "RoomTypeID", "RoomType", "Price", "MaxOccupancy"))
+}
+
+func (s *roomServiceImpl) Delete(ctx context.Context, id uint, cascade bool) error {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionWrite, existing.HotelID); err != nil {
+		return err
+	}
+	reason := "the room was removed"
+	cancelled, err := s.repo.Delete(ctx, id, deleteOptions(cascade, reason))
+	if err != nil {
+		return err
+	}
+	notifyCancelled(ctx, s.notifier, cancelled, reason)
+	return nil
+}
+
+// GetDeleted lists the rooms in the trash that the caller may see
+func (s *roomServiceImpl) GetDeleted(ctx context.Context) ([]models.Room, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	rooms, err := s.repo.GetDeleted()
+	if err != nil {
+		return nil, err
+	}
+	return filterByHotel(ctx, rooms, func(room *models.Room) uint { return room.HotelID }), nil
+}
+
+func (s *roomServiceImpl) Restore(ctx context.Context, id uint) error {
+	room, err := s.repo.GetDeletedByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionWrite, room.HotelID); err != nil {
+		return err
+	}
+	return s.repo.Restore(ctx, id)
+}
+
+// Purge is permanent, so only admins may do it
+func (s *roomServiceImpl) Purge(ctx context.Context, id uint) error {
+	if err := authorize(ctx, actionPurge); err != nil {
+		return err
+	}
+	return s.repo.Purge(ctx, id)
+}
+
+// ValidateRoom returns a list of problems with the room, empty if it is valid
+func ValidateRoom(room *models.Room) []string {
+	var problems []string
+	if strings.TrimSpace(room.RoomType) == "" {
+		problems = append(problems, "room_type is required")
+	}
+	if room.Price <= 0 || math.IsInf(float64(room.Price), 0) || math.IsNaN(float64(room.Price)) {
+		problems = append(problems, "price must be a positive number")
+	}
+	for i, facility := range room.Facilities {
+		if strings.TrimSpace(facility) == "" {
+			problems = append(problems, fmt.Sprintf("facility #%d is empty", i+1))
+		}
+	}
+	if room.MaxOccupancy < 0 {
+		problems = append(problems, "max_occupancy can't be negative")
+	}
+	switch room.Status {
+	case "", models.RoomStatusAvailable, models.RoomStatusOccupied, models.RoomStatusCleaning, models.RoomStatusOutOfService:
+	default:
+		problems = append(problems, "status must be available, occupied, cleaning or out_of_service")
+	}
+	return problems
+}
+
+// Import validates every row and, unless dryRun is set, inserts them all in one transaction.
+// Rows whose number the hotel already has, and rows that repeat an earlier row of the same import,
+// are skipped, so importing the same file twice creates nothing the second time. If any row fails, nothing is written.
+func (s *roomServiceImpl) Import(ctx context.Context, hotelID uint, rows []RoomImportRow, dryRun bool) (RoomImportResult, error) {
+	if err := authorizeHotel(ctx, actionWrite, hotelID); err != nil {
+		return RoomImportResult{}, err
+	}
+
+	numbers, err := s.repo.GetNumbers(hotelID)
+	if err != nil {
+		return RoomImportResult{}, err
+	}
+	existing := make(map[string]bool, len(numbers))
+	for _, number := range numbers {
+		existing[strings.ToLower(number)] = true
+	}
+
+	result := RoomImportResult{DryRun: dryRun}
+	seen := map[string]bool{}
+	var valid []models.Room
+	var validIdx []int
+
+	for _, row := range rows {
+		rowResult := RoomImportRowResult{Line: row.Line}
+
+		var problems []string
+		if row.ParseError != "" {
+			problems = []string{row.ParseError}
+		} else {
+			row.Room.HotelID = hotelID
+			if err := s.prepare(&row.Room); err != nil {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) {
+					return RoomImportResult{}, err
+				}
+				problems = validationErr.Problems
+			}
+		}
+
+		if len(problems) > 0 {
+			rowResult.Status = ImportStatusFailed
+			rowResult.Errors = problems
+			result.Failed++
+			result.Rows = append(result.Rows, rowResult)
+			continue
+		}
+
+		// Лише номер однозначно визначає кімнату; кожен рядок без номера — окрема нова кімната
+		number := strings.ToLower(row.Room.Number)
+		var duplicate string
+		switch {
+		case number != "" && existing[number]:
+			duplicate = fmt.Sprintf("room %s already exists in this hotel", row.Room.Number)
+		case number != "" && seen[number]:
+			duplicate = "duplicate of an earlier row"
+		}
+		if duplicate != "" {
+			rowResult.Status = ImportStatusSkipped
+			rowResult.Errors = []string{duplicate}
+			result.Skipped++
+			result.Rows = append(result.Rows, rowResult)
+			continue
+		}
+		if number != "" {
+			seen[number] = true
+		}
+
+		room := row.Room
+		room.ID = 0
+		room.HotelID = hotelID
+		valid = append(valid, room)
+		validIdx = append(validIdx, len(result.Rows))
+
+		rowResult.Status = ImportStatusValid
+		result.Rows = append(result.Rows, rowResult)
+	}
+
+	if dryRun || result.Failed > 0 || len(valid) == 0 {
+		return result, nil
+	}
+
+	if err := s.repo.CreateBatch(ctx, valid); err != nil {
+		return result, err
+	}
+
+	for _, i := range validIdx {
+		result.Rows[i].Status = ImportStatusCreated
+	}
+	result.Created = len(valid)
+	return result, nil
 }
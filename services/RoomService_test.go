@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.mod/models"
+)
+
+func importRows(rooms ...models.Room) []RoomImportRow {
+	rows := make([]RoomImportRow, len(rooms))
+	for i, room := range rooms {
+		rows[i] = RoomImportRow{Line: i + 2, Room: room}
+	}
+	return rows
+}
+
+func rowStatuses(result RoomImportResult) []string {
+	var statuses []string
+	for _, row := range result.Rows {
+		statuses = append(statuses, row.Status)
+	}
+	return statuses
+}
+
+func newImportFixture() (RoomService, *fakeRoomRepository) {
+	doubleID := uint(5)
+	rooms := &fakeRoomRepository{rooms: []models.Room{
+		{HotelID: 1, Number: "101", RoomType: "Double", RoomTypeID: &doubleID, Price: 120},
+		{HotelID: 2, Number: "102", RoomType: "Double", Price: 90},
+	}}
+	types := &fakeRoomTypeRepository{roomTypes: []models.RoomType{{HotelID: 1, Name: "Double", BasePrice: 120, MaxOccupancy: 2}}}
+	types.roomTypes[0].ID = doubleID
+	return NewRoomService(rooms, types, LogNotifier{}), rooms
+}
+
+func TestImportDryRunWritesNothing(t *testing.T) {
+	service, repo := newImportFixture()
+	ctx := SystemContext(context.Background())
+	rows := importRows(
+		models.Room{Number: "102", RoomType: "double"},
+		models.Room{Number: "103", RoomType: "Double", Price: 140},
+	)
+
+	dryRun, err := service.Import(ctx, 1, rows, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{ImportStatusValid, ImportStatusValid}; !dryRun.DryRun || dryRun.Created != 0 || !slices.Equal(rowStatuses(dryRun), want) {
+		t.Fatalf("dry run = %+v, want both rows valid and nothing created", dryRun)
+	}
+	if repo.batches != 0 || len(repo.rooms) != 2 {
+		t.Fatalf("dry run wrote %d batches, %d rooms", repo.batches, len(repo.rooms))
+	}
+
+	committed, err := service.Import(ctx, 1, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{ImportStatusCreated, ImportStatusCreated}; committed.DryRun || committed.Created != 2 || !slices.Equal(rowStatuses(committed), want) {
+		t.Fatalf("import = %+v, want both rows created", committed)
+	}
+	if repo.batches != 1 || len(repo.rooms) != 4 {
+		t.Fatalf("import wrote %d batches, %d rooms; want one batch of two", repo.batches, len(repo.rooms))
+	}
+	if added := repo.rooms[2]; added.HotelID != 1 || added.RoomTypeID == nil || *added.RoomTypeID != 5 || added.Price != 120 {
+		t.Errorf("imported room = %+v, want it linked to the hotel's Double type at its base price", added)
+	}
+}
+
+func TestImportSkipsRoomsTheHotelHas(t *testing.T) {
+	service, repo := newImportFixture()
+	ctx := SystemContext(context.Background())
+	rows := importRows(
+		models.Room{Number: "101", RoomType: "Double"},
+		models.Room{Number: "104", RoomType: "Double"},
+		models.Room{Number: "104", RoomType: "Double"},
+	)
+
+	for _, dryRun := range []bool{true, false} {
+		result, err := service.Import(ctx, 1, rows, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Skipped != 2 || result.Rows[0].Status != ImportStatusSkipped || result.Rows[2].Status != ImportStatusSkipped {
+			t.Fatalf("dry run %v: %+v, want 101 and the repeated 104 skipped", dryRun, result)
+		}
+	}
+
+	again, err := service.Import(ctx, 1, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Created != 0 || again.Skipped != 3 {
+		t.Fatalf("second import = %+v, want every row skipped", again)
+	}
+	if repo.batches != 1 || len(repo.rooms) != 3 {
+		t.Fatalf("store has %d rooms after %d batches, want only 104 added once", len(repo.rooms), repo.batches)
+	}
+}
+
+func TestImportFailedRowWritesNothing(t *testing.T) {
+	service, repo := newImportFixture()
+	rows := importRows(models.Room{Number: "105", RoomType: "Double"}, models.Room{Number: "106"})
+	rows = append(rows, RoomImportRow{Line: 9, ParseError: "invalid price \"abc\""})
+
+	result, err := service.Import(SystemContext(context.Background()), 1, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{ImportStatusValid, ImportStatusFailed, ImportStatusFailed}; result.Failed != 2 || !slices.Equal(rowStatuses(result), want) {
+		t.Fatalf("import = %+v, want rows 2 and 3 failed", result)
+	}
+	if repo.batches != 0 {
+		t.Fatal("an import with failed rows was written")
+	}
+}
+
+func TestImportCreatesEveryUnnumberedRow(t *testing.T) {
+	service, repo := newImportFixture()
+	rows := importRows(
+		models.Room{RoomType: "Double", Price: 120},
+		models.Room{RoomType: "Double", Price: 120},
+		models.Room{Number: "105", RoomType: "Double", Price: 120},
+	)
+
+	result, err := service.Import(SystemContext(context.Background()), 1, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 3 || result.Skipped != 0 {
+		t.Fatalf("import = %+v, want two identical unnumbered rooms and 105 created", result)
+	}
+	if len(repo.rooms) != 5 {
+		t.Fatalf("store has %d rooms, want 5", len(repo.rooms))
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+const (
+	// maxAvailabilityNights limits the stay an availability query can ask about
+	maxAvailabilityNights = 366
+	defaultMaxOccupancy   = 2
+	// maxSuggestedRooms and maxSuggestions bound the search for room combinations
+	maxSuggestedRooms = 10
+	maxSuggestions    = 5
+)
+
+type RoomTypeService interface {
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.RoomType, error)
+	GetByID(ctx context.Context, id uint) (models.RoomType, error)
+	Create(ctx context.Context, roomType *models.RoomType) error
+	Update(ctx context.Context, roomType *models.RoomType) error
+	Delete(ctx context.Context, id uint, cascade bool) error
+	Availability(ctx context.Context, hotelID uint, query AvailabilityQuery) (AvailabilityResult, error)
+}
+
+// AvailabilityQuery is a stay and, optionally, the party that needs rooms for it
+type AvailabilityQuery struct {
+	CheckIn  models.Date
+	CheckOut models.Date
+	Adults   int
+	Children int
+}
+
+// RoomCombination is one way to fit the party into free rooms
+type RoomCombination struct {
+	Rooms         []RoomCombinationItem `json:"rooms"`
+	Capacity      int                   `json:"capacity"`
+	PricePerNight float32               `json:"price_per_night"`
+	// Quote prices the whole stay with the hotel's taxes
+	Quote *Quote `json:"quote,omitempty"`
+}
+
+type RoomCombinationItem struct {
+	RoomTypeID uint   `json:"room_type_id"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+type AvailabilityResult struct {
+	RoomTypes   []repositories.RoomTypeAvailability `json:"room_types"`
+	Suggestions []RoomCombination                   `json:"suggestions,omitempty"`
+}
+
+type roomTypeServiceImpl struct {
+	repo     repositories.RoomTypeRepository
+	taxes    repositories.TaxRuleRepository
+	notifier Notifier
+}
+
+func NewRoomTypeService(repo repositories.RoomTypeRepository, taxes repositories.TaxRuleRepository, notifier Notifier) RoomTypeService {
+	return &roomTypeServiceImpl{repo: repo, taxes: taxes, notifier: notifier}
+}
+
+func (s *roomTypeServiceImpl) GetAll(ctx context.Context, includeDeleted bool) ([]models.RoomType, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	roomTypes, err := s.repo.GetAll(includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return filterByHotel(ctx, roomTypes, func(roomType *models.RoomType) uint { return roomType.HotelID }), nil
+}
+
+func (s *roomTypeServiceImpl) GetByID(ctx context.Context, id uint) (models.RoomType, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return models.RoomType{}, err
+	}
+	roomType, err := s.repo.GetByID(id)
+	if err != nil {
+		return roomType, err
+	}
+	if !canAccessHotel(ctx, roomType.HotelID) {
+		return models.RoomType{}, ErrForbidden
+	}
+	return roomType, nil
+}
+
+// Room types set the prices, so changing them takes the same permission as changing hotels
+func (s *roomTypeServiceImpl) Create(ctx context.Context, roomType *models.RoomType) error {
+	if err := authorizeHotel(ctx, actionManageHotels, roomType.HotelID); err != nil {
+		return err
+	}
+	if roomType.MaxOccupancy == 0 {
+		roomType.MaxOccupancy = defaultMaxOccupancy
+	}
+	if problems := ValidateRoomType(roomType); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	roomType.ID = 0
+	return s.repo.Create(ctx, roomType)
+}
+
+func (s *roomTypeServiceImpl) Update(ctx context.Context, roomType *models.RoomType) error {
+	existing, err := s.repo.GetByID(roomType.ID)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionManageHotels, existing.HotelID); err != nil {
+		return err
+	}
+	if roomType.HotelID != existing.HotelID {
+		return &ValidationError{Problems: []string{"a room type can't be moved to another hotel"}}
+	}
+	if problems := ValidateRoomType(roomType); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	roomType.CreatedAt = existing.CreatedAt
+	return s.repo.Update(ctx, roomType)
+}
+
+func (s *roomTypeServiceImpl) Delete(ctx context.Context, id uint, cascade bool) error {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionManageHotels, existing.HotelID); err != nil {
+		return err
+	}
+	reason := "the room type is no longer offered"
+	cancelled, err := s.repo.Delete(ctx, id, deleteOptions(cascade, reason))
+	if err != nil {
+		return err
+	}
+	notifyCancelled(ctx, s.notifier, cancelled, reason)
+	return nil
+}
+
+// Availability lists how many rooms of each of the hotel's types are free for the whole stay.
+// With a party it also suggests room combinations that sleep everyone, fewest rooms and lowest price first,
+// each with a quote for the stay.
+func (s *roomTypeServiceImpl) Availability(ctx context.Context, hotelID uint, query AvailabilityQuery) (AvailabilityResult, error) {
+	if err := authorizeHotel(ctx, actionRead, hotelID); err != nil {
+		return AvailabilityResult{}, err
+	}
+	problems := validateStay(query.CheckIn, query.CheckOut)
+	if query.Adults < 0 || query.Children < 0 {
+		problems = append(problems, "adults and children can't be negative")
+	} else if query.Children > 0 && query.Adults == 0 {
+		problems = append(problems, "children need at least one adult")
+	}
+	if len(problems) > 0 {
+		return AvailabilityResult{}, &ValidationError{Problems: problems}
+	}
+
+	roomTypes, err := s.repo.Availability(hotelID, query.CheckIn, query.CheckOut)
+	if err != nil {
+		return AvailabilityResult{}, err
+	}
+	result := AvailabilityResult{RoomTypes: roomTypes}
+	if party := query.Adults + query.Children; party > 0 {
+		result.Suggestions = suggestCombinations(roomTypes, party)
+		if len(result.Suggestions) > 0 {
+			rules, err := s.taxes.GetByHotel(hotelID)
+			if err != nil {
+				return AvailabilityResult{}, err
+			}
+			nights := daysBetween(query.CheckIn, query.CheckOut)
+			for i := range result.Suggestions {
+				quote := quoteCombination(result.Suggestions[i], roomTypes, rules, nights, query)
+				result.Suggestions[i].Quote = &quote
+			}
+		}
+	}
+	return result, nil
+}
+
+// suggestCombinations searches the ways to take free rooms that sleep the party without a spare room.
+// The search stops at maxSuggestedRooms rooms.
+func suggestCombinations(roomTypes []repositories.RoomTypeAvailability, party int) []RoomCombination {
+	var found []RoomCombination
+	counts := make([]int, len(roomTypes))
+
+	var search func(i, rooms, capacity int, price float32)
+	search = func(i, rooms, capacity int, price float32) {
+		if capacity >= party {
+			smallest := capacity
+			for j, count := range counts {
+				if count > 0 {
+					smallest = min(smallest, roomTypes[j].RoomType.MaxOccupancy)
+				}
+			}
+			// Кімната, без якої гості все одно вміщуються, зайва
+			if capacity-smallest >= party {
+				return
+			}
+
+			combination := RoomCombination{Capacity: capacity, PricePerNight: price}
+			for j, count := range counts {
+				if count > 0 {
+					combination.Rooms = append(combination.Rooms, RoomCombinationItem{
+						RoomTypeID: roomTypes[j].RoomType.ID,
+						Name:       roomTypes[j].RoomType.Name,
+						Count:      count,
+					})
+				}
+			}
+			found = append(found, combination)
+			return
+		}
+		if i == len(roomTypes) || rooms == maxSuggestedRooms {
+			return
+		}
+
+		roomType := roomTypes[i].RoomType
+		for count := min(roomTypes[i].Available, maxSuggestedRooms-rooms); count > 0; count-- {
+			counts[i] = count
+			search(i+1, rooms+count, capacity+count*roomType.MaxOccupancy, price+float32(count)*roomType.BasePrice)
+		}
+		counts[i] = 0
+		search(i+1, rooms, capacity, price)
+	}
+	search(0, 0, 0, 0)
+
+	sort.SliceStable(found, func(a, b int) bool {
+		roomsA, roomsB := roomCount(found[a]), roomCount(found[b])
+		if roomsA != roomsB {
+			return roomsA < roomsB
+		}
+		return found[a].PricePerNight < found[b].PricePerNight
+	})
+	if len(found) > maxSuggestions {
+		found = found[:maxSuggestions]
+	}
+	return found
+}
+
+func quoteCombination(combination RoomCombination, roomTypes []repositories.RoomTypeAvailability, rules []models.TaxRule,
+	nights int, query AvailabilityQuery) Quote {
+	var lines []PriceLine
+	for _, item := range combination.Rooms {
+		for _, roomType := range roomTypes {
+			if roomType.RoomType.ID == item.RoomTypeID {
+				lines = append(lines, newPriceLine(item.Name, item.Count*nights, roomType.RoomType.BasePrice))
+			}
+		}
+	}
+	return newQuote(lines, rules, query.CheckIn, nights, query.Adults, query.Children)
+}
+
+func roomCount(combination RoomCombination) int {
+	total := 0
+	for _, item := range combination.Rooms {
+		total += item.Count
+	}
+	return total
+}
+
+func validateStay(checkIn, checkOut models.Date) []string {
+	switch {
+	case checkIn.IsZero() || checkOut.IsZero():
+		return []string{"check_in and check_out are required"}
+	case !checkOut.After(checkIn.Time):
+		return []string{"check_out must be after check_in"}
+	case checkOut.Sub(checkIn.Time).Hours() > 24*maxAvailabilityNights:
+		return []string{fmt.Sprintf("a stay can't be longer than %d nights", maxAvailabilityNights)}
+	}
+	return nil
+}
+
+// ValidateRoomType returns a list of problems with the room type, empty if it is valid
+func ValidateRoomType(roomType *models.RoomType) []string {
+	var problems []string
+	if roomType.HotelID == 0 {
+		problems = append(problems, "hotel_id is required")
+	}
+	if strings.TrimSpace(roomType.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if roomType.BasePrice <= 0 || math.IsInf(float64(roomType.BasePrice), 0) || math.IsNaN(float64(roomType.BasePrice)) {
+		problems = append(problems, "base_price must be a positive number")
+	}
+	if roomType.MaxOccupancy < 1 {
+		problems = append(problems, "max_occupancy must be at least 1")
+	}
+	for i, bed := range roomType.Beds {
+		if strings.TrimSpace(bed.Type) == "" || bed.Count < 1 {
+			problems = append(problems, fmt.Sprintf("bed #%d needs a type and a count of at least 1", i+1))
+		}
+	}
+	for i, facility := range roomType.Facilities {
+		if strings.TrimSpace(facility) == "" {
+			problems = append(problems, fmt.Sprintf("facility #%d is empty", i+1))
+		}
+	}
+	return problems
+}
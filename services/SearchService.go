@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"gorm.io/gorm"
+)
+
+const (
+	// searchPollInterval is how often the index takes up the changes written to the audit log
+	searchPollInterval   = 2 * time.Second
+	searchFeedBatch      = 500
+	defaultSearchLimit   = 10
+	maxSearchLimit       = 50
+	maxSearchQueryLength = 200
+)
+
+// searchedEntities are the audit entity types the index follows
+var searchedEntities = []string{models.AuditEntityHotel, models.AuditEntityGuest, models.AuditEntityBooking}
+
+type SearchHit struct {
+	ID       uint    `json:"id"`
+	Title    string  `json:"title"`
+	Subtitle string  `json:"subtitle,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// SearchResults groups the hits by entity type, best first in each group
+type SearchResults struct {
+	Query    string      `json:"query"`
+	Hotels   []SearchHit `json:"hotels"`
+	Guests   []SearchHit `json:"guests"`
+	Bookings []SearchHit `json:"bookings"`
+}
+
+type SearchService interface {
+	// Search finds up to limit hotels, guests and bookings each; a limit of 0 takes the default
+	Search(ctx context.Context, query string, limit int) (SearchResults, error)
+	// Run loads the index and then keeps it up to date with the audit log until stop is closed
+	Run(stop <-chan struct{})
+}
+
+type searchServiceImpl struct {
+	index    SearchIndex
+	hotels   repositories.HotelRepository
+	guests   repositories.GuestRepository
+	bookings repositories.BookingRepository
+	audit    repositories.AuditRepository
+
+	// cursor is the last audit entry the index has taken up; only Run touches it
+	cursor uint
+}
+
+func NewSearchService(index SearchIndex, hotels repositories.HotelRepository, guests repositories.GuestRepository,
+	bookings repositories.BookingRepository, audit repositories.AuditRepository) SearchService {
+	return &searchServiceImpl{index: index, hotels: hotels, guests: guests, bookings: bookings, audit: audit}
+}
+
+func (s *searchServiceImpl) Search(ctx context.Context, query string, limit int) (SearchResults, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return SearchResults{}, err
+	}
+	query = strings.TrimSpace(query)
+	results := SearchResults{Query: query, Hotels: []SearchHit{}, Guests: []SearchHit{}, Bookings: []SearchHit{}}
+	if query == "" {
+		return results, &ValidationError{Problems: []string{"q is required"}}
+	}
+	if len([]rune(query)) > maxSearchQueryLength {
+		return results, &ValidationError{Problems: []string{fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength)}}
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	for _, match := range s.index.Search(query) {
+		doc := match.Document
+		hit := SearchHit{ID: doc.ID, Title: doc.Title, Subtitle: doc.Subtitle, Score: math.Round(match.Score*100) / 100}
+		var group *[]SearchHit
+		switch doc.Entity {
+		case SearchEntityHotel:
+			group = &results.Hotels
+		case SearchEntityGuest:
+			group = &results.Guests
+		case SearchEntityBooking:
+			group = &results.Bookings
+		default:
+			continue
+		}
+		if len(*group) >= limit || (doc.Entity != SearchEntityGuest && !canAccessHotel(ctx, doc.HotelID)) {
+			continue
+		}
+		*group = append(*group, hit)
+	}
+	return results, nil
+}
+
+func (s *searchServiceImpl) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(searchPollInterval)
+	defer ticker.Stop()
+
+	loaded := false
+	for {
+		if !loaded {
+			if err := s.load(); err != nil {
+				log.Printf("Search index failed to load: %v", err)
+			} else {
+				loaded = true
+			}
+		} else if err := s.follow(); err != nil {
+			log.Printf("Search index failed to update: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// load indexes every hotel, guest and booking. The cursor is taken first, so the changes made
+// while loading are taken up again afterwards.
+func (s *searchServiceImpl) load() error {
+	cursor, err := s.audit.LastID()
+	if err != nil {
+		return err
+	}
+	err = s.hotels.Stream(false, func(hotel *models.Hotel) error {
+		s.index.Put(hotelDocument(hotel))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.guests.Stream(false, func(guest *models.Guest) error {
+		s.index.Put(guestDocument(guest))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.bookings.Stream(false, func(booking *models.Booking) error {
+		s.index.Put(bookingDocument(booking))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.cursor = cursor
+	return nil
+}
+
+// follow reindexes the records changed since the cursor. The cursor only moves past entries that
+// were taken up, so a failed record is tried again on the next poll.
+func (s *searchServiceImpl) follow() error {
+	for {
+		entries, err := s.audit.After(s.cursor, searchedEntities, searchFeedBatch)
+		if err != nil {
+			return err
+		}
+		done := map[searchKey]bool{}
+		for _, entry := range entries {
+			key := searchKey{entry.EntityType, entry.EntityID}
+			if !done[key] {
+				if err := s.reindex(key.entity, key.id); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				done[key] = true
+			}
+			s.cursor = entry.ID
+		}
+		if len(entries) < searchFeedBatch {
+			return nil
+		}
+	}
+}
+
+// reindex reads the record again; a record that is gone or in the trash leaves the index. When a hotel
+// or guest is renamed, the bookings that show the name are reindexed too.
+func (s *searchServiceImpl) reindex(entity string, id uint) error {
+	previous, indexed := s.index.Get(entity, id)
+	doc, err := s.document(entity, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.index.Remove(entity, id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.index.Put(doc)
+
+	if indexed && previous.Title != doc.Title {
+		for _, ref := range s.index.Referencing(searchKey{entity, id}.String()) {
+			if err := s.reindex(ref.Entity, ref.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *searchServiceImpl) document(entity string, id uint) (SearchDocument, error) {
+	switch entity {
+	case SearchEntityHotel:
+		hotel, err := s.hotels.GetByID(id)
+		return hotelDocument(&hotel), err
+	case SearchEntityGuest:
+		guest, err := s.guests.GetByID(id)
+		return guestDocument(&guest), err
+	case SearchEntityBooking:
+		booking, err := s.bookings.GetByID(id)
+		return bookingDocument(&booking), err
+	}
+	return SearchDocument{}, fmt.Errorf("unknown search entity %q", entity)
+}
+
+func hotelDocument(hotel *models.Hotel) SearchDocument {
+	text := []string{hotel.Address, hotel.Email, hotel.Website}
+	text = append(text, hotel.Amenities...)
+	return SearchDocument{
+		Entity:   SearchEntityHotel,
+		ID:       hotel.ID,
+		HotelID:  hotel.ID,
+		Title:    hotel.Name,
+		Subtitle: hotel.Address,
+		Text:     strings.Join(text, " "),
+		Phones:   []string{hotel.Phone},
+	}
+}
+
+func guestDocument(guest *models.Guest) SearchDocument {
+	return SearchDocument{
+		Entity:   SearchEntityGuest,
+		ID:       guest.ID,
+		Title:    guest.Name,
+		Subtitle: guest.MobileNumber,
+		Text:     guest.Email,
+		Phones:   []string{guest.MobileNumber},
+	}
+}
+
+// bookingDocument is found by the guest's name and phone, the hotel's name, its number and dates
+func bookingDocument(booking *models.Booking) SearchDocument {
+	return SearchDocument{
+		Entity:   SearchEntityBooking,
+		ID:       booking.ID,
+		HotelID:  booking.HotelID,
+		Title:    fmt.Sprintf("%s, %s – %s", booking.Guest.Name, booking.CheckIn, booking.CheckOut),
+		Subtitle: fmt.Sprintf("%s, %s", booking.Hotel.Name, booking.Status),
+		Text:     fmt.Sprintf("%d %s %s %s %s", booking.ID, booking.Hotel.Name, booking.CheckIn, booking.CheckOut, booking.Status),
+		Phones:   []string{booking.Guest.MobileNumber},
+		Refs: []string{
+			searchKey{SearchEntityGuest, booking.GuestID}.String(),
+			searchKey{SearchEntityHotel, booking.HotelID}.String(),
+		},
+	}
+}
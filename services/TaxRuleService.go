@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+type TaxRuleService interface {
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.TaxRule, error)
+	GetByID(ctx context.Context, id uint) (models.TaxRule, error)
+	Create(ctx context.Context, rule *models.TaxRule) error
+	Update(ctx context.Context, rule *models.TaxRule) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type taxRuleServiceImpl struct {
+	repo repositories.TaxRuleRepository
+}
+
+func NewTaxRuleService(repo repositories.TaxRuleRepository) TaxRuleService {
+	return &taxRuleServiceImpl{repo: repo}
+}
+
+func (s *taxRuleServiceImpl) GetAll(ctx context.Context, includeDeleted bool) ([]models.TaxRule, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return nil, err
+	}
+	rules, err := s.repo.GetAll(includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	return filterByHotel(ctx, rules, func(rule *models.TaxRule) uint { return rule.HotelID }), nil
+}
+
+func (s *taxRuleServiceImpl) GetByID(ctx context.Context, id uint) (models.TaxRule, error) {
+	if err := authorize(ctx, actionRead); err != nil {
+		return models.TaxRule{}, err
+	}
+	rule, err := s.repo.GetByID(id)
+	if err != nil {
+		return rule, err
+	}
+	if !canAccessHotel(ctx, rule.HotelID) {
+		return models.TaxRule{}, ErrForbidden
+	}
+	return rule, nil
+}
+
+func (s *taxRuleServiceImpl) Create(ctx context.Context, rule *models.TaxRule) error {
+	if err := authorizeHotel(ctx, actionManageHotels, rule.HotelID); err != nil {
+		return err
+	}
+	if problems := ValidateTaxRule(rule); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	rule.ID = 0
+	return s.repo.Create(ctx, rule)
+}
+
+func (s *taxRuleServiceImpl) Update(ctx context.Context, rule *models.TaxRule) error {
+	existing, err := s.repo.GetByID(rule.ID)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionManageHotels, existing.HotelID); err != nil {
+		return err
+	}
+	if rule.HotelID != existing.HotelID {
+		return &ValidationError{Problems: []string{"a tax rule can't be moved to another hotel"}}
+	}
+	if problems := ValidateTaxRule(rule); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	rule.CreatedAt = existing.CreatedAt
+	return s.repo.Update(ctx, rule)
+}
+
+func (s *taxRuleServiceImpl) Delete(ctx context.Context, id uint) error {
+	existing, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if err := authorizeHotel(ctx, actionManageHotels, existing.HotelID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// ValidateTaxRule returns a list of problems with the rule, empty if it is valid
+func ValidateTaxRule(rule *models.TaxRule) []string {
+	var problems []string
+	if rule.HotelID == 0 {
+		problems = append(problems, "hotel_id is required")
+	}
+	if strings.TrimSpace(rule.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	rate := float64(rule.Rate)
+	if math.IsInf(rate, 0) || math.IsNaN(rate) || rate <= 0 {
+		problems = append(problems, "rate must be a positive number")
+	}
+	switch rule.Kind {
+	case models.TaxKindPercent:
+		if rate > 100 {
+			problems = append(problems, "rate of a percent rule can't be above 100")
+		}
+		if rule.ExemptChildren {
+			problems = append(problems, "exempt_children only applies to per_person_night rules")
+		}
+	case models.TaxKindPerPersonNight:
+		// Податок з гостя за ніч не може входити у ціну номера, яка не залежить від кількості гостей
+		if rule.Inclusive {
+			problems = append(problems, "only percent rules can be inclusive")
+		}
+	default:
+		problems = append(problems, "kind must be percent or per_person_night")
+	}
+	if !rule.EffectiveFrom.IsZero() && !rule.EffectiveUntil.IsZero() && !rule.EffectiveUntil.After(rule.EffectiveFrom.Time) {
+		problems = append(problems, "effective_until must be after effective_from")
+	}
+	return problems
+}
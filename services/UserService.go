@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"go.mod/security"
+)
+
+const minPasswordLength = 10
+
+// UserInput is what clients send to create or change a staff account; an empty Password keeps the old one
+type UserInput struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	HotelIDs []uint `json:"hotel_ids"`
+}
+
+type UserService interface {
+	GetAll(ctx context.Context) ([]models.User, error)
+	GetByID(ctx context.Context, id uint) (models.User, error)
+	Create(ctx context.Context, input UserInput) (models.User, error)
+	Update(ctx context.Context, id uint, input UserInput) (models.User, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type userServiceImpl struct {
+	repo repositories.UserRepository
+}
+
+func NewUserService(repo repositories.UserRepository) UserService {
+	return &userServiceImpl{repo: repo}
+}
+
+func (s *userServiceImpl) GetAll(ctx context.Context) ([]models.User, error) {
+	if err := authorize(ctx, actionManageUsers); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll()
+}
+
+func (s *userServiceImpl) GetByID(ctx context.Context, id uint) (models.User, error) {
+	if err := authorize(ctx, actionManageUsers); err != nil {
+		return models.User{}, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *userServiceImpl) Create(ctx context.Context, input UserInput) (models.User, error) {
+	if err := authorize(ctx, actionManageUsers); err != nil {
+		return models.User{}, err
+	}
+	if problems := validateUserInput(input, true); len(problems) > 0 {
+		return models.User{}, &ValidationError{Problems: problems}
+	}
+
+	user := models.User{Username: strings.TrimSpace(input.Username), Role: input.Role}
+	if err := setPassword(&user, input.Password); err != nil {
+		return user, err
+	}
+	user.Hotels = hotelRefs(input.HotelIDs)
+
+	if err := s.repo.Create(&user); err != nil {
+		return user, err
+	}
+	return s.repo.GetByID(user.ID)
+}
+
+func (s *userServiceImpl) Update(ctx context.Context, id uint, input UserInput) (models.User, error) {
+	if err := authorize(ctx, actionManageUsers); err != nil {
+		return models.User{}, err
+	}
+	if problems := validateUserInput(input, false); len(problems) > 0 {
+		return models.User{}, &ValidationError{Problems: problems}
+	}
+
+	user, err := s.repo.GetByID(id)
+	if err != nil {
+		return user, err
+	}
+	user.Username = strings.TrimSpace(input.Username)
+	user.Role = input.Role
+	user.Hotels = hotelRefs(input.HotelIDs)
+	if input.Password != "" {
+		if err := setPassword(&user, input.Password); err != nil {
+			return user, err
+		}
+	}
+
+	if err := s.repo.Update(&user); err != nil {
+		return user, err
+	}
+	return s.repo.GetByID(id)
+}
+
+func (s *userServiceImpl) Delete(ctx context.Context, id uint) error {
+	if err := authorize(ctx, actionManageUsers); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// setPassword hashes with the same sha512 + argon2id scheme as the API key
+func setPassword(user *models.User, password string) error {
+	hash, salt, err := security.HashSecret(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	user.PasswordSalt = salt
+	return nil
+}
+
+func hotelRefs(ids []uint) []models.Hotel {
+	hotels := make([]models.Hotel, 0, len(ids))
+	for _, id := range ids {
+		hotel := models.Hotel{}
+		hotel.ID = id
+		hotels = append(hotels, hotel)
+	}
+	return hotels
+}
+
+func validateUserInput(input UserInput, requirePassword bool) []string {
+	var problems []string
+	if strings.TrimSpace(input.Username) == "" {
+		problems = append(problems, "username is required")
+	}
+	if !IsValidRole(input.Role) {
+		problems = append(problems, "role must be one of admin, manager, receptionist, read-only")
+	}
+	if input.Role == models.RoleReceptionist && len(input.HotelIDs) == 0 {
+		problems = append(problems, "a receptionist must be assigned to at least one hotel")
+	}
+	if (requirePassword || input.Password != "") && len(input.Password) < minPasswordLength {
+		problems = append(problems, "password must be at least 10 characters")
+	}
+	return problems
+}
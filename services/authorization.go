@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"go.mod/models"
+	"go.mod/security"
+)
+
+// ErrForbidden is returned when the caller's role doesn't allow the operation
+var ErrForbidden = errors.New("forbidden")
+
+type action int
+
+const (
+	actionRead action = iota
+	actionWrite
+	actionManageHotels
+	actionManageUsers
+	actionViewAudit
+	actionPurge
+	// actionViewGuestDetails shows a guest's date of birth, address, nationality and identity document
+	actionViewGuestDetails
+	// actionViewGuestDocuments shows document numbers in full rather than their last characters
+	actionViewGuestDocuments
+)
+
+// rolePermissions is the whole permission model; token role claims use the same names
+var rolePermissions = map[string][]action{
+	models.RoleAdmin: {actionRead, actionWrite, actionManageHotels, actionManageUsers, actionViewAudit, actionPurge,
+		actionViewGuestDetails, actionViewGuestDocuments},
+	models.RoleManager:      {actionRead, actionWrite, actionManageHotels, actionViewAudit, actionViewGuestDetails, actionViewGuestDocuments},
+	models.RoleReceptionist: {actionRead, actionWrite, actionViewGuestDetails},
+	models.RoleReadOnly:     {actionRead},
+}
+
+// hotelScopedRoles only see hotels listed in Identity.HotelIDs
+var hotelScopedRoles = map[string]bool{
+	models.RoleReceptionist: true,
+}
+
+// SystemContext is used by background jobs and CLI commands that act on behalf of the server itself
+func SystemContext(ctx context.Context) context.Context {
+	return security.WithIdentity(ctx, security.Identity{
+		Method: security.AuthMethodSystem,
+		Name:   "system",
+		Roles:  []string{models.RoleAdmin},
+	})
+}
+
+// IsValidRole reports whether role is part of the permission model
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// authorize checks that one of the caller's roles allows the action.
+// A context without an identity is denied, so a forgotten middleware fails closed.
+func authorize(ctx context.Context, a action) error {
+	identity, ok := security.IdentityFromContext(ctx)
+	if !ok {
+		return ErrForbidden
+	}
+	for _, role := range identity.Roles {
+		for _, allowed := range rolePermissions[role] {
+			if allowed == a {
+				return nil
+			}
+		}
+	}
+	return ErrForbidden
+}
+
+// hotelScope returns the hotels the caller is limited to; all is true when there is no limit
+func hotelScope(ctx context.Context) (hotelIDs map[uint]bool, all bool) {
+	identity, ok := security.IdentityFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	// Роль без обмеження за готелями має пріоритет, якщо їх кілька
+	for _, role := range identity.Roles {
+		if _, known := rolePermissions[role]; known && !hotelScopedRoles[role] {
+			return nil, true
+		}
+	}
+
+	hotelIDs = make(map[uint]bool, len(identity.HotelIDs))
+	for _, id := range identity.HotelIDs {
+		hotelIDs[id] = true
+	}
+	return hotelIDs, false
+}
+
+// canAccessHotel reports whether the caller may see data that belongs to hotelID
+func canAccessHotel(ctx context.Context, hotelID uint) bool {
+	hotelIDs, all := hotelScope(ctx)
+	return all || hotelIDs[hotelID]
+}
+
+// authorizeHotel combines authorize with the hotel scope check
+func authorizeHotel(ctx context.Context, a action, hotelID uint) error {
+	if err := authorize(ctx, a); err != nil {
+		return err
+	}
+	if !canAccessHotel(ctx, hotelID) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// filterByHotel drops items that belong to hotels outside the caller's scope
+func filterByHotel[T any](ctx context.Context, items []T, hotelID func(item *T) uint) []T {
+	hotelIDs, all := hotelScope(ctx)
+	if all {
+		return items
+	}
+	filtered := make([]T, 0, len(items))
+	for i := range items {
+		if hotelIDs[hotelID(&items[i])] {
+			filtered = append(filtered, items[i])
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,12 @@
+package services
+
+import "strings"
+
+// ValidationError is returned when an entity fails validation before it is saved
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed: " + strings.Join(e.Problems, "; ")
+}
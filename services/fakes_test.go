@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mod/models"
+	"go.mod/repositories"
+	"gorm.io/gorm"
+)
+
+// The fakes embed the repository interfaces and implement only what the tests call;
+// anything else panics on the nil interface, which points at the missing method.
+
+type fakeHotelRepository struct {
+	repositories.HotelRepository
+	hotels []models.Hotel
+}
+
+func (r *fakeHotelRepository) GetAllBrief() ([]models.Hotel, error) {
+	return r.hotels, nil
+}
+
+// GetNear returns every hotel with coordinates, as a box around the whole globe would
+func (r *fakeHotelRepository) GetNear(latitude, longitude, radiusKm float64, includeDeleted bool) ([]models.Hotel, error) {
+	var found []models.Hotel
+	for _, hotel := range r.hotels {
+		if hotel.Latitude != nil && hotel.Longitude != nil {
+			found = append(found, hotel)
+		}
+	}
+	return found, nil
+}
+
+type fakeBookingRepository struct {
+	repositories.BookingRepository
+	bookings map[uint]*models.Booking
+	// onDate lists the days the night audit asked for
+	onDate []models.Date
+	// payments holds the ledgers CheckOut settles against
+	payments *fakePaymentRepository
+}
+
+func newFakeBookingRepository(bookings ...models.Booking) *fakeBookingRepository {
+	repo := &fakeBookingRepository{bookings: map[uint]*models.Booking{}}
+	for i := range bookings {
+		booking := bookings[i]
+		repo.bookings[booking.ID] = &booking
+	}
+	return repo
+}
+
+func (r *fakeBookingRepository) sorted(keep func(booking *models.Booking) bool) []models.Booking {
+	var found []models.Booking
+	for _, booking := range r.bookings {
+		if keep(booking) {
+			found = append(found, *booking)
+		}
+	}
+	sort.Slice(found, func(a, b int) bool { return found[a].ID < found[b].ID })
+	return found
+}
+
+func (r *fakeBookingRepository) GetByID(id uint) (models.Booking, error) {
+	booking, ok := r.bookings[id]
+	if !ok {
+		return models.Booking{}, gorm.ErrRecordNotFound
+	}
+	return *booking, nil
+}
+
+func (r *fakeBookingRepository) GetUnarrived(hotelID uint, arrivingBy models.Date) ([]models.Booking, error) {
+	return r.sorted(func(booking *models.Booking) bool {
+		return booking.HotelID == hotelID && booking.Status == models.BookingStatusConfirmed && !booking.CheckIn.After(arrivingBy.Time)
+	}), nil
+}
+
+func (r *fakeBookingRepository) GetOnDate(hotelID uint, date models.Date) ([]models.Booking, error) {
+	r.onDate = append(r.onDate, date)
+	return r.sorted(func(booking *models.Booking) bool {
+		return booking.HotelID == hotelID && !booking.CheckIn.After(date.Time) && !booking.CheckOut.Before(date.Time)
+	}), nil
+}
+
+func (r *fakeBookingRepository) NoShow(ctx context.Context, id uint, penalty float32) error {
+	booking := r.bookings[id]
+	if booking.Status != models.BookingStatusConfirmed {
+		return &repositories.ConflictError{Message: fmt.Sprintf("booking %d is %s", id, booking.Status)}
+	}
+	booking.Status = models.BookingStatusNoShow
+	booking.CancellationPenalty = penalty
+	return nil
+}
+
+func (r *fakeBookingRepository) Cancel(ctx context.Context, id uint, reason string, penalty float32) error {
+	booking := r.bookings[id]
+	booking.Status = models.BookingStatusCancelled
+	booking.CancellationReason = reason
+	booking.CancellationPenalty = penalty
+	return nil
+}
+
+type fakePolicyRepository struct {
+	repositories.CancellationPolicyRepository
+	policies map[uint]models.CancellationPolicy
+}
+
+func (r *fakePolicyRepository) GetAnyByID(id uint) (models.CancellationPolicy, error) {
+	policy, ok := r.policies[id]
+	if !ok {
+		return policy, gorm.ErrRecordNotFound
+	}
+	return policy, nil
+}
+
+type fakeRoomRepository struct {
+	repositories.RoomRepository
+	rooms []models.Room
+	// batches counts the CreateBatch calls, i.e. the import transactions
+	batches int
+}
+
+func (r *fakeRoomRepository) GetNumbers(hotelID uint) ([]string, error) {
+	var numbers []string
+	for _, room := range r.rooms {
+		if room.HotelID == hotelID && room.Number != "" {
+			numbers = append(numbers, room.Number)
+		}
+	}
+	return numbers, nil
+}
+
+func (r *fakeRoomRepository) CreateBatch(ctx context.Context, rooms []models.Room) error {
+	r.batches++
+	for _, room := range rooms {
+		room.ID = uint(len(r.rooms) + 1)
+		r.rooms = append(r.rooms, room)
+	}
+	return nil
+}
+
+type fakeRoomTypeRepository struct {
+	repositories.RoomTypeRepository
+	roomTypes []models.RoomType
+}
+
+func (r *fakeRoomTypeRepository) GetByName(hotelID uint, name string) (models.RoomType, error) {
+	for _, roomType := range r.roomTypes {
+		if roomType.HotelID == hotelID && strings.EqualFold(roomType.Name, name) {
+			return roomType, nil
+		}
+	}
+	return models.RoomType{}, gorm.ErrRecordNotFound
+}
+
+type fakePaymentRepository struct {
+	repositories.PaymentRepository
+	ledgers map[uint][]models.Payment
+	nextID  uint
+	// completeErr makes Complete fail, as a lost database connection would
+	completeErr error
+}
+
+func newFakePaymentRepository() *fakePaymentRepository {
+	return &fakePaymentRepository{ledgers: map[uint][]models.Payment{}}
+}
+
+func (r *fakePaymentRepository) GetByBooking(bookingID uint) ([]models.Payment, error) {
+	return slices.Clone(r.ledgers[bookingID]), nil
+}
+
+func (r *fakePaymentRepository) Record(ctx context.Context, bookingID uint, build func(ledger []models.Payment) (models.Payment, error)) (models.Payment, error) {
+	payment, err := build(slices.Clone(r.ledgers[bookingID]))
+	if err != nil {
+		return models.Payment{}, err
+	}
+	r.nextID++
+	payment.ID = r.nextID
+	payment.BookingID = bookingID
+	r.ledgers[bookingID] = append(r.ledgers[bookingID], payment)
+	return payment, nil
+}
+
+func (r *fakePaymentRepository) Complete(ctx context.Context, id uint, status, reference string) error {
+	if r.completeErr != nil {
+		return r.completeErr
+	}
+	for _, ledger := range r.ledgers {
+		for i := range ledger {
+			if ledger[i].ID == id && ledger[i].Status == models.PaymentStatusPending {
+				ledger[i].Status = status
+				ledger[i].Reference = reference
+				return nil
+			}
+		}
+	}
+	return &repositories.ConflictError{Message: fmt.Sprintf("payment %d is not pending", id)}
+}
+
+func (r *fakeBookingRepository) CheckOut(ctx context.Context, id uint, settle func(booking *models.Booking, ledger []models.Payment) error) error {
+	booking := r.bookings[id]
+	if booking.Status != models.BookingStatusCheckedIn {
+		return &repositories.ConflictError{Message: fmt.Sprintf("booking %d is %s and can't be checked out", id, booking.Status)}
+	}
+	var ledger []models.Payment
+	if r.payments != nil {
+		ledger, _ = r.payments.GetByBooking(id)
+	}
+	if err := settle(booking, ledger); err != nil {
+		return err
+	}
+	booking.Status = models.BookingStatusCheckedOut
+	return nil
+}
+
+type fakeInvoiceService struct {
+	InvoiceService
+	// issued lists the bookings invoiced
+	issued []uint
+}
+
+func (s *fakeInvoiceService) Issue(ctx context.Context, bookingID uint) (Folio, bool, error) {
+	s.issued = append(s.issued, bookingID)
+	return Folio{}, true, nil
+}
+
+// fakeInvoiceRepository numbers each hotel's invoices from 1 and, like the database, gives a failed issue's number back
+type fakeInvoiceRepository struct {
+	repositories.InvoiceRepository
+	bookings *fakeBookingRepository
+	payments *fakePaymentRepository
+	invoices map[uint]models.Invoice
+	last     map[uint]uint
+}
+
+func newFakeInvoiceRepository(bookings *fakeBookingRepository, payments *fakePaymentRepository) *fakeInvoiceRepository {
+	return &fakeInvoiceRepository{bookings: bookings, payments: payments, invoices: map[uint]models.Invoice{}, last: map[uint]uint{}}
+}
+
+func (r *fakeInvoiceRepository) GetByBooking(bookingID uint) (models.Invoice, error) {
+	invoice, ok := r.invoices[bookingID]
+	if !ok {
+		return models.Invoice{}, gorm.ErrRecordNotFound
+	}
+	return invoice, nil
+}
+
+func (r *fakeInvoiceRepository) Issue(ctx context.Context, bookingID uint, build repositories.FolioBuilder) (models.Invoice, bool, error) {
+	if invoice, ok := r.invoices[bookingID]; ok {
+		return invoice, false, nil
+	}
+	booking := r.bookings.bookings[bookingID]
+	ledger, _ := r.payments.GetByBooking(bookingID)
+	invoice := models.Invoice{
+		ID:        uint(len(r.invoices) + 1),
+		IssuedAt:  time.Date(2026, 4, 12, 11, 0, 0, 0, time.UTC),
+		HotelID:   booking.HotelID,
+		Number:    r.last[booking.HotelID] + 1,
+		BookingID: bookingID,
+	}
+	folio, err := build(booking, ledger, invoice.Number, invoice.IssuedAt)
+	if err != nil {
+		return models.Invoice{}, false, err
+	}
+	invoice.Folio = folio
+	r.last[booking.HotelID] = invoice.Number
+	r.invoices[bookingID] = invoice
+	return invoice, true, nil
+}
+
+type fakeGuestRepository struct {
+	repositories.GuestRepository
+	guests map[uint]models.Guest
+	// hotels and deletedHotels hold the hotels of each guest's live and trashed bookings
+	hotels, deletedHotels map[uint][]uint
+	deleted, restored     []uint
+}
+
+func newFakeGuestRepository(guests ...models.Guest) *fakeGuestRepository {
+	repo := &fakeGuestRepository{guests: map[uint]models.Guest{}, hotels: map[uint][]uint{}, deletedHotels: map[uint][]uint{}}
+	for _, guest := range guests {
+		repo.guests[guest.ID] = guest
+	}
+	return repo
+}
+
+func (r *fakeGuestRepository) GetByID(id uint) (models.Guest, error) {
+	guest, ok := r.guests[id]
+	if !ok {
+		return models.Guest{}, gorm.ErrRecordNotFound
+	}
+	return guest, nil
+}
+
+func (r *fakeGuestRepository) GetAll(includeDeleted bool) ([]models.Guest, error) {
+	var guests []models.Guest
+	for _, guest := range r.guests {
+		guests = append(guests, guest)
+	}
+	sort.Slice(guests, func(a, b int) bool { return guests[a].ID < guests[b].ID })
+	return guests, nil
+}
+
+func (r *fakeGuestRepository) HotelIDs(id uint, includeDeleted bool) ([]uint, error) {
+	if includeDeleted {
+		return append(slices.Clone(r.hotels[id]), r.deletedHotels[id]...), nil
+	}
+	return r.hotels[id], nil
+}
+
+func (r *fakeGuestRepository) Update(ctx context.Context, guest *models.Guest) error {
+	r.guests[guest.ID] = *guest
+	return nil
+}
+
+func (r *fakeGuestRepository) Patch(ctx context.Context, guest *models.Guest, fields []string) error {
+	r.guests[guest.ID] = *guest
+	return nil
+}
+
+func (r *fakeGuestRepository) Delete(ctx context.Context, id uint, opts repositories.DeleteOptions) ([]models.Booking, error) {
+	r.deleted = append(r.deleted, id)
+	return nil, nil
+}
+
+func (r *fakeGuestRepository) Restore(ctx context.Context, id uint) error {
+	r.restored = append(r.restored, id)
+	return nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"go.mod/models"
+)
+
+// locations caches the loaded zones by name; loading one reads the zone database
+var locations sync.Map
+
+// hotelLocation is the hotel's time zone. Hotels without one, or with a name the zone database
+// doesn't know, are kept in UTC.
+func hotelLocation(hotel *models.Hotel) *time.Location {
+	if hotel.TimeZone == "" {
+		return time.UTC
+	}
+	if cached, ok := locations.Load(hotel.TimeZone); ok {
+		return cached.(*time.Location)
+	}
+	location, err := time.LoadLocation(hotel.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	locations.Store(hotel.TimeZone, location)
+	return location
+}
+
+// hotelToday is the calendar date at the hotel at the instant now
+func hotelToday(hotel *models.Hotel, now time.Time) models.Date {
+	return models.DateOf(now.In(hotelLocation(hotel)))
+}
+
+// hotelInstant is the UTC instant when the hotel's clocks show clock ("15:04") on date. On the day clocks
+// fall back and show the time twice, it is the first time; on the day they jump over it, it is as late
+// as the jump, e.g. 02:30 becomes 03:30.
+func hotelInstant(hotel *models.Hotel, date models.Date, clock string) time.Time {
+	location := hotelLocation(hotel)
+	hour, minute := 0, 0
+	if parsed, err := time.Parse("15:04", clock); err == nil {
+		hour, minute = parsed.Hour(), parsed.Minute()
+	}
+	year, month, day := date.Date()
+	wall := time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+
+	// Зсуви до і після можливого переходу; годинник переводять не частіше ніж раз на добу
+	_, before := wall.Add(-12 * time.Hour).In(location).Zone()
+	_, after := wall.Add(12 * time.Hour).In(location).Zone()
+	var found time.Time
+	for _, offset := range []int{before, after} {
+		instant := wall.Add(-time.Duration(offset) * time.Second)
+		local := instant.In(location)
+		if local.Day() == day && local.Hour() == hour && local.Minute() == minute && (found.IsZero() || instant.Before(found)) {
+			found = instant
+		}
+	}
+	if found.IsZero() {
+		found = wall.Add(-time.Duration(before) * time.Second)
+	}
+	return found.UTC()
+}
+
+// checkInWindow is when the booking can be checked in: from the start of its arrival day at the hotel
+// until the no-show cutoff, the hotel's check-out time on the morning after arrival
+func checkInWindow(booking *models.Booking) (opens, cutoff time.Time) {
+	opens = hotelInstant(&booking.Hotel, booking.CheckIn, "00:00")
+	checkOutTime := booking.Hotel.CheckOutTime
+	if checkOutTime == "" {
+		checkOutTime = defaultCheckOutTime
+	}
+	cutoff = hotelInstant(&booking.Hotel, models.Date{Time: booking.CheckIn.AddDate(0, 0, 1)}, checkOutTime)
+	return opens, cutoff
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"go.mod/models"
+)
+
+var kyiv = &models.Hotel{TimeZone: "Europe/Kyiv", CheckOutTime: "11:00"}
+
+func utc(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestHotelInstant(t *testing.T) {
+	// У 2026 році Київ переводить годинник 29 березня з 03:00 на 04:00 і 25 жовтня з 04:00 на 03:00
+	tests := []struct {
+		name  string
+		hotel *models.Hotel
+		date  models.Date
+		clock string
+		want  string
+	}{
+		{"winter", kyiv, models.NewDate(2026, 1, 15), "15:00", "2026-01-15T13:00:00Z"},
+		{"summer", kyiv, models.NewDate(2026, 7, 1), "15:00", "2026-07-01T12:00:00Z"},
+		{"spring forward, midnight", kyiv, models.NewDate(2026, 3, 29), "00:00", "2026-03-28T22:00:00Z"},
+		{"spring forward, 02:30 before the jump", kyiv, models.NewDate(2026, 3, 29), "02:30", "2026-03-29T00:30:00Z"},
+		{"spring forward, skipped 03:00 moves to 04:00", kyiv, models.NewDate(2026, 3, 29), "03:00", "2026-03-29T01:00:00Z"},
+		{"spring forward, skipped 03:30 moves to 04:30", kyiv, models.NewDate(2026, 3, 29), "03:30", "2026-03-29T01:30:00Z"},
+		{"spring forward, after the jump", kyiv, models.NewDate(2026, 3, 29), "11:00", "2026-03-29T08:00:00Z"},
+		{"fall back, midnight", kyiv, models.NewDate(2026, 10, 25), "00:00", "2026-10-24T21:00:00Z"},
+		{"fall back, repeated 03:30 is the first one", kyiv, models.NewDate(2026, 10, 25), "03:30", "2026-10-25T00:30:00Z"},
+		{"fall back, after the repeat", kyiv, models.NewDate(2026, 10, 25), "11:00", "2026-10-25T09:00:00Z"},
+		{"no time zone is UTC", &models.Hotel{}, models.NewDate(2026, 3, 29), "03:30", "2026-03-29T03:30:00Z"},
+		{"unknown time zone is UTC", &models.Hotel{TimeZone: "Mars/Olympus"}, models.NewDate(2026, 3, 29), "03:30", "2026-03-29T03:30:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hotelInstant(tt.hotel, tt.date, tt.clock); !got.Equal(utc(tt.want)) {
+				t.Errorf("hotelInstant(%s, %s) = %s, want %s", tt.date, tt.clock, got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestHotelToday(t *testing.T) {
+	tests := []struct {
+		now  string
+		want models.Date
+	}{
+		{"2026-03-28T21:59:00Z", models.NewDate(2026, 3, 28)},
+		{"2026-03-28T22:00:00Z", models.NewDate(2026, 3, 29)},
+		{"2026-10-24T20:59:00Z", models.NewDate(2026, 10, 24)},
+		{"2026-10-24T21:00:00Z", models.NewDate(2026, 10, 25)},
+	}
+	for _, tt := range tests {
+		if got := hotelToday(kyiv, utc(tt.now)); got != tt.want {
+			t.Errorf("hotelToday(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestCheckInWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		arrival    models.Date
+		opens      string
+		cutoff     string
+		windowSpan time.Duration
+	}{
+		{"ordinary night", models.NewDate(2026, 1, 15), "2026-01-14T22:00:00Z", "2026-01-16T09:00:00Z", 35 * time.Hour},
+		{"night the clocks go forward", models.NewDate(2026, 3, 28), "2026-03-27T22:00:00Z", "2026-03-29T08:00:00Z", 34 * time.Hour},
+		{"night the clocks go back", models.NewDate(2026, 10, 24), "2026-10-23T21:00:00Z", "2026-10-25T09:00:00Z", 36 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			booking := &models.Booking{Hotel: *kyiv, CheckIn: tt.arrival, CheckOut: models.Date{Time: tt.arrival.AddDate(0, 0, 2)}}
+			opens, cutoff := checkInWindow(booking)
+			if !opens.Equal(utc(tt.opens)) || !cutoff.Equal(utc(tt.cutoff)) {
+				t.Errorf("checkInWindow() = %s .. %s, want %s .. %s", opens.Format(time.RFC3339), cutoff.Format(time.RFC3339), tt.opens, tt.cutoff)
+			}
+			if span := cutoff.Sub(opens); span != tt.windowSpan {
+				t.Errorf("window lasts %s, want %s", span, tt.windowSpan)
+			}
+		})
+	}
+}
+
+func TestCheckInWindowDefaultsCheckOutTime(t *testing.T) {
+	booking := &models.Booking{Hotel: models.Hotel{TimeZone: "Europe/Kyiv"}, CheckIn: models.NewDate(2026, 1, 15)}
+	if _, cutoff := checkInWindow(booking); !cutoff.Equal(utc("2026-01-16T09:00:00Z")) {
+		t.Errorf("cutoff = %s, want the default 11:00 check-out", cutoff.Format(time.RFC3339))
+	}
+}
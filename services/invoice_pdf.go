@@ -0,0 +1,282 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"go.mod/models"
+)
+
+const (
+	pdfPageWidth  = 595 // A4 in points
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfLeading    = 14
+	// maxDescriptionRunes keeps a line's description clear of the quantity column
+	maxDescriptionRunes = 48
+	maxTaxLabelRunes    = 36
+)
+
+// pdfText is one string on a page; x is its left edge, or its right edge when right is set
+type pdfText struct {
+	x, y  float64
+	size  float64
+	bold  bool
+	right bool
+	text  string
+}
+
+// pdfLayout places text top to bottom and starts a new page when one is full
+type pdfLayout struct {
+	pages [][]pdfText
+	rules [][][4]float64
+	y     float64
+}
+
+func newPDFLayout() *pdfLayout {
+	l := &pdfLayout{}
+	l.newPage()
+	return l
+}
+
+func (l *pdfLayout) newPage() {
+	l.pages = append(l.pages, nil)
+	l.rules = append(l.rules, nil)
+	l.y = pdfPageHeight - pdfMargin
+}
+
+// next moves down by height, first starting a new page if it doesn't fit
+func (l *pdfLayout) next(height float64) float64 {
+	if l.y-height < pdfMargin {
+		l.newPage()
+	}
+	l.y -= height
+	return l.y
+}
+
+func (l *pdfLayout) add(text pdfText) {
+	page := len(l.pages) - 1
+	l.pages[page] = append(l.pages[page], text)
+}
+
+// line writes a row of texts at the same height
+func (l *pdfLayout) line(size float64, texts ...pdfText) {
+	y := l.next(size + pdfLeading - 10)
+	for _, text := range texts {
+		text.y, text.size = y, size
+		l.add(text)
+	}
+}
+
+func (l *pdfLayout) rule() {
+	y := l.next(6) + 3
+	page := len(l.rules) - 1
+	l.rules[page] = append(l.rules[page], [4]float64{pdfMargin, y, pdfPageWidth - pdfMargin, y})
+}
+
+func (l *pdfLayout) gap() {
+	l.next(pdfLeading / 2)
+}
+
+// Колонки таблиці: опис ліворуч, числа вирівняні праворуч
+const (
+	pdfColTax      = 240
+	pdfColQuantity = 360
+	pdfColUnit     = 450
+	pdfColAmount   = pdfPageWidth - pdfMargin
+)
+
+func money(amount float32) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+func shorten(text string, maxRunes int) string {
+	if runes := []rune(text); len(runes) > maxRunes {
+		return string(runes[:maxRunes-3]) + "..."
+	}
+	return text
+}
+
+// RenderInvoicePDF lays the folio out on A4 pages. It uses the standard Helvetica fonts, so no font
+// has to be embedded; characters they can't show, e.g. Cyrillic, are printed as '?'.
+func RenderInvoicePDF(folio *Folio) []byte {
+	l := newPDFLayout()
+	left := func(x float64, text string) pdfText { return pdfText{x: x, text: text} }
+	right := func(x float64, text string) pdfText { return pdfText{x: x, text: text, right: true} }
+	bold := func(text pdfText) pdfText { text.bold = true; return text }
+
+	title := fmt.Sprintf("Invoice %d", folio.Number)
+	if folio.Draft {
+		title = "Folio (draft, not an invoice)"
+	}
+	l.line(18, bold(left(pdfMargin, title)))
+	if folio.IssuedAt != nil {
+		l.line(10, left(pdfMargin, "Issued "+folio.IssuedAt.Format("2006-01-02")))
+	}
+	l.gap()
+
+	l.line(12, bold(left(pdfMargin, folio.Hotel.Name)))
+	for _, addressLine := range strings.Split(folio.Hotel.Address, "\n") {
+		if addressLine = strings.TrimSpace(addressLine); addressLine != "" {
+			l.line(10, left(pdfMargin, addressLine))
+		}
+	}
+	l.gap()
+
+	l.line(10, left(pdfMargin, "Guest: "+folio.Guest.Name), right(pdfColAmount, fmt.Sprintf("Booking %d", folio.BookingID)))
+	if folio.Guest.MobileNumber != "" {
+		l.line(10, left(pdfMargin, folio.Guest.MobileNumber))
+	}
+	if !folio.CheckIn.IsZero() {
+		l.line(10, left(pdfMargin, fmt.Sprintf("Stay: %s to %s, %d nights", folio.CheckIn, folio.CheckOut, folio.Nights)))
+	}
+	l.gap()
+
+	l.line(10, bold(left(pdfMargin, "Description")), bold(right(pdfColQuantity, "Qty")),
+		bold(right(pdfColUnit, "Unit price")), bold(right(pdfColAmount, "Amount")))
+	l.rule()
+	for _, line := range folio.Lines {
+		l.line(10, left(pdfMargin, shorten(line.Description, maxDescriptionRunes)), right(pdfColQuantity, fmt.Sprint(line.Quantity)),
+			right(pdfColUnit, money(line.UnitPrice)), right(pdfColAmount, money(line.Amount)))
+	}
+	l.rule()
+	l.line(10, left(pdfColQuantity, "Subtotal"), right(pdfColAmount, money(folio.Subtotal)))
+	for _, tax := range folio.Taxes {
+		label := tax.Name
+		switch {
+		case tax.Kind == models.TaxKindPercent && tax.Inclusive:
+			label = fmt.Sprintf("%s %g%% (included)", tax.Name, tax.Rate)
+		case tax.Kind == models.TaxKindPercent:
+			label = fmt.Sprintf("%s %g%%", tax.Name, tax.Rate)
+		}
+		l.line(10, left(pdfColTax, shorten(label, maxTaxLabelRunes)), right(pdfColAmount, money(tax.Amount)))
+	}
+	l.line(11, bold(left(pdfColQuantity, "Total")), bold(right(pdfColAmount, money(folio.Total))))
+	l.gap()
+
+	if len(folio.Payments) > 0 {
+		l.line(10, bold(left(pdfMargin, "Payments")))
+		l.rule()
+		for _, payment := range folio.Payments {
+			description := payment.Kind
+			if payment.Method != "" {
+				description += ", " + payment.Method
+			}
+			l.line(10, left(pdfMargin, payment.Date.Format("2006-01-02")), left(pdfMargin+80, description),
+				right(pdfColAmount, money(payment.Amount)))
+		}
+		l.rule()
+	}
+	l.line(10, left(pdfColQuantity, "Paid"), right(pdfColAmount, money(folio.Paid)))
+	l.line(11, bold(left(pdfColQuantity, "Balance due")), bold(right(pdfColAmount, money(folio.BalanceDue))))
+
+	return l.render()
+}
+
+// render writes the pages as a PDF 1.4 file: catalog, page tree, the two fonts, then a page
+// and its content stream for every page
+func (l *pdfLayout) render() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	const firstPage = 5
+	kids := make([]string, len(l.pages))
+	for i := range l.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(l.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, texts := range l.pages {
+		var content bytes.Buffer
+		for _, r := range l.rules[i] {
+			fmt.Fprintf(&content, "0.5 w %.1f %.1f m %.1f %.1f l S\n", r[0], r[1], r[2], r[3])
+		}
+		for _, text := range texts {
+			encoded := winAnsi(text.text)
+			font := "F1"
+			if text.bold {
+				font = "F2"
+			}
+			x := text.x
+			if text.right {
+				x -= textWidth(encoded, text.bold) * text.size / 1000
+			}
+			fmt.Fprintf(&content, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, text.size, x, text.y, pdfEscape(encoded))
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// winAnsiSpecial are the characters WinAnsiEncoding keeps in 0x80-0x9F instead of Latin-1's control codes
+var winAnsiSpecial = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+func winAnsi(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch special, ok := winAnsiSpecial[r]; {
+		case ok:
+			out = append(out, special)
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+func pdfEscape(text []byte) []byte {
+	var out []byte
+	for _, c := range text {
+		if c == '(' || c == ')' || c == '\\' {
+			out = append(out, '\\')
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// textWidth measures text in thousandths of the font size. Digits and the usual number punctuation have
+// their exact Helvetica widths, which is all the right-aligned columns hold; other characters are estimated.
+func textWidth(text []byte, bold bool) float64 {
+	width := 0.0
+	for _, c := range text {
+		switch {
+		case c >= '0' && c <= '9':
+			width += 556
+		case c == '.' || c == ',':
+			width += 278
+		case c == '-':
+			width += 333
+		case c == ' ':
+			width += 278
+		case bold:
+			width += 611
+		default:
+			width += 556
+		}
+	}
+	return width
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mod/models"
+	"go.mod/repositories"
+)
+
+// Notifier tells guests about changes to their bookings that they didn't make themselves
+type Notifier interface {
+	BookingCancelled(ctx context.Context, booking models.Booking, reason string)
+}
+
+// LogNotifier only logs the notifications; it stands in until a mail or SMS gateway is configured
+type LogNotifier struct{}
+
+func (LogNotifier) BookingCancelled(ctx context.Context, booking models.Booking, reason string) {
+	log.Printf("Notification to guest %d: booking %d (%s - %s) cancelled: %s",
+		booking.GuestID, booking.ID, booking.CheckIn, booking.CheckOut, reason)
+}
+
+// deleteOptions builds the delete policy; cascade cancels future bookings instead of refusing the delete.
+// Whether a booking is still in the future is decided by the calendar of its own hotel.
+func deleteOptions(cascade bool, reason string) repositories.DeleteOptions {
+	now := time.Now()
+	return repositories.DeleteOptions{
+		Cascade: cascade,
+		Today:   func(hotel *models.Hotel) models.Date { return hotelToday(hotel, now) },
+		Reason:  reason,
+	}
+}
+
+func notifyCancelled(ctx context.Context, notifier Notifier, bookings []models.Booking, reason string) {
+	for _, booking := range bookings {
+		notifier.BookingCancelled(ctx, booking, reason)
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPaymentDeclined is returned by a PaymentProvider that refused to move the money
+var ErrPaymentDeclined = errors.New("payment declined")
+
+// PaymentProvider moves the money of card payments, deposits and refunds.
+// Both methods return the provider's transaction ID, which the ledger keeps as the entry's reference.
+type PaymentProvider interface {
+	Charge(ctx context.Context, amount float32, cardToken string) (string, error)
+	Refund(ctx context.Context, transactionID string, amount float32) (string, error)
+}
+
+// FakeDeclinedCard is the card token FakePaymentProvider declines
+const FakeDeclinedCard = "tok_declined"
+
+// FakePaymentProvider approves every card except FakeDeclinedCard and keeps its transactions in memory.
+// It stands in for a real gateway in development and tests.
+type FakePaymentProvider struct {
+	mu   sync.Mutex
+	next int
+	// refundable is what is left to refund of every charge made since the start
+	refundable map[string]float32
+}
+
+func NewFakePaymentProvider() *FakePaymentProvider {
+	return &FakePaymentProvider{refundable: map[string]float32{}}
+}
+
+func (p *FakePaymentProvider) Charge(ctx context.Context, amount float32, cardToken string) (string, error) {
+	if cardToken == FakeDeclinedCard {
+		return "", fmt.Errorf("%w: card %s", ErrPaymentDeclined, cardToken)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	id := fmt.Sprintf("fake_ch_%d", p.next)
+	p.refundable[id] = amount
+	return id, nil
+}
+
+// Refund also accepts charges it doesn't know, e.g. ones made before a restart
+func (p *FakePaymentProvider) Refund(ctx context.Context, transactionID string, amount float32) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if left, ok := p.refundable[transactionID]; ok {
+		if amount > left {
+			return "", fmt.Errorf("%w: only %.2f of %s is left to refund", ErrPaymentDeclined, left, transactionID)
+		}
+		p.refundable[transactionID] = left - amount
+	}
+	p.next++
+	return fmt.Sprintf("fake_re_%d", p.next), nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"go.mod/models"
+)
+
+// nightlyRate is the price of one night of the booking: its booked rooms plus the base price
+// of every reserved room type that has no room assigned yet
+func nightlyRate(booking *models.Booking) float32 {
+	var rate float32
+	for _, room := range booking.BookedRooms {
+		rate += room.Price
+	}
+	for _, reservation := range booking.Reservations {
+		if reservation.RoomID == nil && reservation.RoomType != nil {
+			rate += reservation.RoomType.BasePrice
+		}
+	}
+	return rate
+}
+
+// StayPrice is what the booking's rooms and unassigned reservations cost for the whole stay, before taxes
+func StayPrice(booking *models.Booking) float32 {
+	return roundMoney(float64(nightlyRate(booking)) * float64(stayNights(booking)))
+}
+
+// stayNights counts the nights of the stay, 0 for a booking without dates
+func stayNights(booking *models.Booking) int {
+	if booking.CheckIn.IsZero() || booking.CheckOut.IsZero() {
+		return 0
+	}
+	return daysBetween(booking.CheckIn, booking.CheckOut)
+}
+
+func daysBetween(from, to models.Date) int {
+	return int(math.Round(to.Sub(from.Time).Hours() / 24))
+}
+
+func roundMoney(amount float64) float32 {
+	return float32(math.Round(amount*100) / 100)
+}
+
+// CancellationPenalty is what cancelling the booking on the given day costs under the policy.
+// Without a policy or dates cancelling is free; a policy with FreeUntilDays 0 has no free period.
+func CancellationPenalty(policy *models.CancellationPolicy, booking *models.Booking, today models.Date) float32 {
+	nights := stayNights(booking)
+	if policy == nil || nights == 0 {
+		return 0
+	}
+	if policy.FreeUntilDays > 0 && daysBetween(today, booking.CheckIn) >= policy.FreeUntilDays {
+		return 0
+	}
+
+	rate := float64(nightlyRate(booking))
+	switch policy.PenaltyType {
+	case models.PenaltyTypePercent:
+		return roundMoney(rate * float64(nights) * float64(policy.PenaltyPercent) / 100)
+	default:
+		return roundMoney(rate)
+	}
+}
+
+// PriceLine is one item of a quote or folio
+type PriceLine struct {
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float32 `json:"unit_price"`
+	Amount      float32 `json:"amount"`
+}
+
+func newPriceLine(description string, quantity int, unitPrice float32) PriceLine {
+	return PriceLine{
+		Description: description,
+		Quantity:    quantity,
+		UnitPrice:   unitPrice,
+		Amount:      roundMoney(float64(unitPrice) * float64(quantity)),
+	}
+}
+
+// TaxLine is what one of the hotel's tax rules adds up to. Inclusive taxes are already part of the subtotal.
+type TaxLine struct {
+	Name      string  `json:"name"`
+	Kind      string  `json:"kind"`
+	Rate      float32 `json:"rate"`
+	Inclusive bool    `json:"inclusive"`
+	Amount    float32 `json:"amount"`
+}
+
+// Quote is a price broken down into its lines and the taxes charged on them
+type Quote struct {
+	Lines    []PriceLine `json:"lines"`
+	Subtotal float32     `json:"subtotal"`
+	Taxes    []TaxLine   `json:"taxes"`
+	Total    float32     `json:"total"`
+}
+
+// newQuote applies the tax rules in effect on the check-in day to the lines. Percent taxes are taken of the net
+// price, i.e. the subtotal without its inclusive taxes; per-person taxes are charged for every night the party stays.
+func newQuote(lines []PriceLine, rules []models.TaxRule, checkIn models.Date, nights, adults, children int) Quote {
+	quote := Quote{Lines: lines, Taxes: []TaxLine{}}
+	if quote.Lines == nil {
+		quote.Lines = []PriceLine{}
+	}
+	var inEffect []models.TaxRule
+	for _, rule := range rules {
+		if rule.InEffect(checkIn) {
+			inEffect = append(inEffect, rule)
+		}
+	}
+	rules = inEffect
+
+	var subtotal, inclusiveRate float64
+	for _, line := range lines {
+		subtotal += float64(line.Amount)
+	}
+	for _, rule := range rules {
+		if rule.Kind == models.TaxKindPercent && rule.Inclusive {
+			inclusiveRate += float64(rule.Rate)
+		}
+	}
+	net := subtotal / (1 + inclusiveRate/100)
+
+	total := subtotal
+	for _, rule := range rules {
+		var amount float64
+		switch rule.Kind {
+		case models.TaxKindPercent:
+			amount = net * float64(rule.Rate) / 100
+		case models.TaxKindPerPersonNight:
+			guests := adults
+			if !rule.ExemptChildren {
+				guests += children
+			}
+			amount = float64(rule.Rate) * float64(guests) * float64(nights)
+		}
+		if amount = float64(roundMoney(amount)); amount == 0 {
+			continue
+		}
+		quote.Taxes = append(quote.Taxes, TaxLine{
+			Name:      rule.Name,
+			Kind:      rule.Kind,
+			Rate:      rule.Rate,
+			Inclusive: rule.Inclusive,
+			Amount:    float32(amount),
+		})
+		if !rule.Inclusive {
+			total += amount
+		}
+	}
+	quote.Subtotal = roundMoney(subtotal)
+	quote.Total = roundMoney(total)
+	return quote
+}
+
+// BookingQuote prices the booking's stay, or its cancellation or no-show fee, and the ledger's extra charges
+// with the hotel's taxes. A cancelled or no-show booking pays no per-person taxes, as nobody stayed.
+func BookingQuote(booking *models.Booking, ledger []models.Payment) Quote {
+	var lines []PriceLine
+	nights := stayNights(booking)
+	switch {
+	case booking.Status == models.BookingStatusCancelled || booking.Status == models.BookingStatusNoShow:
+		if booking.CancellationPenalty > 0 {
+			description := "Cancellation fee"
+			if booking.Status == models.BookingStatusNoShow {
+				description = "No-show fee"
+			}
+			lines = append(lines, newPriceLine(description, 1, booking.CancellationPenalty))
+		}
+		nights = 0
+	case nights > 0:
+		for _, room := range booking.BookedRooms {
+			description := "Room " + room.RoomType
+			if room.Number != "" {
+				description = fmt.Sprintf("Room %s, %s", room.Number, room.RoomType)
+			}
+			lines = append(lines, newPriceLine(description, nights, room.Price))
+		}
+		for _, reservation := range booking.Reservations {
+			if reservation.RoomID == nil && reservation.RoomType != nil {
+				lines = append(lines, newPriceLine(reservation.RoomType.Name, nights, reservation.RoomType.BasePrice))
+			}
+		}
+	}
+	for _, entry := range ledger {
+		if entry.Kind == models.PaymentKindCharge && entry.Settled() {
+			lines = append(lines, newPriceLine(entry.Note, 1, entry.Amount))
+		}
+	}
+	return newQuote(lines, booking.Hotel.TaxRules, booking.CheckIn, nights, booking.Adults, booking.Children)
+}
+
+// Balance sums up a booking's ledger. Outstanding is what the guest still owes; below zero the hotel owes the guest.
+type Balance struct {
+	StayTotal   float32 `json:"stay_total"`
+	Charges     float32 `json:"charges"`
+	Taxes       float32 `json:"taxes"`
+	Paid        float32 `json:"paid"`
+	Refunded    float32 `json:"refunded"`
+	Outstanding float32 `json:"outstanding"`
+}
+
+// BookingBalance adds the stay, the ledger's charges and the taxes on them up against its payments,
+// deposits and refunds. Taxes only counts the ones not already included in the prices; card entries
+// the provider is still processing or refused don't count.
+func BookingBalance(booking *models.Booking, ledger []models.Payment) Balance {
+	quote := BookingQuote(booking, ledger)
+	var charges, paid, refunded float64
+	for _, entry := range ledger {
+		if !entry.Settled() {
+			continue
+		}
+		switch entry.Kind {
+		case models.PaymentKindCharge:
+			charges += float64(entry.Amount)
+		case models.PaymentKindPayment, models.PaymentKindDeposit:
+			paid += float64(entry.Amount)
+		case models.PaymentKindRefund:
+			refunded += float64(entry.Amount)
+		}
+	}
+	stay := float64(quote.Subtotal) - charges
+	return Balance{
+		StayTotal:   roundMoney(stay),
+		Charges:     roundMoney(charges),
+		Taxes:       roundMoney(float64(quote.Total) - float64(quote.Subtotal)),
+		Paid:        roundMoney(paid),
+		Refunded:    roundMoney(refunded),
+		Outstanding: roundMoney(float64(quote.Total) - paid + refunded),
+	}
+}
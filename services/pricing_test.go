@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.mod/models"
+)
+
+var (
+	vatIncluded = models.TaxRule{Name: "VAT", Kind: models.TaxKindPercent, Rate: 20, Inclusive: true}
+	vatOnTop    = models.TaxRule{Name: "VAT", Kind: models.TaxKindPercent, Rate: 20}
+	serviceFee  = models.TaxRule{Name: "Service", Kind: models.TaxKindPercent, Rate: 10}
+	cityTax     = models.TaxRule{Name: "City tax", Kind: models.TaxKindPerPersonNight, Rate: 1.5}
+	// cityTax2026 gives way to cityTax2027 for the stays from 2027 on
+	cityTax2026 = models.TaxRule{Name: "City tax", Kind: models.TaxKindPerPersonNight, Rate: 1.5, EffectiveUntil: models.NewDate(2027, 1, 1)}
+	cityTax2027 = models.TaxRule{Name: "City tax", Kind: models.TaxKindPerPersonNight, Rate: 2, EffectiveFrom: models.NewDate(2027, 1, 1)}
+)
+
+// formatTaxes writes the tax lines as "name amount; ..." for comparing
+func formatTaxes(taxes []TaxLine) string {
+	var parts []string
+	for _, tax := range taxes {
+		parts = append(parts, fmt.Sprintf("%s %.2f", tax.Name, tax.Amount))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestNewQuote(t *testing.T) {
+	summer := models.NewDate(2026, 7, 1)
+	tests := []struct {
+		name             string
+		lines            []PriceLine
+		rules            []models.TaxRule
+		checkIn          models.Date
+		nights           int
+		adults, children int
+		wantTaxes        string
+		wantSubtotal     float32
+		wantTotal        float32
+	}{
+		{"no rules", []PriceLine{newPriceLine("Double", 2, 100)}, nil, summer, 2, 2, 0, "", 200, 200},
+		{"exclusive percent is added", []PriceLine{newPriceLine("Double", 2, 100)}, []models.TaxRule{vatOnTop}, summer, 2, 2, 0,
+			"VAT 40.00", 200, 240},
+		{"inclusive percent is already in the price", []PriceLine{newPriceLine("Double", 2, 120)}, []models.TaxRule{vatIncluded}, summer, 2, 2, 0,
+			"VAT 40.00", 240, 240},
+		{"exclusive percent is taken of the net price", []PriceLine{newPriceLine("Double", 2, 120)}, []models.TaxRule{vatIncluded, serviceFee},
+			summer, 2, 2, 0, "VAT 40.00; Service 20.00", 240, 260},
+		{"percent covers extra charges", []PriceLine{newPriceLine("Double", 2, 100), newPriceLine("Minibar", 1, 50)},
+			[]models.TaxRule{vatOnTop}, summer, 2, 2, 0, "VAT 50.00", 250, 300},
+		{"per night for every guest", []PriceLine{newPriceLine("Family", 3, 150)}, []models.TaxRule{cityTax}, summer, 3, 2, 1,
+			"City tax 13.50", 450, 463.5},
+		{"per night without exempt children", []PriceLine{newPriceLine("Family", 3, 150)},
+			[]models.TaxRule{{Name: "City tax", Kind: models.TaxKindPerPersonNight, Rate: 1.5, ExemptChildren: true}}, summer, 3, 2, 1,
+			"City tax 9.00", 450, 459},
+		{"per night without nights", []PriceLine{newPriceLine("Cancellation fee", 1, 100)}, []models.TaxRule{cityTax}, summer, 0, 2, 0,
+			"", 100, 100},
+		{"percent and per night together", []PriceLine{newPriceLine("Double", 2, 120)}, []models.TaxRule{vatIncluded, cityTax}, summer, 2, 2, 0,
+			"VAT 40.00; City tax 6.00", 240, 246},
+		{"last stay before the rule ends", []PriceLine{newPriceLine("Double", 2, 100)}, []models.TaxRule{cityTax2026, cityTax2027},
+			models.NewDate(2026, 12, 31), 2, 2, 0, "City tax 6.00", 200, 206},
+		{"first stay of the new rule", []PriceLine{newPriceLine("Double", 2, 100)}, []models.TaxRule{cityTax2026, cityTax2027},
+			models.NewDate(2027, 1, 1), 2, 2, 0, "City tax 8.00", 200, 208},
+		{"before a rule starts", []PriceLine{newPriceLine("Double", 2, 100)}, []models.TaxRule{cityTax2027}, summer, 2, 2, 0,
+			"", 200, 200},
+		{"an inclusive rule out of effect isn't taken out of the net price", []PriceLine{newPriceLine("Double", 2, 100)},
+			[]models.TaxRule{{Name: "Old VAT", Kind: models.TaxKindPercent, Rate: 20, Inclusive: true, EffectiveUntil: summer}, serviceFee},
+			summer, 2, 2, 0, "Service 20.00", 200, 220},
+		{"rounds each tax to cents", []PriceLine{newPriceLine("Single", 1, 99.99)},
+			[]models.TaxRule{{Name: "Sales tax", Kind: models.TaxKindPercent, Rate: 7}}, summer, 1, 1, 0, "Sales tax 7.00", 99.99, 106.99},
+		{"rounds an inclusive tax to cents", []PriceLine{newPriceLine("Single", 1, 100)},
+			[]models.TaxRule{{Name: "VAT", Kind: models.TaxKindPercent, Rate: 19, Inclusive: true}}, summer, 1, 1, 0, "VAT 15.97", 100, 100},
+		{"rounds half a cent up", []PriceLine{newPriceLine("Single", 1, 10.5)},
+			[]models.TaxRule{{Name: "Service", Kind: models.TaxKindPercent, Rate: 5}}, summer, 1, 1, 0, "Service 0.53", 10.5, 11.03},
+		{"a tax that rounds to nothing isn't listed", []PriceLine{newPriceLine("Coffee", 1, 0.05)},
+			[]models.TaxRule{{Name: "Service", Kind: models.TaxKindPercent, Rate: 5}}, summer, 1, 1, 0, "", 0.05, 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote := newQuote(tt.lines, tt.rules, tt.checkIn, tt.nights, tt.adults, tt.children)
+			if got := formatTaxes(quote.Taxes); got != tt.wantTaxes {
+				t.Errorf("taxes = %q, want %q", got, tt.wantTaxes)
+			}
+			if quote.Subtotal != tt.wantSubtotal || quote.Total != tt.wantTotal {
+				t.Errorf("subtotal %.2f, total %.2f; want %.2f and %.2f", quote.Subtotal, quote.Total, tt.wantSubtotal, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestValidateTaxRuleEffectiveDates(t *testing.T) {
+	rule := cityTax
+	rule.HotelID = 1
+	rule.EffectiveFrom, rule.EffectiveUntil = models.NewDate(2027, 1, 1), models.NewDate(2027, 1, 1)
+	if problems := ValidateTaxRule(&rule); len(problems) != 1 {
+		t.Errorf("problems with an empty effective range = %q, want one", problems)
+	}
+	rule.EffectiveUntil = models.NewDate(2027, 1, 2)
+	if problems := ValidateTaxRule(&rule); len(problems) != 0 {
+		t.Errorf("problems with a one-day effective range = %q, want none", problems)
+	}
+}
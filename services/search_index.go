@@ -0,0 +1,448 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	SearchEntityHotel   = "hotel"
+	SearchEntityGuest   = "guest"
+	SearchEntityBooking = "booking"
+)
+
+// SearchDocument is what the index knows about one hotel, guest or booking. HotelID scopes it to
+// a hotel, 0 for guests; Refs name the records its text was taken from, e.g. "guest:5".
+type SearchDocument struct {
+	Entity   string
+	ID       uint
+	HotelID  uint
+	Title    string
+	Subtitle string
+	Text     string
+	Phones   []string
+	Refs     []string
+}
+
+// SearchMatch is a document that matched a query with its score; higher is better
+type SearchMatch struct {
+	Document SearchDocument
+	Score    float64
+}
+
+// SearchIndex stores documents and finds them by text or phone number. MemoryIndex is the in-process
+// implementation; an external search engine can take its place behind the same interface.
+type SearchIndex interface {
+	Put(doc SearchDocument)
+	Remove(entity string, id uint)
+	Get(entity string, id uint) (SearchDocument, bool)
+	// Referencing lists the documents whose Refs contain ref
+	Referencing(ref string) []SearchDocument
+	// Search returns every match, best first
+	Search(query string) []SearchMatch
+}
+
+const (
+	titleWeight = 2.0
+	textWeight  = 1.0
+	// minPrefixLength is the shortest query word that matches the beginning of longer words
+	minPrefixLength = 2
+)
+
+type searchKey struct {
+	entity string
+	id     uint
+}
+
+// MemoryIndex is an inverted index of normalized words. Words are matched exactly, by prefix,
+// or with one typo (two in long words); phone numbers are matched in full or by their last digits.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[searchKey]SearchDocument
+	postings map[string]map[searchKey]float64
+	phones   map[searchKey][]string
+	refs     map[string]map[searchKey]bool
+	// byLength and byPrefix narrow the terms a query word is compared with before edit distances are computed
+	byLength map[int]map[string]bool
+	byPrefix map[string]map[string]bool
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     map[searchKey]SearchDocument{},
+		postings: map[string]map[searchKey]float64{},
+		phones:   map[searchKey][]string{},
+		refs:     map[string]map[searchKey]bool{},
+		byLength: map[int]map[string]bool{},
+		byPrefix: map[string]map[string]bool{},
+	}
+}
+
+func (idx *MemoryIndex) Put(doc SearchDocument) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	key := searchKey{doc.Entity, doc.ID}
+	idx.remove(key)
+
+	idx.docs[key] = doc
+	add := func(text string, weight float64) {
+		for _, term := range searchTerms(text) {
+			if idx.postings[term] == nil {
+				idx.postings[term] = map[searchKey]float64{}
+				idx.addTerm(term)
+			}
+			idx.postings[term][key] = max(idx.postings[term][key], weight)
+		}
+	}
+	add(doc.Title, titleWeight)
+	add(doc.Text, textWeight)
+	for _, phone := range doc.Phones {
+		if normalized := normalizePhone(phone); normalized != "" {
+			idx.phones[key] = append(idx.phones[key], normalized)
+		}
+	}
+	for _, ref := range doc.Refs {
+		if idx.refs[ref] == nil {
+			idx.refs[ref] = map[searchKey]bool{}
+		}
+		idx.refs[ref][key] = true
+	}
+}
+
+func (idx *MemoryIndex) Remove(entity string, id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(searchKey{entity, id})
+}
+
+func (idx *MemoryIndex) remove(key searchKey) {
+	doc, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	for _, term := range append(searchTerms(doc.Title), searchTerms(doc.Text)...) {
+		delete(idx.postings[term], key)
+		if _, ok := idx.postings[term]; ok && len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+			idx.removeTerm(term)
+		}
+	}
+	for _, ref := range doc.Refs {
+		delete(idx.refs[ref], key)
+		if len(idx.refs[ref]) == 0 {
+			delete(idx.refs, ref)
+		}
+	}
+	delete(idx.phones, key)
+	delete(idx.docs, key)
+}
+
+func (idx *MemoryIndex) addTerm(term string) {
+	if idx.byLength[len(term)] == nil {
+		idx.byLength[len(term)] = map[string]bool{}
+	}
+	idx.byLength[len(term)][term] = true
+	if len(term) >= minPrefixLength {
+		prefix := term[:minPrefixLength]
+		if idx.byPrefix[prefix] == nil {
+			idx.byPrefix[prefix] = map[string]bool{}
+		}
+		idx.byPrefix[prefix][term] = true
+	}
+}
+
+func (idx *MemoryIndex) removeTerm(term string) {
+	delete(idx.byLength[len(term)], term)
+	if len(idx.byLength[len(term)]) == 0 {
+		delete(idx.byLength, len(term))
+	}
+	if len(term) >= minPrefixLength {
+		prefix := term[:minPrefixLength]
+		delete(idx.byPrefix[prefix], term)
+		if len(idx.byPrefix[prefix]) == 0 {
+			delete(idx.byPrefix, prefix)
+		}
+	}
+}
+
+// candidates lists the indexed terms that can be similar to query: the same word, the words it begins
+// and the words close enough in length to be within its typo allowance
+func (idx *MemoryIndex) candidates(query string) map[string]bool {
+	found := map[string]bool{}
+	if _, ok := idx.postings[query]; ok {
+		found[query] = true
+	}
+	if len(query) >= minPrefixLength {
+		for term := range idx.byPrefix[query[:minPrefixLength]] {
+			found[term] = true
+		}
+	}
+	allowed := typoAllowance(query)
+	for n := len(query) - allowed; allowed > 0 && n <= len(query)+allowed; n++ {
+		for term := range idx.byLength[n] {
+			found[term] = true
+		}
+	}
+	return found
+}
+
+func (idx *MemoryIndex) Get(entity string, id uint) (SearchDocument, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	doc, ok := idx.docs[searchKey{entity, id}]
+	return doc, ok
+}
+
+func (idx *MemoryIndex) Referencing(ref string) []SearchDocument {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	docs := make([]SearchDocument, 0, len(idx.refs[ref]))
+	for key := range idx.refs[ref] {
+		docs = append(docs, idx.docs[key])
+	}
+	return docs
+}
+
+// Search scores a document by how well each query word matches its words. Every word of the query
+// has to match; a query that is a phone number also finds the documents with that number.
+func (idx *MemoryIndex) Search(query string) []SearchMatch {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := map[searchKey]float64{}
+	if terms := searchTerms(query); len(terms) > 0 {
+		for key, score := range idx.matchAll(terms) {
+			scores[key] += score
+		}
+	}
+	if digits, ok := phoneQuery(query); ok {
+		for key, phones := range idx.phones {
+			if score := phoneScore(phones, digits); score > 0 {
+				scores[key] += score
+			}
+		}
+	}
+
+	matches := make([]SearchMatch, 0, len(scores))
+	for key, score := range scores {
+		matches = append(matches, SearchMatch{Document: idx.docs[key], Score: score})
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].Score != matches[b].Score {
+			return matches[a].Score > matches[b].Score
+		}
+		if matches[a].Document.Entity != matches[b].Document.Entity {
+			return matches[a].Document.Entity < matches[b].Document.Entity
+		}
+		return matches[a].Document.ID < matches[b].Document.ID
+	})
+	return matches
+}
+
+// matchAll keeps the documents that match every term, each term counting with its best match
+func (idx *MemoryIndex) matchAll(terms []string) map[searchKey]float64 {
+	var total map[searchKey]float64
+	for _, queryTerm := range terms {
+		best := map[searchKey]float64{}
+		for term := range idx.candidates(queryTerm) {
+			similarity := termSimilarity(queryTerm, term)
+			if similarity == 0 {
+				continue
+			}
+			for key, weight := range idx.postings[term] {
+				best[key] = max(best[key], similarity*weight)
+			}
+		}
+
+		if total == nil {
+			total = best
+			continue
+		}
+		for key := range total {
+			if score, ok := best[key]; ok {
+				total[key] += score
+			} else {
+				delete(total, key)
+			}
+		}
+	}
+	return total
+}
+
+// termSimilarity is 1 for the same word and less for a prefix or a word with typos; 0 means no match
+func termSimilarity(query, term string) float64 {
+	if query == term {
+		return 1
+	}
+	if len(query) >= minPrefixLength && strings.HasPrefix(term, query) {
+		return 0.5 + 0.3*float64(len(query))/float64(len(term))
+	}
+	allowed := typoAllowance(query)
+	if allowed == 0 || abs(len(query)-len(term)) > allowed {
+		return 0
+	}
+	if distance := editDistance(query, term, allowed); distance <= allowed {
+		return 0.8 - 0.2*float64(distance-1)
+	}
+	return 0
+}
+
+// typoAllowance is how many typos a query word may have: none in short words, two in long ones
+func typoAllowance(query string) int {
+	switch {
+	case len(query) >= 8:
+		return 2
+	case len(query) >= 4:
+		return 1
+	}
+	return 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of neighbouring letters
+// between a and b. Past limit it returns limit+1 without finishing.
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// transliteration spells Ukrainian and Russian letters in Latin, by the Ukrainian national standard where
+// the two languages share a letter. Position-dependent letters always take their in-word form, e.g. я is ia.
+var transliteration = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d", 'е': "e", 'є': "ie", 'ж': "zh", 'з': "z",
+	'и': "y", 'і': "i", 'ї': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p",
+	'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ь': "", 'ю': "iu", 'я': "ia", 'ё': "e", 'ы': "y", 'э': "e", 'ъ': "",
+	// Латиниця з діакритикою, як у польських і чеських записах прізвищ
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ą': "a", 'ć': "c", 'č': "ch", 'é': "e", 'è': "e", 'ê': "e", 'ë': "e", 'ę': "e",
+	'í': "i", 'ï': "i", 'ł': "l", 'ń': "n", 'ñ': "n", 'ó': "o", 'ö': "o", 'ś': "s", 'š': "sh", 'ú': "u", 'ü': "u",
+	'ý': "y", 'ź': "z", 'ż': "z", 'ž': "zh",
+}
+
+// spellingFold maps the ways one sound is spelt in Latin transliterations to one spelling, so that
+// Hryhorii, Grigoriy and Григорій all become hrihori. Longer patterns come first.
+var spellingFold = strings.NewReplacer(
+	"shch", "sch", "kh", "h", "ph", "f", "ck", "k", "tz", "ts",
+	"x", "ks", "w", "v", "q", "k", "g", "h", "j", "i", "y", "i", "c", "k",
+)
+
+// searchTerms splits text into normalized words: lower case, Cyrillic transliterated, spelling variants
+// folded and doubled letters collapsed. Apostrophes are dropped, so that Мар'яна is one word.
+func searchTerms(text string) []string {
+	var latin strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == '\'' || r == '’' || r == 'ʼ' || r == '`':
+		default:
+			if spelled, ok := transliteration[r]; ok {
+				latin.WriteString(spelled)
+			} else {
+				latin.WriteRune(r)
+			}
+		}
+	}
+
+	words := strings.FieldsFunc(latin.String(), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		folded := []rune(spellingFold.Replace(word))
+		term := make([]rune, 0, len(folded))
+		for i, r := range folded {
+			if i == 0 || r != folded[i-1] || unicode.IsDigit(r) {
+				term = append(term, r)
+			}
+		}
+		terms = append(terms, string(term))
+	}
+	return terms
+}
+
+// normalizePhone keeps the digits of a phone number and writes Ukrainian numbers in full
+// international form, e.g. 050 123 45 67 becomes 380501234567
+func normalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	number := digits.String()
+	switch {
+	case len(number) == 10 && strings.HasPrefix(number, "0"):
+		return "38" + number
+	case len(number) == 9 && !strings.HasPrefix(number, "0"):
+		return "380" + number
+	}
+	return number
+}
+
+// phoneQuery reports whether the query is a phone number or its last digits, i.e. at least four digits
+// and nothing else but the usual separators
+func phoneQuery(query string) (string, bool) {
+	var digits strings.Builder
+	for _, r := range query {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" +-().", r):
+		default:
+			return "", false
+		}
+	}
+	return digits.String(), digits.Len() >= 4
+}
+
+// phoneScore ranks the whole number above its ending and the ending above digits elsewhere in it
+func phoneScore(phones []string, digits string) float64 {
+	full := normalizePhone(digits)
+	best := 0.0
+	for _, phone := range phones {
+		switch {
+		case phone == full:
+			best = max(best, 3)
+		case strings.HasSuffix(phone, digits):
+			best = max(best, 1.5)
+		case strings.Contains(phone, digits):
+			best = max(best, 1)
+		}
+	}
+	return best
+}
+
+func (k searchKey) String() string {
+	return fmt.Sprintf("%s:%d", k.entity, k.id)
+}
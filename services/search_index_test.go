@@ -0,0 +1,126 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func searchFixture() *MemoryIndex {
+	idx := NewMemoryIndex()
+	for _, doc := range []SearchDocument{
+		{Entity: SearchEntityGuest, ID: 1, Title: "Olena Kovalenko", Phones: []string{"+380 50 123 45 67"}},
+		{Entity: SearchEntityGuest, ID: 2, Title: "Григорій Шевченко", Phones: []string{"067-765-43-21"}},
+		{Entity: SearchEntityGuest, ID: 3, Title: "Мар'яна Чорновол"},
+		{Entity: SearchEntityGuest, ID: 4, Title: "Andrzej Wiśniewski", Phones: []string{"+48 601 234 567"}},
+		{Entity: SearchEntityHotel, ID: 1, Title: "Carpathian Lodge", Text: "Yaremche"},
+	} {
+		idx.Put(doc)
+	}
+	return idx
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	idx := searchFixture()
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"exact", "Olena Kovalenko", []string{"guest:1"}},
+		{"prefix", "kov", []string{"guest:1"}},
+		{"one typo", "Kovalanko", []string{"guest:1"}},
+		{"swapped letters", "Kovlaenko", []string{"guest:1"}},
+		{"two typos in a long word", "Kovalemkko", []string{"guest:1"}},
+		{"too many typos", "Kvlanko", nil},
+		{"no typos in short words", "Oln", nil},
+		{"every word has to match", "Olena Shevchenko", nil},
+		{"Ukrainian transliteration", "Hryhorii Shevchenko", []string{"guest:2"}},
+		{"Russian transliteration", "Grigoriy", []string{"guest:2"}},
+		{"Cyrillic query", "Коваленко", []string{"guest:1"}},
+		{"apostrophe", "Mariana", []string{"guest:3"}},
+		{"ch", "Chornovol", []string{"guest:3"}},
+		{"diacritics", "Wisniewski", []string{"guest:4"}},
+		{"hotel text", "Яремче", []string{"hotel:1"}},
+		{"full phone", "050 123 45 67", []string{"guest:1"}},
+		{"international phone", "+380501234567", []string{"guest:1"}},
+		{"last digits", "4321", []string{"guest:2"}},
+		{"digits inside the number", "765-43", []string{"guest:2"}},
+		{"foreign phone", "601234567", []string{"guest:4"}},
+		{"too few digits", "567", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, match := range idx.Search(tc.query) {
+				got = append(got, searchKey{match.Document.Entity, match.Document.ID}.String())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Search(%q) = %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexRanking(t *testing.T) {
+	idx := NewMemoryIndex()
+	idx.Put(SearchDocument{Entity: SearchEntityGuest, ID: 1, Title: "Ivan Kovalenko"})
+	idx.Put(SearchDocument{Entity: SearchEntityGuest, ID: 2, Title: "Ivan Kovalchuk"})
+	idx.Put(SearchDocument{Entity: SearchEntityGuest, ID: 3, Title: "Ivan Kovalenk"})
+	idx.Put(SearchDocument{Entity: SearchEntityBooking, ID: 4, Title: "Booking 4", Text: "Ivan Kovalenko"})
+
+	var got []uint
+	for _, match := range idx.Search("kovalenko") {
+		got = append(got, match.Document.ID)
+	}
+	// Назва важить більше за текст, тож опечатка в назві вища за точний збіг у тексті; Kovalchuk не схожий
+	if want := []uint{1, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("ranking = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryIndexRemoveDropsTerms(t *testing.T) {
+	idx := searchFixture()
+	for _, doc := range []SearchDocument{
+		{Entity: SearchEntityGuest, ID: 1}, {Entity: SearchEntityGuest, ID: 2}, {Entity: SearchEntityGuest, ID: 3},
+		{Entity: SearchEntityGuest, ID: 4}, {Entity: SearchEntityHotel, ID: 1},
+	} {
+		idx.Remove(doc.Entity, doc.ID)
+	}
+	if len(idx.postings) != 0 || len(idx.byLength) != 0 || len(idx.byPrefix) != 0 || len(idx.phones) != 0 {
+		t.Errorf("index still has %d terms, %d lengths, %d prefixes and %d phones",
+			len(idx.postings), len(idx.byLength), len(idx.byPrefix), len(idx.phones))
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hryhorii", []string{"hrihori"}},
+		{"Grigoriy", []string{"hrihori"}},
+		{"Григорій", []string{"hrihori"}},
+		{"Щербак", []string{"scherbak"}},
+		{"Shcherbak", []string{"scherbak"}},
+		{"Мар'яна", []string{"mariana"}},
+		{"Room 1100", []string{"rom", "1100"}},
+	}
+	for _, tc := range tests {
+		if got := searchTerms(tc.text); !slices.Equal(got, tc.want) {
+			t.Errorf("searchTerms(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+}
+
+// TestCandidatesCoverEverySimilarTerm checks that narrowing by length and prefix loses no match a full scan finds
+func TestCandidatesCoverEverySimilarTerm(t *testing.T) {
+	idx := searchFixture()
+	for _, query := range []string{"kov", "kovalanko", "kovalemkko", "hrihori", "ol", "olna", "iaremche", "lodg", "a"} {
+		candidates := idx.candidates(query)
+		for term := range idx.postings {
+			if termSimilarity(query, term) > 0 && !candidates[term] {
+				t.Errorf("%q is similar to %q but not a candidate", term, query)
+			}
+		}
+	}
+}